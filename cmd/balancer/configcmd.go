@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"videobalance/internal/config"
+)
+
+const configUsage = `Использование:
+  balancer config validate <файл>       проверить файл конфигурации
+  balancer config diff <старый> <новый> показать изменённые параметры
+  balancer config schema                вывести JSON Schema файла конфигурации`
+
+// runConfig реализует подкоманду config для проверки файлов перед выкладкой.
+// Коды выхода: 0 — успех (для diff — изменений нет), 1 — ошибки в файле (для diff — есть изменения),
+// 2 — неверные аргументы или ошибки в файлах diff.
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "validate" && len(args) == 1:
+		return validateConfig(os.Stdout, args[0])
+	case cmd == "diff" && len(args) == 2:
+		return diffConfig(os.Stdout, args[0], args[1])
+	case cmd == "schema" && len(args) == 0:
+		os.Stdout.Write(config.Schema())
+		return 0
+	case cmd == "-h" || cmd == "-help" || cmd == "help":
+		fmt.Fprintln(os.Stdout, configUsage)
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, configUsage)
+	return 2
+}
+
+// Проверка файла теми же правилами, что и при запуске: типы, ограничения, адреса и
+// совпадение портов, регулярные выражения, URL и ключ подписи удалённой конфигурации
+func validateConfig(w io.Writer, path string) int {
+	if _, err := config.LoadFile(path); err != nil {
+		fmt.Fprintf(w, "%s: ошибки конфигурации:\n", path)
+		printErrors(w, err)
+		return 1
+	}
+	fmt.Fprintf(w, "%s: конфигурация корректна\n", path)
+	return 0
+}
+
+// Сравнение двух файлов с учётом значений по умолчанию
+func diffConfig(w io.Writer, oldPath, newPath string) int {
+	var cfgs [2]*config.Config
+	failed := false
+	for i, path := range []string{oldPath, newPath} {
+		cfg, err := config.LoadFile(path)
+		if err != nil {
+			fmt.Fprintf(w, "%s: ошибки конфигурации:\n", path)
+			printErrors(w, err)
+			failed = true
+		}
+		cfgs[i] = cfg
+	}
+	if failed {
+		return 2
+	}
+
+	changes := config.Diff(cfgs[0], cfgs[1])
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "Изменений нет")
+		return 0
+	}
+	restart := 0
+	for _, c := range changes {
+		fmt.Fprintln(w, c)
+		if !c.Hot {
+			restart++
+		}
+	}
+	fmt.Fprintf(w, "Изменено параметров: %d, применятся только после перезапуска: %d\n", len(changes), restart)
+	return 1
+}
+
+// Вывод объединённых ошибок по одной в строке
+func printErrors(w io.Writer, err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
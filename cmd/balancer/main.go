@@ -7,20 +7,31 @@ import (
 	"videobalance/internal/worker"
 	pb "videobalance/proto"
 
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"log/slog"
 	"net"
 	"net/http"
-	_ "net/http/pprof" // Инициализация пакета pprof
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	"videobalance/internal/accesslog"
+	"videobalance/internal/cache"
 	"videobalance/internal/config"
+	"videobalance/internal/interceptor"
+	"videobalance/internal/logs"
+	"videobalance/internal/metrics"
 	"videobalance/internal/server"
+	"videobalance/internal/tracing"
 	_ "videobalance/proto"
 )
 
+const shutdownTimeout = 30 * time.Second // Время на завершение периодических задач при остановке
+
 // healthCheckHandler для проверки состояния через HTTP
 func healthCheckHandler(w http.ResponseWriter, _ *http.Request) {
 	// Это простая проверка состояния
@@ -29,24 +40,110 @@ func healthCheckHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 func main() {
-	// Логирование для структурированных логов
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	slog.SetDefault(logger)
+	// Подкоманды выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "prewarm":
+			os.Exit(runPrewarm(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		case "schema":
+			// Прежнее имя подкоманды config schema
+			os.Exit(runConfig([]string{"schema"}))
+		}
+	}
 
-	worker.AdjustWorkerPoolSize()
+	// Логирование для структурированных логов. Уровень отбирает LevelHandler,
+	// чтобы его можно было менять во время работы глобально и для отдельных пакетов.
+	// Секреты в URL и заголовках скрываются до записи.
+	baseHandler := slog.Handler(logs.NewRedactingHandler(
+		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logs.MinLevel}),
+	))
+	slog.SetDefault(slog.New(logs.NewLevelHandler(baseHandler)))
 
-	// Настроим pprof
+	// Загрузка конфигурации: файл, удалённый источник, переменные окружения и флаги
+	loader := config.NewLoader(os.Args[1:])
+	cfg, err := loader.Load(context.Background())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("Ошибка загрузки конфигурации", "ошибка", err)
+		os.Exit(1)
+	}
+	slog.Info("Конфигурация загружена", "CDN_HOST", cfg.CDNHost, "SERVER_PORT", cfg.ServerPort)
+
+	// Служебные обработчики: pprof, уровни логирования, конфигурация и задачи.
+	// Они меняют состояние сервиса, поэтому доступны только на служебном порту, а не на порту health check.
+	debugMux := http.NewServeMux()
+	debugMux.HandleFunc("/debug/pprof/", pprof.Index)
+	debugMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	debugMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	debugMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	debugMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	debugMux.Handle("/debug/loglevel", logs.LevelsHTTPHandler())
 	go func() {
-		log.Println(http.ListenAndServe("localhost:6060", nil)) // Порт для pprof
+		log.Println(http.ListenAndServe(cfg.DebugAddr, debugMux))
 	}()
 
-	// Загрузка конфигурации
-	cfg, err := config.LoadConfig()
+	redactor, err := newRedactor(cfg)
 	if err != nil {
-		slog.Error("Ошибка загрузки конфигурации", "ошибка", err)
+		slog.Error("Ошибка настройки скрытия секретов в логах", "ошибка", err)
+		return
+	}
+	logs.SetRedactor(redactor)
+
+	if err := logs.SetLevel("", cfg.LogLevel, 0); err != nil {
+		slog.Error("Ошибка установки уровня логирования", "ошибка", err)
+		return
+	}
+
+	// Семплирование одинаковых сообщений; ошибки пишутся всегда
+	if cfg.LogSamplingFirst > 0 {
+		sampling := logs.NewSamplingHandler(baseHandler, logs.SamplingOptions{
+			First:      cfg.LogSamplingFirst,
+			Thereafter: cfg.LogSamplingThereafter,
+			Interval:   cfg.LogSamplingInterval,
+			KeyAttr:    cfg.LogSamplingKey,
+		})
+		defer sampling.Close()
+		slog.SetDefault(slog.New(logs.NewLevelHandler(sampling)))
+	}
+
+	// Кэш маршрутов
+	cache.Configure(cache.Options{
+		Size:     cfg.CacheSize,
+		TTL:      cfg.CacheTTL,
+		Shards:   cfg.CacheShards,
+		Policies: cachePolicies(cfg),
+	})
+
+	// Исполнитель фоновых задач
+	worker.Start(worker.Options{
+		Workers:         cfg.WorkerCount,
+		MinWorkers:      cfg.WorkerMinCount,
+		MaxWorkers:      cfg.WorkerMaxCount,
+		QueueSize:       cfg.WorkerQueueSize,
+		TaskTimeout:     cfg.WorkerTaskTimeout,
+		TargetQueueWait: cfg.WorkerTargetQueueWait,
+	})
+
+	// Поведение асинхронного логгера при переполнении канала
+	if err := logs.SetOverflow(logs.OverflowOptions{
+		Policy:       logs.OverflowPolicy(cfg.LogOverflowPolicy),
+		BlockTimeout: cfg.LogOverflowBlockTimeout,
+		SpillPath:    cfg.LogSpillPath,
+		SpillMaxSize: int64(cfg.LogSpillMaxSizeMB) << 20,
+	}); err != nil {
+		slog.Error("Ошибка настройки переполнения канала логов", "ошибка", err)
+		return
+	}
+
+	// Приёмники асинхронного логгера
+	if err := logs.ConfigureSinks(cfg.LogSinks); err != nil {
+		slog.Error("Ошибка настройки приёмников логов", "ошибка", err)
 		return
 	}
-	slog.Info("Конфигурация загружена", "CDN_HOST", cfg.CDNHost, "SERVER_PORT", cfg.ServerPort)
 
 	// Настройка gRPC сервера
 	lis, err := net.Listen("tcp", cfg.ServerPort)
@@ -56,19 +153,89 @@ func main() {
 	}
 	slog.Info("gRPC сервер слушает порт", "порт", cfg.ServerPort)
 
+	// Журнал доступа: по одной строке на запрос Redirect
+	var accessLog *accesslog.Logger
+	if cfg.AccessLog != "off" {
+		accessLog, err = accesslog.New(accesslog.Options{
+			Output:         cfg.AccessLog,
+			Format:         accesslog.Format(cfg.AccessLogFormat),
+			MaxSize:        int64(cfg.AccessLogMaxSizeMB) * 1024 * 1024,
+			RotateInterval: cfg.AccessLogRotateInterval,
+			MaxBackups:     cfg.AccessLogMaxBackups,
+			Compress:       cfg.AccessLogCompress,
+		})
+		if err != nil {
+			slog.Error("Ошибка создания журнала доступа", "ошибка", err)
+			return
+		}
+	}
+
 	// Создание нового экземпляра сервера балансировщика
-	balancerServer := server.NewBalancerServer("balancer-domain.com", cfg.CDNHost)
-
-	grpcServer := grpc.NewServer(
-		grpc.MaxConcurrentStreams(200000),
-		grpc.MaxRecvMsgSize(100*1024*1024),
-		grpc.MaxSendMsgSize(100*1024*1024),
-		grpc.WriteBufferSize(256*1024*1024),
-		grpc.ReadBufferSize(256*1024*1024),
+	opts := serverOptions(cfg)
+	opts.AccessLog = accessLog
+	balancerServer := server.NewBalancerServer(cfg.BalancerDomain, cfg.CDNHost, opts)
+
+	// Перезагрузка конфигурации по SIGHUP, при изменении файла или удалённого документа и через /debug/config
+	reloader := newReloader(loader, cfg, balancerServer)
+	if err := reloader.Start(balancerServer.Jobs()); err != nil {
+		slog.Error("Ошибка настройки перезагрузки конфигурации", "ошибка", err)
+		return
+	}
+	debugMux.Handle("/debug/config", reloader.HTTPHandler())
+
+	// Периодические задачи запускаются и останавливаются вместе с сервером
+	debugMux.Handle("/debug/jobs", balancerServer.Jobs().HTTPHandler())
+	balancerServer.Start()
+
+	// Трассировки: контекст W3C из метаданных запроса и экспорт спанов по OTLP
+	shutdownTracing, err := tracing.Start(context.Background(), tracing.Options{
+		Endpoint:    cfg.TracingEndpoint,
+		Protocol:    cfg.TracingProtocol,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		slog.Error("Ошибка настройки трассировки", "ошибка", err)
+		return
+	}
+
+	// Метрики Prometheus: вызовы gRPC учитываются перехватчиками, состояние компонентов читается при запросе
+	metrics.RegisterServer(balancerServer)
+	debugMux.Handle("/metrics", metrics.Handler())
+
+	// Перехватчики вызовов от внешнего к внутреннему. Паника перехватывается внутри метрик
+	// и логирования, чтобы они учли ответ INTERNAL; авторизация — последней, перед обработчиком.
+	grpcLogger := logs.For("grpc")
+	interceptors := interceptor.Chain(
+		interceptor.AssignRequestID(),
+		interceptor.Interceptor{Unary: tracing.UnaryServerInterceptor(), Stream: tracing.StreamServerInterceptor()},
+		interceptor.Logging(grpcLogger),
+		interceptor.Interceptor{Unary: metrics.UnaryServerInterceptor(), Stream: metrics.StreamServerInterceptor()},
+		interceptor.Recovery(grpcLogger),
+		interceptor.Deadline(interceptor.DeadlineOptions{
+			Default: cfg.GRPCDefaultTimeout,
+			Max:     cfg.GRPCMaxTimeout,
+		}),
+		interceptor.Auth(interceptor.AuthOptions{
+			Tokens:        cfg.GRPCAuthTokens,
+			AdminTokens:   cfg.GRPCAuthAdminTokens,
+			TenantTokens:  cfg.GRPCAuthTenantTokens,
+			GatewayTokens: cfg.GRPCAuthGatewayTokens,
+			Restricted:    []string{"/" + pb.Admin_ServiceDesc.ServiceName + "/"},
+			Exempt:        []string{"/" + grpc_health_v1.Health_ServiceDesc.ServiceName + "/"},
+		}),
 	)
 
+	grpcServer := grpc.NewServer(append(interceptors,
+		grpc.MaxConcurrentStreams(uint32(cfg.GRPCMaxConcurrentStreams)),
+		grpc.MaxRecvMsgSize(cfg.GRPCMaxRecvMsgSizeMB<<20),
+		grpc.MaxSendMsgSize(cfg.GRPCMaxSendMsgSizeMB<<20),
+		grpc.WriteBufferSize(cfg.GRPCWriteBufferSizeKB<<10),
+		grpc.ReadBufferSize(cfg.GRPCReadBufferSizeKB<<10),
+	)...)
+
 	// Регистрация сервиса
 	pb.RegisterBalancerServer(grpcServer, balancerServer)
+	pb.RegisterAdminServer(grpcServer, server.NewAdminServer(balancerServer))
 
 	// Настройка Health Check для gRPC
 	healthServer := health.NewServer()
@@ -77,9 +244,6 @@ func main() {
 	// Устанавливаем статус "SERVING" для нашего сервиса
 	healthServer.SetServingStatus("videobalance", grpc_health_v1.HealthCheckResponse_SERVING)
 
-	// Инициализация пула горутин и запуск мониторинга
-	worker.RunWorkers() // Запускаем рабочие горутины
-
 	// Запуск gRPC сервера в горутине
 	go func() {
 		startTime := time.Now()
@@ -90,11 +254,13 @@ func main() {
 		slog.Info("gRPC сервер завершил работу", "время_работы", duration)
 	}()
 
-	// Запуск HTTP сервера для health check
+	// Запуск HTTP сервера для health check; служебные обработчики здесь не регистрируются.
+	// Метрики только читаются и нужны Prometheus, которому служебный порт (localhost) недоступен.
+	healthMux := http.NewServeMux()
+	healthMux.HandleFunc("/health", healthCheckHandler)
+	healthMux.Handle("/metrics", metrics.Handler())
 	go func() {
-		// Добавление обработчика health check
-		http.HandleFunc("/health", healthCheckHandler) // Путь для health check
-		log.Fatal(http.ListenAndServe(":8080", nil))   // Порт для health check
+		log.Fatal(http.ListenAndServe(cfg.HealthAddr, healthMux))
 	}()
 
 	// Канал для graceful shutdown
@@ -112,8 +278,16 @@ func main() {
 		// Завершаем сервер
 		grpcServer.GracefulStop()
 
-		// Завершаем работу пула горутин и gRPC сервера
-		worker.Shutdown() // Завершаем мониторинг горутин
+		// Останавливаем периодические задачи и выполняем оставшиеся фоновые задачи
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := balancerServer.Shutdown(ctx); err != nil {
+			slog.Warn("Периодические задачи не завершились вовремя", "ошибка", err)
+		}
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Не удалось отправить оставшиеся спаны", "ошибка", err)
+		}
+		cancel()
+		worker.Shutdown()
 
 		// Закрытие канала graceful shutdown
 		close(stopChan)
@@ -124,5 +298,12 @@ func main() {
 
 	// Закрытие других ресурсов, если необходимо
 
-	slog.Info("Остановка сервиса завершена")
+	// Записываем накопленные асинхронные логи и журнал доступа перед выходом
+	if err := accessLog.Close(); err != nil {
+		slog.Error("Ошибка закрытия журнала доступа", "ошибка", err)
+	}
+	logs.Close()
+
+	slog.Info("Лимит параллельных запросов", "stats", balancerServer.LimiterStats())
+	slog.Info("Остановка сервиса завершена", "потеряно_логов", logs.Dropped(), "переполнение_логов", logs.Overflow())
 }
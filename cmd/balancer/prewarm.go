@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	pb "videobalance/proto"
+)
+
+// runPrewarm реализует подкоманду prewarm: отправляет список URL на работающий
+// балансировщик и выводит прогресс прогрева кэша
+func runPrewarm(args []string) int {
+	fs := flag.NewFlagSet("prewarm", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:443", "адрес gRPC сервера балансировщика")
+	file := fs.String("file", "", "файл со списком URL по одному в строке ('-' — стандартный ввод)")
+	timeout := fs.Duration("timeout", 10*time.Minute, "максимальное время прогрева")
+	token := fs.String("token", os.Getenv("BALANCER_TOKEN"), "токен Bearer для сервиса Admin (по умолчанию из BALANCER_TOKEN)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: balancer prewarm [флаги] [url...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	req := &pb.PrewarmRequest{Videos: fs.Args()}
+	if *file != "" {
+		var (
+			data []byte
+			err  error
+		)
+		if *file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось прочитать список URL: %v\n", err)
+			return 1
+		}
+		req.List = data
+	}
+	if len(req.Videos) == 0 && len(req.List) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось подключиться к %s: %v\n", *addr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewAdminClient(conn).Prewarm(ctx, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка прогрева: %v\n", err)
+		return 1
+	}
+
+	var last *pb.PrewarmProgress
+	for {
+		progress, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка прогрева: %v\n", err)
+			return 1
+		}
+		last = progress
+
+		for _, failure := range progress.Failures {
+			fmt.Fprintf(os.Stdout, "ошибка\t%s\t%s\n", failure.Video, failure.Error)
+		}
+		fmt.Fprintf(os.Stderr, "Прогрев: %d/%d, успешно %d, ошибок %d\n",
+			progress.Processed, progress.Total, progress.Warmed, progress.Failed)
+	}
+
+	if last == nil || !last.Done {
+		fmt.Fprintln(os.Stderr, "Прогрев прерван до завершения")
+		return 1
+	}
+	if last.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"videobalance/internal/cache"
+	"videobalance/internal/config"
+	"videobalance/internal/limiter"
+	"videobalance/internal/logs"
+	"videobalance/internal/offload"
+	"videobalance/internal/scheduler"
+	"videobalance/internal/server"
+)
+
+// Параметры балансировщика из конфигурации; журнал доступа задаётся отдельно
+func serverOptions(cfg *config.Config) server.Options {
+	return server.Options{
+		Offload: offload.Options{
+			TargetShare:     cfg.OriginTargetShare,
+			TargetOriginRPS: cfg.OriginTargetRPS,
+			MaxOriginRPS:    cfg.OriginMaxRPS,
+			HotThreshold:    uint64(cfg.OriginHotThreshold),
+		},
+		Limiter: limiter.Options{
+			InitialLimit: cfg.ConcurrencyInitialLimit,
+			MinLimit:     cfg.ConcurrencyMinLimit,
+			MaxLimit:     cfg.ConcurrencyMaxLimit,
+			Tolerance:    cfg.ConcurrencyTolerance,
+		},
+		Tenants:         cfg.TenantPriorities,
+		DefaultPriority: cfg.DefaultPriority,
+		TrustHeaders:    cfg.PriorityTrustHeaders,
+		RequestTimeout:  cfg.RequestTimeout,
+
+		HealthCheckInterval: cfg.HealthInterval,
+	}
+}
+
+// Окна кэширования по видам контента из конфигурации; TTL у всех видов общий — cache.ttl
+func cachePolicies(cfg *config.Config) map[cache.Kind]cache.Policy {
+	return map[cache.Kind]cache.Policy{
+		cache.KindManifest: {TTL: cfg.CacheTTL, StaleTTL: cfg.CacheManifestStaleTTL, NegativeTTL: cfg.CacheManifestNegativeTTL},
+		cache.KindSegment:  {TTL: cfg.CacheTTL, StaleTTL: cfg.CacheSegmentStaleTTL, NegativeTTL: cfg.CacheSegmentNegativeTTL},
+		cache.KindOther:    {TTL: cfg.CacheTTL, StaleTTL: cfg.CacheOtherStaleTTL, NegativeTTL: cfg.CacheOtherNegativeTTL},
+	}
+}
+
+// Правила скрытия секретов в логах из конфигурации
+func newRedactor(cfg *config.Config) (*logs.Redactor, error) {
+	return logs.NewRedactor(logs.RedactOptions{
+		QueryParams: cfg.LogRedactParams,
+		Headers:     cfg.LogRedactHeaders,
+		Patterns:    cfg.LogRedactPatterns,
+	})
+}
+
+// reloader перечитывает конфигурацию по SIGHUP, при изменении файла или удалённого
+// документа и по запросу к /debug/config. Новая конфигурация применяется, только если она целиком корректна;
+// иначе продолжает действовать прежняя, а ошибка пишется в лог и в состояние.
+type reloader struct {
+	loader *config.Loader
+	server *server.BalancerServer
+
+	mu      sync.Mutex
+	initial *config.Config // Конфигурация при запуске: параметры без горячей перезагрузки действуют из неё
+	current *config.Config
+	fileSum [sha256.Size]byte
+	status  reloadStatus
+}
+
+// Состояние перезагрузки для /debug/config
+type reloadStatus struct {
+	File            string    `json:"file,omitempty"`
+	Generation      int       `json:"generation"` // Количество применённых перезагрузок
+	LoadedAt        time.Time `json:"loaded_at"`
+	LastAttempt     time.Time `json:"last_attempt"`
+	LastTrigger     string    `json:"last_trigger,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+	RestartRequired []string  `json:"restart_required,omitempty"` // Изменённые параметры, которые применятся после перезапуска
+}
+
+func newReloader(loader *config.Loader, cfg *config.Config, srv *server.BalancerServer) *reloader {
+	r := &reloader{
+		loader:  loader,
+		server:  srv,
+		initial: cfg,
+		current: cfg,
+		status:  reloadStatus{File: cfg.File(), LoadedAt: time.Now()},
+	}
+	r.fileSum, _ = r.readFileSum()
+	return r
+}
+
+// Start подписывается на SIGHUP и регистрирует в планировщике проверку файла
+// и опрос удалённой конфигурации
+func (r *reloader) Start(jobs *scheduler.Scheduler) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_ = r.reload("SIGHUP")
+		}
+	}()
+
+	if r.current.File() != "" && r.current.ConfigWatchInterval > 0 {
+		if err := jobs.Register(scheduler.Job{
+			Name:     "config-watch",
+			Interval: r.current.ConfigWatchInterval,
+			Timeout:  r.current.ConfigWatchInterval,
+			Run:      r.watch,
+		}); err != nil {
+			return err
+		}
+	}
+	if r.current.RemoteURL != "" && r.current.RemotePollInterval > 0 {
+		return jobs.Register(scheduler.Job{
+			Name:     "config-remote",
+			Interval: r.current.RemotePollInterval,
+			Jitter:   r.current.RemotePollInterval / 10,
+			Timeout:  time.Minute,
+			Run:      r.poll,
+		})
+	}
+	return nil
+}
+
+// Перезагрузка при изменении удалённого документа
+func (r *reloader) poll(ctx context.Context) error {
+	changed, err := r.loader.Poll(ctx)
+	if err != nil || !changed {
+		return err
+	}
+	return r.reload("remote")
+}
+
+// Перезагрузка при изменении содержимого файла
+func (r *reloader) watch(context.Context) error {
+	sum, err := r.readFileSum()
+	r.mu.Lock()
+	changed := sum != r.fileSum
+	r.mu.Unlock()
+	if err != nil || !changed {
+		return err
+	}
+	return r.reload("file")
+}
+
+func (r *reloader) readFileSum() ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(r.initial.File())
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// reload собирает конфигурацию заново и применяет параметры с горячей перезагрузкой
+func (r *reloader) reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.LastAttempt = time.Now()
+	r.status.LastTrigger = trigger
+	// Файл запоминается до разбора, чтобы некорректный файл не перечитывался на каждой проверке
+	r.fileSum, _ = r.readFileSum()
+
+	cfg, err := r.load()
+	if err != nil {
+		r.status.LastError = err.Error()
+		slog.Error("Ошибка перезагрузки конфигурации, действует прежняя", "trigger", trigger, "ошибка", err)
+		return err
+	}
+	r.status.LastError = ""
+
+	changes := config.Diff(r.current, cfg)
+	if len(changes) == 0 {
+		slog.Info("Конфигурация не изменилась", "trigger", trigger)
+		return nil
+	}
+
+	// Всё, что может не удаться, проверяется до применения
+	redactor, err := newRedactor(cfg)
+	if err != nil {
+		r.status.LastError = err.Error()
+		slog.Error("Ошибка перезагрузки конфигурации, действует прежняя", "trigger", trigger, "ошибка", err)
+		return err
+	}
+
+	hot := false
+	for _, c := range changes {
+		hot = hot || c.Hot
+	}
+	if hot {
+		r.server.Reload(cfg.BalancerDomain, cfg.CDNHost, serverOptions(cfg))
+	}
+	logs.SetRedactor(redactor)
+	if cfg.LogLevel != r.current.LogLevel {
+		_ = logs.SetLevel("", cfg.LogLevel, 0)
+	}
+	if !reflect.DeepEqual(cachePolicies(cfg), cachePolicies(r.current)) {
+		cache.SetPolicies(cfg.CacheTTL, cachePolicies(cfg))
+	}
+
+	for _, c := range changes {
+		if c.Hot {
+			slog.Info("Параметр конфигурации изменён", "параметр", c.Key, "было", c.Old, "стало", c.New)
+		}
+	}
+	r.status.RestartRequired = r.status.RestartRequired[:0]
+	for _, c := range config.Diff(r.initial, cfg) {
+		if !c.Hot {
+			r.status.RestartRequired = append(r.status.RestartRequired, c.Key)
+		}
+	}
+	if len(r.status.RestartRequired) > 0 {
+		slog.Warn("Часть изменённых параметров применится только после перезапуска", "параметры", r.status.RestartRequired)
+	}
+
+	r.current = cfg
+	r.status.Generation++
+	r.status.LoadedAt = time.Now()
+	slog.Info("Конфигурация перезагружена", "trigger", trigger, "generation", r.status.Generation, "changes", len(changes))
+	return nil
+}
+
+// Сборка конфигурации с перехватом паники, чтобы ошибка перезагрузки не останавливала сервис
+func (r *reloader) load() (cfg *config.Config, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("паника при загрузке конфигурации: %v", p)
+		}
+	}()
+	return r.loader.Load(context.Background())
+}
+
+// Status возвращает состояние перезагрузки
+func (r *reloader) Status() reloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.RestartRequired = append([]string(nil), r.status.RestartRequired...)
+	return status
+}
+
+// HTTPHandler отдаёт состояние перезагрузки (GET) и перезагружает конфигурацию (POST)
+func (r *reloader) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		code := http.StatusOK
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := r.reload("http"); err != nil {
+				code = http.StatusBadRequest
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(r.Status())
+	})
+}
@@ -0,0 +1,223 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"videobalance/internal/logs"
+)
+
+const flushInterval = 1 * time.Second // Период сброса буфера на диск
+
+// Format — формат строк журнала доступа
+type Format string
+
+const (
+	FormatJSON Format = "json" // Одна JSON-строка на запрос
+	FormatText Format = "text" // Компактный формат key=value
+)
+
+// Вид обращения к кэшу
+const (
+	CacheHit      = "hit"
+	CacheMiss     = "miss"
+	CacheStale    = "stale"
+	CacheNegative = "negative"
+)
+
+// Options задаёт вывод журнала доступа
+type Options struct {
+	Output         string        // stdout, stderr или путь к файлу
+	Format         Format        // json (по умолчанию) или text
+	MaxSize        int64         // Размер файла в байтах для ротации (0 — без ограничения)
+	RotateInterval time.Duration // Период ротации файла (0 — без ротации по времени)
+	MaxBackups     int           // Количество хранимых ротированных файлов (0 — хранить все)
+	Compress       bool          // Сжимать ротированные файлы gzip
+}
+
+// Record — одна запись журнала доступа для вызова Redirect
+type Record struct {
+	RequestID string
+	Client    string
+	Video     string
+	Server    string // Origin-сервер из URL (например, s1)
+	Path      string // Путь из URL
+	Priority  string // Приоритет запроса при ограничении нагрузки
+	Backend   string // Выбранный бэкенд: cdn или origin
+	Target    string // URL, на который перенаправлен клиент
+	Reason    string // Причина решения
+	Cache     string // hit, miss, stale или negative
+	Latency   time.Duration
+	Error     string
+}
+
+// Logger пишет по одной строке на запрос. Вывод буферизуется и сбрасывается
+// раз в flushInterval и при закрытии.
+type Logger struct {
+	logger *slog.Logger
+	out    *bufferedWriter
+	closer io.Closer
+}
+
+// New создаёт журнал доступа
+func New(opts Options) (*Logger, error) {
+	var (
+		w      io.Writer
+		closer io.Closer
+	)
+	switch opts.Output {
+	case "", "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := OpenRotatingFile(RotateOptions{
+			Path:       opts.Output,
+			MaxSize:    opts.MaxSize,
+			Interval:   opts.RotateInterval,
+			MaxBackups: opts.MaxBackups,
+			Compress:   opts.Compress,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть журнал доступа: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	out := newBufferedWriter(w)
+	handlerOpts := &slog.HandlerOptions{ReplaceAttr: dropLevelAndMessage}
+
+	var handler slog.Handler
+	switch opts.Format {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	case FormatText:
+		handler = slog.NewTextHandler(out, handlerOpts)
+	default:
+		out.Close()
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("неизвестный формат журнала доступа: %q", opts.Format)
+	}
+
+	// URL запросов содержат подписи и идентификаторы пользователей
+	handler = logs.NewRedactingHandler(handler)
+
+	return &Logger{logger: slog.New(handler), out: out, closer: closer}, nil
+}
+
+// Уровень и сообщение одинаковы для всех записей, поэтому не выводятся
+func dropLevelAndMessage(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// Log записывает запись. Для nil журнала ничего не делает.
+func (l *Logger) Log(r Record) {
+	if l == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", r.RequestID),
+		slog.String("client", r.Client),
+		slog.String("video", r.Video),
+		slog.String("server", r.Server),
+		slog.String("path", r.Path),
+		slog.String("priority", r.Priority),
+		slog.String("backend", r.Backend),
+		slog.String("target", r.Target),
+		slog.String("reason", r.Reason),
+		slog.String("cache", r.Cache),
+		slog.Float64("latency_ms", float64(r.Latency.Microseconds())/1000),
+	}
+	if r.Error != "" {
+		attrs = append(attrs, slog.String("error", r.Error))
+	}
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, "", attrs...)
+}
+
+// Close сбрасывает буфер и закрывает файл
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	err := l.out.Close()
+	if l.closer != nil {
+		if cerr := l.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// bufferedWriter накапливает строки в памяти и периодически сбрасывает их,
+// чтобы не делать системный вызов на каждый запрос
+type bufferedWriter struct {
+	mu   sync.Mutex
+	bw   *bufio.Writer
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newBufferedWriter(w io.Writer) *bufferedWriter {
+	b := &bufferedWriter{
+		bw:   bufio.NewWriterSize(w, 64*1024),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.flushLoop()
+	return b
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Каждый вызов — целая строка. Буфер сбрасывается до неё, а не посередине,
+	// чтобы ротация файла не разрезала запись
+	if len(p) > b.bw.Available() && b.bw.Buffered() > 0 {
+		if err := b.bw.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return b.bw.Write(p)
+}
+
+func (b *bufferedWriter) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bw.Flush()
+}
+
+func (b *bufferedWriter) flushLoop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.flush(); err != nil {
+				slog.Error("Не удалось записать журнал доступа", "error", err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *bufferedWriter) Close() error {
+	close(b.stop)
+	<-b.done
+	return b.flush()
+}
@@ -0,0 +1,180 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"videobalance/internal/logs"
+)
+
+var record = Record{
+	RequestID: "req-1",
+	Client:    "10.0.0.1",
+	Video:     "http://s1.origin-cluster/video/1.m3u8?token=secret",
+	Server:    "s1",
+	Path:      "/video/1.m3u8",
+	Priority:  "normal",
+	Backend:   "cdn",
+	Target:    "http://cdn.example.com/s1/video/1.m3u8",
+	Reason:    "popular",
+	Cache:     CacheHit,
+	Latency:   1500 * time.Microsecond,
+}
+
+func TestLogFormat(t *testing.T) {
+	withError := record
+	withError.Error = "origin недоступен"
+
+	for _, tc := range []struct {
+		name   string
+		format Format
+		record Record
+		check  func(t *testing.T, line string)
+	}{
+		{
+			name:   "json",
+			record: record,
+			check: func(t *testing.T, line string) {
+				var got map[string]any
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatal(err)
+				}
+				want := map[string]any{
+					"request_id": "req-1", "client": "10.0.0.1", "server": "s1", "path": "/video/1.m3u8",
+					"priority": "normal", "backend": "cdn", "target": "http://cdn.example.com/s1/video/1.m3u8",
+					"reason": "popular", "cache": "hit", "latency_ms": 1.5,
+					"video": "http://s1.origin-cluster/video/1.m3u8?token=" + logs.Redacted,
+				}
+				for key, value := range want {
+					if got[key] != value {
+						t.Errorf("%s = %v, ожидается %v", key, got[key], value)
+					}
+				}
+				for _, key := range []string{"level", "msg", "error"} {
+					if _, ok := got[key]; ok {
+						t.Errorf("лишнее поле %s", key)
+					}
+				}
+			},
+		},
+		{
+			name:   "text с ошибкой",
+			format: FormatText,
+			record: withError,
+			check: func(t *testing.T, line string) {
+				for _, part := range []string{"request_id=req-1", "cache=hit", "latency_ms=1.5", `error="origin недоступен"`, "token=" + logs.Redacted} {
+					if !strings.Contains(line, part) {
+						t.Errorf("нет %s в %q", part, line)
+					}
+				}
+				if strings.Contains(line, "level=") || strings.Contains(line, "msg=") || strings.Contains(line, "secret") {
+					t.Errorf("лишние поля в %q", line)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			l, err := New(Options{Output: path, Format: tc.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.Log(tc.record)
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(readFile(t, path), "\n"), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("строк %d, ожидается 1: %q", len(lines), lines)
+			}
+			tc.check(t, lines[0])
+		})
+	}
+}
+
+// Записи копятся в буфере и попадают на диск по таймеру без закрытия журнала
+func TestLogBufferedFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := New(Options{Output: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.Log(record)
+	if got := readFile(t, path); got != "" {
+		t.Fatalf("запись на диске до сброса буфера: %q", got)
+	}
+
+	deadline := time.Now().Add(3 * flushInterval)
+	for readFile(t, path) == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("буфер не сброшен по таймеру")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Журнал в файле ротируется по размеру; строки не теряются и не разрываются
+func TestLogRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	l, err := New(Options{Output: path, MaxSize: 1024, MaxBackups: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 1000
+	for i := 0; i < n; i++ {
+		l.Log(record)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := backups(t, dir, path)
+	if len(files) == 0 {
+		t.Fatal("файл не ротирован")
+	}
+	total := 0
+	for _, data := range append(slices.Collect(maps.Values(files)), readFile(t, path)) {
+		for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("некорректная строка %q", line)
+			}
+			total++
+		}
+	}
+	if total != n {
+		t.Errorf("строк во всех файлах %d, ожидается %d", total, n)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	if _, err := New(Options{Output: path, Format: "xml"}); err == nil {
+		t.Fatal("неизвестный формат принят")
+	}
+
+	// Путь занят файлом, каталог создать нельзя
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{Output: filepath.Join(dir, "file", "access.log")}); err == nil {
+		t.Fatal("журнал открыт в несуществующем каталоге")
+	}
+
+	// Пустой журнал ничего не делает
+	var l *Logger
+	l.Log(record)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,265 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatedTimeFormat = "20060102T150405.000" // Формат метки времени в имени ротированного файла
+
+// RotateOptions задаёт правила ротации файла
+type RotateOptions struct {
+	Path       string        // Путь к текущему файлу
+	MaxSize    int64         // Размер в байтах, после которого файл ротируется (0 — без ограничения)
+	Interval   time.Duration // Период ротации по времени (0 — без ротации по времени)
+	MaxBackups int           // Количество хранимых ротированных файлов (0 — хранить все)
+	Compress   bool          // Сжимать ротированные файлы gzip
+}
+
+// RotatingFile — файл, который ротируется по размеру и/или времени.
+// Ротированные файлы получают метку времени в имени, а сжатие и удаление старых
+// файлов выполняются в фоне одной горутиной в порядке ротации.
+type RotatingFile struct {
+	opts RotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	rotated   chan string // Ротированные файлы, ожидающие сжатия и очистки
+	closeOnce sync.Once
+	done      chan struct{} // Закрывается после завершения фоновой горутины
+}
+
+// OpenRotatingFile открывает файл для дозаписи, создавая каталоги при необходимости
+func OpenRotatingFile(opts RotateOptions) (*RotatingFile, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("не указан путь к файлу")
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &RotatingFile{
+		opts:    opts,
+		rotated: make(chan string, 64),
+		done:    make(chan struct{}),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	go r.maintain()
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.file = f
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+// Write дописывает данные, предварительно ротируя файл, если это требуется
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.needsRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) needsRotate(next int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size+next > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.Interval > 0 && time.Since(r.openedAt) >= r.opts.Interval
+}
+
+// Переименование текущего файла и открытие нового. Вызывается под mu.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	rotated := r.rotatedName(time.Now())
+	if err := os.Rename(r.opts.Path, rotated); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.rotated <- rotated
+	return nil
+}
+
+// Сжатие ротированных файлов и удаление старых в порядке ротации
+func (r *RotatingFile) maintain() {
+	defer close(r.done)
+
+	for rotated := range r.rotated {
+		if r.opts.Compress {
+			// Файл мог быть уже удалён как лишний при очистке после предыдущей ротации
+			if err := compressFile(rotated); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Error("Не удалось сжать ротированный файл", "file", rotated, "error", err)
+			}
+		}
+		r.removeOldBackups()
+	}
+}
+
+// Имя ротированного файла: access.log → access-20240101T000000.000.log.
+// При совпадении метки времени к имени добавляется порядковый номер.
+func (r *RotatingFile) rotatedName(t time.Time) string {
+	ext := filepath.Ext(r.opts.Path)
+	base := strings.TrimSuffix(r.opts.Path, ext)
+	stamp := t.Format(rotatedTimeFormat)
+
+	name := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+	return name
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// Удаление самых старых ротированных файлов сверх MaxBackups
+func (r *RotatingFile) removeOldBackups() {
+	if r.opts.MaxBackups <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(r.opts.Path)
+	base := strings.TrimSuffix(r.opts.Path, ext)
+	matches, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		name  string
+		stamp string
+		seq   int
+	}
+	var backups []backup
+	for _, m := range matches {
+		rest := strings.TrimSuffix(strings.TrimPrefix(m, base+"-"), ".gz")
+		if !strings.HasSuffix(rest, ext) {
+			continue
+		}
+		rest = strings.TrimSuffix(rest, ext)
+
+		// Метка времени имеет фиксированную длину, за ней может идти порядковый номер
+		if len(rest) < len(rotatedTimeFormat) {
+			continue
+		}
+		b := backup{name: m, stamp: rest[:len(rotatedTimeFormat)]}
+		if suffix := rest[len(rotatedTimeFormat):]; suffix != "" {
+			seq, err := strconv.Atoi(strings.TrimPrefix(suffix, "."))
+			if err != nil || suffix[0] != '.' {
+				continue
+			}
+			b.seq = seq
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].seq < backups[j].seq
+	})
+
+	for len(backups) > r.opts.MaxBackups {
+		if err := os.Remove(backups[0].name); err != nil && !os.IsNotExist(err) {
+			slog.Error("Не удалось удалить старый файл лога", "file", backups[0].name, "error", err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// Сжатие файла в name.gz с удалением исходного файла
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// Close закрывает файл и дожидается завершения фонового сжатия
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.closeOnce.Do(func() { close(r.rotated) })
+	<-r.done
+	return err
+}
@@ -0,0 +1,266 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Ротированные файлы каталога: имя → содержимое (сжатые распаковываются)
+func backups(t *testing.T, dir, current string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		if name == current {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			r = gz
+		}
+		data, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		files[e.Name()] = string(data)
+	}
+	return files
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func write(t *testing.T, r *RotatingFile, s string) {
+	t.Helper()
+	if _, err := r.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     RotateOptions
+		writes   []string
+		existing string // Содержимое файла до открытия
+		current  string
+		rotated  []string // Содержимое сохранённых ротированных файлов
+	}{
+		{name: "без ротации", opts: RotateOptions{MaxSize: 100}, writes: []string{"a\n", "b\n"}, current: "a\nb\n"},
+		{name: "без ограничения", writes: []string{strings.Repeat("x", 1000)}, current: strings.Repeat("x", 1000)},
+		{
+			name:    "по размеру",
+			opts:    RotateOptions{MaxSize: 10},
+			writes:  []string{"line1\n", "line2\n", "line3\n", "line4\n"},
+			current: "line4\n",
+			rotated: []string{"line1\n", "line2\n", "line3\n"},
+		},
+		{
+			name:    "запись больше MaxSize в пустой файл",
+			opts:    RotateOptions{MaxSize: 4},
+			writes:  []string{"длинная строка\n", "x\n"},
+			current: "x\n",
+			rotated: []string{"длинная строка\n"},
+		},
+		{
+			name:     "размер существующего файла учитывается",
+			opts:     RotateOptions{MaxSize: 10},
+			existing: "previous\n",
+			writes:   []string{"next\n"},
+			current:  "next\n",
+			rotated:  []string{"previous\n"},
+		},
+		{
+			name:     "дозапись в существующий файл",
+			opts:     RotateOptions{MaxSize: 100},
+			existing: "previous\n",
+			writes:   []string{"next\n"},
+			current:  "previous\nnext\n",
+		},
+		{
+			name:    "хранятся последние MaxBackups",
+			opts:    RotateOptions{MaxSize: 6, MaxBackups: 2},
+			writes:  []string{"l1\nl1\n", "l2\nl2\n", "l3\nl3\n", "l4\nl4\n", "l5\nl5\n"},
+			current: "l5\nl5\n",
+			rotated: []string{"l3\nl3\n", "l4\nl4\n"},
+		},
+		{
+			name:    "сжатие",
+			opts:    RotateOptions{MaxSize: 6, MaxBackups: 2, Compress: true},
+			writes:  []string{"l1\nl1\n", "l2\nl2\n", "l3\nl3\n", "l4\nl4\n"},
+			current: "l4\nl4\n",
+			rotated: []string{"l2\nl2\n", "l3\nl3\n"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "logs", "nested")
+			path := filepath.Join(dir, "access.log")
+			if tc.existing != "" {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tc.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := tc.opts
+			opts.Path = path
+			r, err := OpenRotatingFile(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.writes {
+				write(t, r, s)
+			}
+			// Close дожидается фонового сжатия и очистки
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFile(t, path); got != tc.current {
+				t.Errorf("текущий файл %q, ожидается %q", got, tc.current)
+			}
+			files := backups(t, dir, path)
+			var got []string
+			for name, data := range files {
+				if strings.HasSuffix(name, ".gz") != tc.opts.Compress {
+					t.Errorf("%s: сжатие %v, ожидается %v", name, !tc.opts.Compress, tc.opts.Compress)
+				}
+				if !strings.HasPrefix(name, "access-") {
+					t.Errorf("неожиданное имя ротированного файла %s", name)
+				}
+				got = append(got, data)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tc.rotated) {
+				t.Errorf("ротированные файлы %q, ожидается %q", got, tc.rotated)
+			}
+		})
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	r, err := OpenRotatingFile(RotateOptions{Path: path, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	age := func() {
+		r.mu.Lock()
+		r.openedAt = r.openedAt.Add(-time.Hour)
+		r.mu.Unlock()
+	}
+
+	// Пустой файл не ротируется, даже если интервал прошёл
+	age()
+	write(t, r, "first\n")
+	if n := len(backups(t, dir, path)); n != 0 {
+		t.Fatalf("ротирован пустой файл: %d", n)
+	}
+
+	// Непустой файл ротируется, новый открывается с новым отсчётом
+	write(t, r, "second\n")
+	write(t, r, "third\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, path); got != "second\nthird\n" {
+		t.Errorf("текущий файл %q", got)
+	}
+	files := backups(t, dir, path)
+	if len(files) != 1 {
+		t.Fatalf("ротированные файлы %v, ожидается один", files)
+	}
+	for name, data := range files {
+		if data != "first\n" {
+			t.Errorf("%s: %q", name, data)
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "access-"), ".log")
+		if _, err := time.Parse(rotatedTimeFormat, stamp); err != nil {
+			t.Errorf("метка времени в имени %s: %v", name, err)
+		}
+	}
+}
+
+// Ротации с одинаковой меткой времени получают порядковые номера, и очистка их учитывает
+func TestRotatedNameSequence(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{opts: RotateOptions{Path: filepath.Join(dir, "access.log"), MaxBackups: 2}}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := r.rotatedName(at)
+		if i == 1 {
+			// Сжатый файл тоже занимает имя
+			name += ".gz"
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Base(name))
+	}
+	want := []string{"access-20240102T030405.006.log", "access-20240102T030405.006.1.log.gz", "access-20240102T030405.006.2.log", "access-20240102T030405.006.3.log"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("имена %v, ожидается %v", names, want)
+	}
+
+	r.removeOldBackups()
+	var left []string
+	for name := range backups(t, dir, "") {
+		left = append(left, name)
+	}
+	slices.Sort(left)
+	if !slices.Equal(left, want[2:]) {
+		t.Errorf("после очистки %v, ожидается %v", left, want[2:])
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	if _, err := OpenRotatingFile(RotateOptions{}); err == nil {
+		t.Fatal("открыт файл без пути")
+	}
+
+	r, err := OpenRotatingFile(RotateOptions{Path: filepath.Join(t.TempDir(), "access.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write после Close = %v, ожидается %v", err, os.ErrClosed)
+	}
+	// Повторный Close не паникует
+	if err := r.Close(); err != nil {
+		t.Errorf("повторный Close = %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package apierr описывает ошибки, которые видят клиенты балансировщика, и их перевод
+// в статусы gRPC с подробностями errdetails.
+package apierr
+
+import (
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain — домен ошибок в ErrorInfo
+const Domain = "videobalance"
+
+// Ошибки для клиентов. Обработчики возвращают их обёрнутыми через %w или в *Error
+// с подробностями, а Status переводит их в статус gRPC.
+var (
+	ErrBadURL           = errors.New("некорректный URL видео")                     // INVALID_ARGUMENT
+	ErrUnknownOrigin    = errors.New("неизвестный origin-сервер")                  // NOT_FOUND
+	ErrOverloaded       = errors.New("сервер перегружен")                          // RESOURCE_EXHAUSTED
+	ErrNoHealthyBackend = errors.New("нет доступного сервера для перенаправления") // UNAVAILABLE
+	ErrForbidden        = errors.New("доступ запрещён")                            // PERMISSION_DENIED
+)
+
+// Код gRPC и причина ErrorInfo для каждой ошибки
+var kinds = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{ErrBadURL, codes.InvalidArgument, "BAD_URL"},
+	{ErrUnknownOrigin, codes.NotFound, "UNKNOWN_ORIGIN"},
+	{ErrOverloaded, codes.ResourceExhausted, "OVERLOADED"},
+	{ErrNoHealthyBackend, codes.Unavailable, "NO_HEALTHY_BACKEND"},
+	{ErrForbidden, codes.PermissionDenied, "FORBIDDEN"},
+}
+
+// Error дополняет ошибку сведениями, которые клиент получает в errdetails
+type Error struct {
+	Err        error             // Ошибка, в цепочке которой есть одна из Err*
+	Field      string            // Поле запроса с неверным значением — для BadRequest
+	RetryAfter time.Duration     // Через сколько повторить запрос — для RetryInfo
+	Metadata   map[string]string // Сведения для ErrorInfo
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Status переводит ошибку в статус gRPC. Ошибки Err* получают свой код и ErrorInfo,
+// а переданные в *Error — также BadRequest и RetryInfo. Статусы gRPC и ошибки контекста
+// сохраняют свой код, остальные ошибки становятся UNKNOWN.
+func Status(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return withDetails(status.New(k.code, err.Error()), k.reason, err)
+		}
+	}
+	return status.FromContextError(err)
+}
+
+func withDetails(st *status.Status, reason string, err error) *status.Status {
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: Domain}
+	details := []protoadapt.MessageV1{info}
+
+	var e *Error
+	if errors.As(err, &e) {
+		info.Metadata = e.Metadata
+		if e.Field != "" {
+			details = append(details, &errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: e.Field, Description: err.Error()}},
+			})
+		}
+		if e.RetryAfter > 0 {
+			details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+		}
+	}
+
+	if detailed, err := st.WithDetails(details...); err == nil {
+		return detailed
+	}
+	return st
+}
@@ -0,0 +1,122 @@
+package apierr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		code     codes.Code
+		msg      string
+		reason   string            // Причина ErrorInfo; пусто — подробностей нет
+		metadata map[string]string // Metadata в ErrorInfo
+		extra    []proto.Message   // Подробности после ErrorInfo
+	}{
+		{name: "nil", err: nil, code: codes.OK},
+		{name: "некорректный URL", err: ErrBadURL, code: codes.InvalidArgument, msg: ErrBadURL.Error(), reason: "BAD_URL"},
+		{name: "неизвестный origin", err: ErrUnknownOrigin, code: codes.NotFound, msg: ErrUnknownOrigin.Error(), reason: "UNKNOWN_ORIGIN"},
+		{name: "перегрузка", err: ErrOverloaded, code: codes.ResourceExhausted, msg: ErrOverloaded.Error(), reason: "OVERLOADED"},
+		{name: "нет бэкенда", err: ErrNoHealthyBackend, code: codes.Unavailable, msg: ErrNoHealthyBackend.Error(), reason: "NO_HEALTHY_BACKEND"},
+		{name: "доступ запрещён", err: ErrForbidden, code: codes.PermissionDenied, msg: ErrForbidden.Error(), reason: "FORBIDDEN"},
+		{
+			name:   "обёрнутая через %w",
+			err:    fmt.Errorf("s9: %w", ErrUnknownOrigin),
+			code:   codes.NotFound,
+			msg:    "s9: " + ErrUnknownOrigin.Error(),
+			reason: "UNKNOWN_ORIGIN",
+		},
+		{
+			name:     "BadRequest из Error",
+			err:      &Error{Err: fmt.Errorf("схема ftp: %w", ErrBadURL), Field: "video_url", Metadata: map[string]string{"scheme": "ftp"}},
+			code:     codes.InvalidArgument,
+			msg:      "схема ftp: " + ErrBadURL.Error(),
+			reason:   "BAD_URL",
+			metadata: map[string]string{"scheme": "ftp"},
+			extra: []proto.Message{&errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "video_url", Description: "схема ftp: " + ErrBadURL.Error()}},
+			}},
+		},
+		{
+			name:   "RetryInfo из Error",
+			err:    &Error{Err: ErrOverloaded, RetryAfter: 250 * time.Millisecond},
+			code:   codes.ResourceExhausted,
+			msg:    ErrOverloaded.Error(),
+			reason: "OVERLOADED",
+			extra:  []proto.Message{&errdetails.RetryInfo{RetryDelay: durationpb.New(250 * time.Millisecond)}},
+		},
+		{
+			name:   "Error, обёрнутая через %w",
+			err:    fmt.Errorf("запрос: %w", &Error{Err: ErrOverloaded, Field: "priority", RetryAfter: time.Second}),
+			code:   codes.ResourceExhausted,
+			msg:    "запрос: " + ErrOverloaded.Error(),
+			reason: "OVERLOADED",
+			extra: []proto.Message{
+				&errdetails.BadRequest{
+					FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "priority", Description: "запрос: " + ErrOverloaded.Error()}},
+				},
+				&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)},
+			},
+		},
+		{name: "Error без Err*", err: &Error{Err: errors.New("сбой"), Field: "video_url"}, code: codes.Unknown, msg: "сбой"},
+		{name: "статус gRPC", err: status.Error(codes.Aborted, "прервано"), code: codes.Aborted, msg: "прервано"},
+		{
+			name: "обёрнутый статус gRPC",
+			err:  fmt.Errorf("вызов: %w", status.Error(codes.FailedPrecondition, "нет")),
+			code: codes.FailedPrecondition,
+			msg:  "вызов: rpc error: code = FailedPrecondition desc = нет",
+		},
+		{name: "отмена", err: context.Canceled, code: codes.Canceled, msg: context.Canceled.Error()},
+		{name: "таймаут", err: context.DeadlineExceeded, code: codes.DeadlineExceeded, msg: context.DeadlineExceeded.Error()},
+		{
+			name: "обёрнутый таймаут",
+			err:  fmt.Errorf("origin: %w", context.DeadlineExceeded),
+			code: codes.DeadlineExceeded,
+			msg:  "origin: " + context.DeadlineExceeded.Error(),
+		},
+		{name: "прочая ошибка", err: errors.New("сбой"), code: codes.Unknown, msg: "сбой"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			st := Status(tc.err)
+			if st.Code() != tc.code || st.Message() != tc.msg {
+				t.Fatalf("Status() = %v %q, ожидается %v %q", st.Code(), st.Message(), tc.code, tc.msg)
+			}
+			if tc.err == nil && st.Err() != nil {
+				t.Fatalf("Status(nil).Err() = %v", st.Err())
+			}
+
+			details := st.Details()
+			if tc.reason == "" {
+				if len(details) != 0 {
+					t.Fatalf("лишние подробности %v", details)
+				}
+				return
+			}
+			if len(details) != 1+len(tc.extra) {
+				t.Fatalf("подробностей %d, ожидается %d: %v", len(details), 1+len(tc.extra), details)
+			}
+			info, ok := details[0].(*errdetails.ErrorInfo)
+			want := &errdetails.ErrorInfo{Reason: tc.reason, Domain: Domain, Metadata: tc.metadata}
+			if !ok || !proto.Equal(info, want) {
+				t.Errorf("ErrorInfo = %v, ожидается %v", details[0], want)
+			}
+			for i, w := range tc.extra {
+				got, ok := details[1+i].(proto.Message)
+				if !ok || !proto.Equal(got, w) {
+					t.Errorf("подробность %d = %v, ожидается %v", i+1, details[1+i], w)
+				}
+			}
+		})
+	}
+}
@@ -1,108 +1,434 @@
 package cache
 
 import (
-	"fmt"
-	lru "github.com/hashicorp/golang-lru"
-	"hash/fnv"
-	"log/slog"
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+	"videobalance/internal/logs"
+	"videobalance/internal/popularity"
 )
 
 const (
 	cacheTTL                = 10 * time.Minute // Время жизни кэша (TTL)
 	maxCacheSize            = 5000             // Максимальный размер кэша
-	cacheGCInterval         = 5 * time.Minute  // Интервал для очистки кэша
-	frequentAccessThreshold = 100              // Порог запросов, после которого URL остаётся дольше в кэше
+	frequentAccessThreshold = 100              // Порог популярности, после которого URL остаётся дольше в кэше
+	defaultShardCount       = 64               // Количество шардов по умолчанию (степень двойки)
+	defaultNegativeTTL      = 30 * time.Second // Время жизни негативной записи по умолчанию
+	negativeShare           = 4                // Негативные записи занимают не больше 1/negativeShare ёмкости шарда
+
+	// CleanInterval — период очистки кэша от устаревших записей для CleanExpired
+	CleanInterval = 5 * time.Minute
 )
 
 var (
-	UrlCache         *lru.Cache           // LRU кэш для хранения URL
-	cacheTimestamp   map[string]time.Time // Карта для хранения времени для каждого ключа
-	cacheAccessCount map[string]int       // Карта для подсчёта количества запросов на видео
-	cacheMutex       sync.RWMutex         // Мьютекс для синхронизации доступа
-	CleanerStop      chan struct{}        // Канал для остановки очистки
+	defaultCache = New(Options{}) // Кэш по умолчанию, используемый функциями пакета
+
+	logger = logs.For("cache") // Логгер пакета с отдельно настраиваемым уровнем
 )
 
-type CacheEntry struct {
-	URL       string
-	Timestamp time.Time
+// Options задаёт параметры кэша. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Size   int           // Суммарная ёмкость кэша по всем шардам; негативные записи занимают до Size/4 сверх неё
+	TTL    time.Duration // Время жизни записи
+	Shards int           // Количество шардов, округляется вверх до степени двойки
+
+	// Policies переопределяет окна кэширования для отдельных видов контента.
+	// Для видов, не указанных здесь, используется DefaultPolicies с TTL из поля TTL.
+	Policies map[Kind]Policy
+}
+
+// Stats содержит накопленную статистику обращений к кэшу
+type Stats struct {
+	Hits         uint64
+	StaleHits    uint64
+	NegativeHits uint64
+	Misses       uint64
+	Evictions    uint64
+	Len          int
+}
+
+// Cache — шардированный LRU-кэш. Каждый шард защищён собственным мьютексом,
+// а счётчики обращений атомарные, поэтому чтения разных ключей не конкурируют за одну блокировку.
+type Cache struct {
+	shards   []*shard
+	mask     uint64
+	policies atomic.Pointer[map[Kind]Policy]
+}
+
+// Негативные записи хранятся в отдельном LRU меньшего размера, чтобы поток
+// некорректных URL не вытеснял из шарда маршруты корректных.
+type shard struct {
+	mu       sync.Mutex
+	lru      *simplelru.LRU
+	negative *simplelru.LRU
+
+	hits         atomic.Uint64
+	staleHits    atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+
+	_ [8]byte // Выравнивание до 64 байт, чтобы счётчики соседних шардов не делили строку кэша
+}
+
+// entry — запись кэша. Поля изменяются атомарно без захвата блокировки шарда.
+type entry struct {
+	url        string
+	kind       Kind
+	negative   bool         // URL был отклонён при разборе
+	err        error        // Ошибка разбора отклонённого URL
+	expiresAt  atomic.Int64 // Время истечения в наносекундах Unix
+	staleUntil atomic.Int64 // До этого момента запись можно отдавать устаревшей
+	refreshing atomic.Bool  // Фоновое обновление уже запущено
+}
+
+// LookupResult описывает результат поиска в кэше
+type LookupResult struct {
+	URL        string
+	Found      bool  // Найдена пригодная запись (свежая, устаревшая в окне grace или негативная)
+	Stale      bool  // Запись устарела, но ещё в пределах окна grace
+	Revalidate bool  // Вызывающий должен обновить запись в фоне; флаг выдаётся только одному вызывающему
+	Negative   bool  // URL недавно отклонён, повторно разбирать его не нужно
+	Err        error // Для негативной записи — ошибка, с которой URL был отклонён
 }
 
-// Инициализация и очистка кэша по TTL
-func init() {
-	var err error
-	UrlCache, err = lru.New(maxCacheSize)
+// New создаёт шардированный кэш
+func New(opts Options) *Cache {
+	if opts.Size <= 0 {
+		opts.Size = maxCacheSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = cacheTTL
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = defaultShardCount
+	}
+	shardCount := 1
+	for shardCount < opts.Shards {
+		shardCount <<= 1
+	}
+
+	// Ёмкость делится между шардами, но каждый шард хранит хотя бы одну запись
+	perShard := (opts.Size + shardCount - 1) / shardCount
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	c := &Cache{
+		shards: make([]*shard, shardCount),
+		mask:   uint64(shardCount - 1),
+	}
+	c.SetPolicies(opts.TTL, opts.Policies)
+	for i := range c.shards {
+		c.shards[i] = &shard{lru: newLRU(perShard), negative: newLRU(max(perShard/negativeShare, 1))}
+	}
+	return c
+}
+
+func newLRU(size int) *simplelru.LRU {
+	l, err := simplelru.NewLRU(size, nil)
 	if err != nil {
-		slog.Info("Ошибка при создании кэша: %v", err)
+		// NewLRU возвращает ошибку только для неположительного размера
+		panic(err)
 	}
+	return l
+}
 
-	cacheTimestamp = make(map[string]time.Time)
-	cacheAccessCount = make(map[string]int)
+// Функция для хеширования видео URL (FNV-1a без аллокаций)
+func hashVideo(video string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(video); i++ {
+		h ^= uint64(video[i])
+		h *= prime64
+	}
+	return h
+}
 
-	// Инициализация канала для остановки очистки
-	CleanerStop = make(chan struct{})
+func (c *Cache) shardFor(video string) *shard {
+	return c.shards[hashVideo(video)&c.mask]
+}
 
-	// Запуск горутины для очистки устаревших данных
-	go startCacheCleaner()
+// SetPolicies атомарно заменяет окна кэширования. Уже сохранённые записи
+// сохраняют свои сроки, новые значения применяются к следующим записям.
+func (c *Cache) SetPolicies(ttl time.Duration, overrides map[Kind]Policy) {
+	if ttl <= 0 {
+		ttl = cacheTTL
+	}
+	policies := make(map[Kind]Policy, len(DefaultPolicies))
+	for kind, p := range DefaultPolicies {
+		p.TTL = ttl
+		policies[kind] = p
+	}
+	for kind, p := range overrides {
+		if p.TTL <= 0 {
+			p.TTL = ttl
+		}
+		policies[kind] = p
+	}
+	c.policies.Store(&policies)
 }
 
-// Функция для хеширования видео URL
-func hashVideo(video string) string {
-	h := fnv.New64a()
-	h.Write([]byte(video))
-	return fmt.Sprintf("%x", h.Sum(nil))
+func (c *Cache) policyFor(kind Kind) Policy {
+	policies := *c.policies.Load()
+	if p, ok := policies[kind]; ok {
+		return p
+	}
+	return policies[KindOther]
 }
 
-// Очистка кэша от устаревших записей
-func startCacheCleaner() {
-	ticker := time.NewTicker(cacheGCInterval)
-	defer ticker.Stop()
+// Get возвращает URL из кэша, если запись существует и не устарела
+func (c *Cache) Get(video string) (string, bool) {
+	res := c.Lookup(video)
+	if !res.Found || res.Stale || res.Negative {
+		return "", false
+	}
+	return res.URL, true
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			cleanExpiredCache()
-		case <-CleanerStop: // Ожидаем закрытия канала
-			slog.Info("Остановка очистки кэша.")
-			return
+// Lookup ищет запись в кэше. Устаревшая запись возвращается, пока не истекло окно grace;
+// первому вызывающему при этом выставляется Revalidate, чтобы обновление запускалось один раз.
+func (c *Cache) Lookup(video string) LookupResult {
+	s := c.shardFor(video)
+
+	s.mu.Lock()
+	value, found := s.lru.Get(video)
+	if !found {
+		value, found = s.negative.Get(video)
+	}
+	s.mu.Unlock()
+
+	if !found {
+		s.misses.Add(1)
+		return LookupResult{}
+	}
+
+	e := value.(*entry)
+	now := time.Now()
+	nowNano := now.UnixNano()
+
+	if e.negative {
+		if nowNano > e.expiresAt.Load() {
+			s.misses.Add(1)
+			return LookupResult{}
+		}
+		s.negativeHits.Add(1)
+		return LookupResult{Found: true, Negative: true, Err: e.err}
+	}
+
+	if nowNano > e.expiresAt.Load() {
+		if nowNano > e.staleUntil.Load() {
+			s.misses.Add(1)
+			return LookupResult{}
+		}
+		s.staleHits.Add(1)
+		return LookupResult{
+			URL:        e.url,
+			Found:      true,
+			Stale:      true,
+			Revalidate: e.refreshing.CompareAndSwap(false, true),
 		}
 	}
+
+	// Популярные URL остаются в кэше дольше
+	if popularity.Estimate(video) > frequentAccessThreshold {
+		p := c.policyFor(e.kind)
+		e.expiresAt.Store(now.Add(p.TTL).UnixNano())
+		e.staleUntil.Store(now.Add(p.TTL + p.StaleTTL).UnixNano())
+	}
+	s.hits.Add(1)
+	return LookupResult{URL: e.url, Found: true}
 }
 
-// Очистка устаревших записей из кэша
-func cleanExpiredCache() {
+// Set сохраняет URL в кэше
+func (c *Cache) Set(video, url string) {
+	kind := KindOf(video)
+	p := c.policyFor(kind)
 	now := time.Now()
 
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-
-	// Проходим по всем ключам и удаляем устаревшие записи
-	for key, timestamp := range cacheTimestamp {
-		if now.Sub(timestamp) > cacheTTL {
-			// Удаление устаревших элементов
-			UrlCache.Remove(key)
-			delete(cacheTimestamp, key)
-			delete(cacheAccessCount, key)
-			slog.Info("Удалена устаревшая запись из кэша: %v", key)
+	e := &entry{url: url, kind: kind}
+	e.expiresAt.Store(now.Add(p.TTL).UnixNano())
+	e.staleUntil.Store(now.Add(p.TTL + p.StaleTTL).UnixNano())
+	c.store(video, e)
+}
+
+// SetNegative запоминает, что URL был отклонён с ошибкой err. При нулевом NegativeTTL запись не создаётся.
+func (c *Cache) SetNegative(video string, err error) {
+	kind := KindOf(video)
+	p := c.policyFor(kind)
+	if p.NegativeTTL <= 0 {
+		return
+	}
+	deadline := time.Now().Add(p.NegativeTTL).UnixNano()
+
+	e := &entry{kind: kind, negative: true, err: err}
+	e.expiresAt.Store(deadline)
+	e.staleUntil.Store(deadline)
+	c.store(video, e)
+}
+
+// Запись попадает в LRU своего вида, а прежняя запись другого вида удаляется
+func (c *Cache) store(video string, e *entry) {
+	s := c.shardFor(video)
+	add, stale := s.lru, s.negative
+	if e.negative {
+		add, stale = s.negative, s.lru
+	}
+	s.mu.Lock()
+	stale.Remove(video)
+	evicted := add.Add(video, e)
+	s.mu.Unlock()
+
+	if evicted {
+		s.evictions.Add(1)
+	}
+}
+
+// RevalidateFailed снимает отметку о фоновом обновлении записи, выданную Lookup
+// через Revalidate, чтобы следующий запрос устаревшей записи повторил обновление.
+// Порядок записей в LRU не меняется.
+func (c *Cache) RevalidateFailed(video string) {
+	s := c.shardFor(video)
+	s.mu.Lock()
+	value, found := s.lru.Peek(video)
+	s.mu.Unlock()
+	if found {
+		value.(*entry).refreshing.Store(false)
+	}
+}
+
+// Remove удаляет запись из кэша
+func (c *Cache) Remove(video string) {
+	s := c.shardFor(video)
+	s.mu.Lock()
+	s.lru.Remove(video)
+	s.negative.Remove(video)
+	s.mu.Unlock()
+}
+
+// Purge удаляет все записи и возвращает их количество
+func (c *Cache) Purge() int {
+	removed := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		removed += s.lru.Len() + s.negative.Len()
+		s.lru.Purge()
+		s.negative.Purge()
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// Len возвращает количество записей во всех шардах
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.lru.Len() + s.negative.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats возвращает суммарную статистику по всем шардам
+func (c *Cache) Stats() Stats {
+	var st Stats
+	for _, s := range c.shards {
+		st.Hits += s.hits.Load()
+		st.StaleHits += s.staleHits.Load()
+		st.NegativeHits += s.negativeHits.Load()
+		st.Misses += s.misses.Load()
+		st.Evictions += s.evictions.Load()
+	}
+	st.Len = c.Len()
+	return st
+}
+
+// Configure заменяет кэш по умолчанию новым кэшем с параметрами opts.
+// Вызывается при запуске, до первого обращения к кэшу.
+func Configure(opts Options) {
+	defaultCache = New(opts)
+}
+
+// CleanExpired удаляет устаревшие записи и возвращает их количество.
+// Шарды обходятся по очереди, так что блокируется только один шард за раз.
+func (c *Cache) CleanExpired() int {
+	now := time.Now().UnixNano()
+	removed := 0
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+		removed += cleanExpired(s.lru, now) + cleanExpired(s.negative, now)
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+func cleanExpired(l *simplelru.LRU, now int64) int {
+	removed := 0
+	for _, key := range l.Keys() {
+		value, ok := l.Peek(key)
+		if !ok {
+			continue
+		}
+		if now > value.(*entry).staleUntil.Load() {
+			l.Remove(key)
+			removed++
 		}
 	}
+	return removed
+}
+
+// CleanExpired удаляет устаревшие записи из кэша по умолчанию. Вызывается планировщиком раз в CleanInterval.
+func CleanExpired(context.Context) error {
+	if removed := defaultCache.CleanExpired(); removed > 0 {
+		logger.Info("Удалены устаревшие записи из кэша", "количество", removed)
+	}
+	return nil
 }
 
 // Получение URL из кэша
 func GetFromCache(video string) (string, bool) {
-	cacheMutex.RLock()
-	defer cacheMutex.RUnlock()
+	return defaultCache.Get(video)
+}
 
-	hashedVideo := hashVideo(video)
-	entryInterface, found := UrlCache.Get(hashedVideo)
-	if !found || time.Since(entryInterface.(CacheEntry).Timestamp) > cacheTTL {
-		return "", false
-	}
+// Поиск в кэше с учётом устаревших и негативных записей
+func LookupInCache(video string) LookupResult {
+	return defaultCache.Lookup(video)
+}
 
-	cacheAccessCount[video]++
-	if cacheAccessCount[video] > frequentAccessThreshold {
-		cacheTimestamp[video] = time.Now().Add(cacheTTL)
-	}
-	return entryInterface.(CacheEntry).URL, true
+// Сохранение URL в кэше
+func AddToCache(video, url string) {
+	defaultCache.Set(video, url)
+}
+
+// Сохранение негативной записи для отклонённого URL
+func AddNegativeToCache(video string, err error) {
+	defaultCache.SetNegative(video, err)
+}
+
+// Повтор фонового обновления записи кэша по умолчанию после неудачной попытки
+func RevalidateFailed(video string) {
+	defaultCache.RevalidateFailed(video)
+}
+
+// Очистка кэша по умолчанию, например после смены CDN
+func Purge() int {
+	return defaultCache.Purge()
+}
+
+// Настройка окон кэширования для кэша по умолчанию
+func SetPolicies(ttl time.Duration, overrides map[Kind]Policy) {
+	defaultCache.SetPolicies(ttl, overrides)
+}
+
+// Статистика кэша по умолчанию
+func GetStats() Stats {
+	return defaultCache.Stats()
 }
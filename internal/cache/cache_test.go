@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Количества шардов для бенчмарков: 1 шард соответствует прежнему кэшу с общим мьютексом
+var benchShardCounts = []int{1, 4, 16, 64, 256}
+
+const benchKeys = 1 << 14
+
+func benchVideos() []string {
+	videos := make([]string, benchKeys)
+	for i := range videos {
+		videos[i] = "http://s" + strconv.Itoa(i%8) + ".origin-cluster/video/" + strconv.Itoa(i) + "/index.m3u8"
+	}
+	return videos
+}
+
+func BenchmarkLookup(b *testing.B) {
+	videos := benchVideos()
+	for _, shards := range benchShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := New(Options{Size: benchKeys, TTL: time.Hour, Shards: shards})
+			for _, v := range videos {
+				c.Set(v, v)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.Lookup(videos[i&(benchKeys-1)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	videos := benchVideos()
+	for _, shards := range benchShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := New(Options{Size: benchKeys / 2, TTL: time.Hour, Shards: shards})
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					v := videos[i&(benchKeys-1)]
+					c.Set(v, v)
+					i++
+				}
+			})
+		})
+	}
+}
+
+// Одновременные чтения, записи, негативные записи и очистка устаревших записей.
+// Проверяется под go test -race.
+func TestConcurrentAccess(t *testing.T) {
+	c := New(Options{Size: 256, Shards: 8, Policies: map[Kind]Policy{
+		KindManifest: {TTL: time.Millisecond, StaleTTL: time.Millisecond, NegativeTTL: time.Millisecond},
+		KindOther:    {TTL: time.Millisecond, NegativeTTL: time.Millisecond},
+	}})
+	errRejected := errors.New("отклонён")
+
+	const (
+		workers    = 8
+		iterations = 2000
+	)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				video := "http://s1.origin-cluster/video/" + strconv.Itoa((w*iterations+i)%512) + ".m3u8"
+				switch i % 4 {
+				case 0:
+					c.Set(video, "http://cdn/"+video)
+				case 1:
+					c.SetNegative(video+"?bad", errRejected)
+				case 2:
+					if res := c.Lookup(video); res.Found && !res.Negative && res.URL != "http://cdn/"+video {
+						t.Errorf("Lookup(%q) = %q", video, res.URL)
+					}
+				case 3:
+					if res := c.Lookup(video + "?bad"); res.Negative && !errors.Is(res.Err, errRejected) {
+						t.Errorf("негативная запись без ошибки: %v", res.Err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			c.CleanExpired()
+			c.Stats()
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	wg.Wait()
+
+	// После истечения всех окон очистка удаляет все записи
+	time.Sleep(5 * time.Millisecond)
+	c.CleanExpired()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("после очистки осталось %d записей", n)
+	}
+	st := c.Stats()
+	if st.Hits+st.StaleHits+st.NegativeHits+st.Misses != workers*iterations/2 {
+		t.Fatalf("обращений учтено %d, ожидалось %d", st.Hits+st.StaleHits+st.NegativeHits+st.Misses, workers*iterations/2)
+	}
+}
+
+func TestShardCountRoundedToPowerOfTwo(t *testing.T) {
+	for _, tc := range []struct{ shards, want int }{{1, 1}, {3, 4}, {64, 64}, {100, 128}} {
+		if got := len(New(Options{Shards: tc.shards}).shards); got != tc.want {
+			t.Errorf("Shards: %d → %d шардов, ожидалось %d", tc.shards, got, tc.want)
+		}
+	}
+}
+
+// Негативные записи вытесняют только друг друга и не занимают место маршрутов
+func TestNegativeEntriesSeparateLRU(t *testing.T) {
+	const size = 64
+	c := New(Options{Size: size, Shards: 1})
+	errRejected := errors.New("отклонён")
+	for i := 0; i < size; i++ {
+		c.Set("http://s1.origin-cluster/video/"+strconv.Itoa(i)+".m3u8", "http://cdn/"+strconv.Itoa(i))
+	}
+	for i := 0; i < 10*size; i++ {
+		c.SetNegative("http://junk/"+strconv.Itoa(i), errRejected)
+	}
+
+	for i := 0; i < size; i++ {
+		if _, ok := c.Get("http://s1.origin-cluster/video/" + strconv.Itoa(i) + ".m3u8"); !ok {
+			t.Fatalf("маршрут %d вытеснен негативными записями", i)
+		}
+	}
+	if n, want := c.Len(), size+size/negativeShare; n != want {
+		t.Fatalf("записей %d, ожидается %d", n, want)
+	}
+	// Последние негативные записи на месте, старые вытеснены
+	if res := c.Lookup("http://junk/" + strconv.Itoa(10*size-1)); !res.Negative || !errors.Is(res.Err, errRejected) {
+		t.Fatalf("последняя негативная запись: %+v", res)
+	}
+	if res := c.Lookup("http://junk/0"); res.Found {
+		t.Fatalf("старая негативная запись не вытеснена: %+v", res)
+	}
+}
+
+// Запись одного вида заменяет прежнюю запись другого вида для того же URL
+func TestNegativeEntryReplacement(t *testing.T) {
+	c := New(Options{Size: 16, Shards: 1})
+	const video = "http://s1.origin-cluster/video/1.m3u8"
+
+	c.SetNegative(video, errors.New("отклонён"))
+	c.Set(video, "http://cdn/1")
+	if res := c.Lookup(video); res.Negative || res.URL != "http://cdn/1" || c.Len() != 1 {
+		t.Fatalf("после Set: %+v, записей %d", res, c.Len())
+	}
+
+	c.SetNegative(video, errors.New("отклонён"))
+	if res := c.Lookup(video); !res.Negative || c.Len() != 1 {
+		t.Fatalf("после SetNegative: %+v, записей %d", res, c.Len())
+	}
+
+	c.Remove(video)
+	if res := c.Lookup(video); res.Found {
+		t.Fatalf("после Remove: %+v", res)
+	}
+}
+
+// Revalidate выдаётся один раз, пока обновление не завершилось неудачей
+func TestRevalidateFailed(t *testing.T) {
+	c := New(Options{Size: 16, Shards: 1, Policies: map[Kind]Policy{
+		KindManifest: {TTL: time.Nanosecond, StaleTTL: time.Hour},
+	}})
+	const video = "http://s1.origin-cluster/video/1.m3u8"
+	c.Set(video, "http://cdn/1")
+	time.Sleep(time.Millisecond)
+
+	for _, tc := range []struct {
+		name   string
+		failed bool // Перед поиском обновление завершилось неудачей
+		want   bool
+	}{
+		{name: "первый запрос обновляет", want: true},
+		{name: "обновление уже запущено"},
+		{name: "повтор после неудачи", failed: true, want: true},
+		{name: "повтор уже запущен"},
+	} {
+		if tc.failed {
+			c.RevalidateFailed(video)
+		}
+		res := c.Lookup(video)
+		if !res.Stale || res.Revalidate != tc.want {
+			t.Errorf("%s: Stale = %v, Revalidate = %v; ожидается true, %v", tc.name, res.Stale, res.Revalidate, tc.want)
+		}
+	}
+
+	// Для отсутствующей записи вызов ничего не делает
+	c.RevalidateFailed("http://s1.origin-cluster/video/2.m3u8")
+}
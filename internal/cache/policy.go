@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Kind — вид контента, для которого настраиваются окна кэширования
+type Kind string
+
+const (
+	KindManifest Kind = "manifest" // Плейлисты и манифесты (m3u8, mpd)
+	KindSegment  Kind = "segment"  // Сегменты и файлы медиа (ts, m4s, mp4 и т.д.)
+	KindOther    Kind = "other"    // Всё остальное, в том числе нераспознанные URL
+)
+
+// Policy задаёт окна кэширования для вида контента
+type Policy struct {
+	TTL         time.Duration // Время, в течение которого запись считается свежей
+	StaleTTL    time.Duration // Окно grace после TTL, когда запись отдаётся устаревшей и обновляется в фоне
+	NegativeTTL time.Duration // Время жизни негативной записи для отклонённого URL (0 — не кэшировать)
+}
+
+// DefaultPolicies — окна кэширования по умолчанию. TTL берётся из Options.TTL.
+var DefaultPolicies = map[Kind]Policy{
+	KindManifest: {StaleTTL: 30 * time.Second, NegativeTTL: defaultNegativeTTL},
+	KindSegment:  {StaleTTL: 2 * time.Minute, NegativeTTL: defaultNegativeTTL},
+	KindOther:    {NegativeTTL: defaultNegativeTTL},
+}
+
+// KindOf определяет вид контента по расширению файла в URL
+func KindOf(video string) Kind {
+	// Отбрасываем query и fragment, чтобы не зависеть от подписанных параметров
+	if i := strings.IndexAny(video, "?#"); i >= 0 {
+		video = video[:i]
+	}
+
+	switch strings.ToLower(path.Ext(video)) {
+	case ".m3u8", ".mpd":
+		return KindManifest
+	case ".ts", ".m4s", ".mp4", ".m4a", ".m4v", ".aac", ".vtt", ".webm":
+		return KindSegment
+	default:
+		return KindOther
+	}
+}
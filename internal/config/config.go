@@ -1,45 +1,301 @@
 package config
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"videobalance/internal/limiter"
 )
 
-// Config представляет конфигурацию приложения
+// Config представляет конфигурацию приложения.
+// Каждое поле описано тегами: key — путь в файле конфигурации (он же имя флага),
+// env — переменная окружения, default — значение по умолчанию, desc — описание для схемы,
+// enum, min, max — ограничения, проверяемые Validate, sep — разделитель списка в env и флагах,
+// reload:"hot" — параметр применяется при перезагрузке без перезапуска,
+// secret:"true" — значение скрывается в логах и выводе diff.
 type Config struct {
-	CDNHost    string // Хост CDN, используемый для передачи данных
-	ServerPort string // Порт для gRPC сервера, по которому сервер будет принимать соединения
+	CDNHost        string        `key:"cdn.host" reload:"hot" env:"CDN_HOST" default:"cdn.example.com" desc:"Хост CDN, используемый для передачи данных"`
+	ServerPort     string        `key:"server.port" env:"SERVER_PORT" default:":443" desc:"Адрес gRPC сервера"`
+	BalancerDomain string        `key:"server.domain" reload:"hot" env:"BALANCER_DOMAIN" default:"balancer-domain.com" desc:"Домен балансировщика"`
+	RequestTimeout time.Duration `key:"server.request_timeout" reload:"hot" env:"REQUEST_TIMEOUT" default:"10s" min:"1ms" desc:"Максимальное время обработки запроса Redirect"`
+	HealthAddr     string        `key:"health.addr" env:"HEALTH_ADDR" default:":8080" desc:"Адрес HTTP health check"`
+	HealthInterval time.Duration `key:"health.check_interval" env:"HEALTH_CHECK_INTERVAL" default:"30s" min:"0s" desc:"Период проверки доступности CDN и origin-серверов запросом HEAD / (0 — не проверяются)"`
+	DebugAddr      string        `key:"debug.addr" env:"DEBUG_ADDR" default:"localhost:6060" desc:"Адрес pprof и служебных HTTP-обработчиков /debug"`
+
+	GRPCMaxConcurrentStreams int `key:"grpc.max_concurrent_streams" env:"GRPC_MAX_CONCURRENT_STREAMS" default:"200000" min:"1" desc:"Максимум одновременных потоков на соединение"`
+	GRPCMaxRecvMsgSizeMB     int `key:"grpc.max_recv_msg_size_mb" env:"GRPC_MAX_RECV_MSG_SIZE_MB" default:"100" min:"1" desc:"Максимальный размер входящего сообщения в мегабайтах"`
+	GRPCMaxSendMsgSizeMB     int `key:"grpc.max_send_msg_size_mb" env:"GRPC_MAX_SEND_MSG_SIZE_MB" default:"100" min:"1" desc:"Максимальный размер исходящего сообщения в мегабайтах"`
+	GRPCWriteBufferSizeKB    int `key:"grpc.write_buffer_size_kb" env:"GRPC_WRITE_BUFFER_SIZE_KB" default:"262144" min:"0" desc:"Размер буфера записи соединения в килобайтах"`
+	GRPCReadBufferSizeKB     int `key:"grpc.read_buffer_size_kb" env:"GRPC_READ_BUFFER_SIZE_KB" default:"262144" min:"0" desc:"Размер буфера чтения соединения в килобайтах"`
+
+	GRPCDefaultTimeout    time.Duration     `key:"grpc.default_timeout" env:"GRPC_DEFAULT_TIMEOUT" default:"30s" min:"0s" desc:"Срок унарных вызовов без deadline клиента (0 — без срока)"`
+	GRPCMaxTimeout        time.Duration     `key:"grpc.max_timeout" env:"GRPC_MAX_TIMEOUT" default:"1m" min:"0s" desc:"Максимальный срок унарных вызовов; больший deadline клиента сокращается (0 — без ограничения)"`
+	GRPCAuthTokens        []string          `key:"grpc.auth.tokens" env:"GRPC_AUTH_TOKENS" secret:"true" desc:"Токены Bearer для вызовов gRPC; пусто — Balancer доступен без токена"`
+	GRPCAuthAdminTokens   []string          `key:"grpc.auth.admin_tokens" env:"GRPC_AUTH_ADMIN_TOKENS" secret:"true" desc:"Токены Bearer для служебного сервиса Admin, действуют и для остальных; пусто — Admin принимает grpc.auth.tokens"`
+	GRPCAuthTenantTokens  map[string]string `key:"grpc.auth.tenant_tokens" env:"GRPC_AUTH_TENANT_TOKENS" secret:"true" desc:"Токены Bearer арендаторов: арендатор=токен; арендатор вызова определяется по токену"`
+	GRPCAuthGatewayTokens []string          `key:"grpc.auth.gateway_tokens" env:"GRPC_AUTH_GATEWAY_TOKENS" secret:"true" desc:"Токены Bearer доверенного шлюза, от которого принимаются метаданные x-tenant и x-priority"`
+
+	CacheSize   int           `key:"cache.size" env:"CACHE_SIZE" default:"5000" min:"1" desc:"Суммарная ёмкость кэша маршрутов"`
+	CacheTTL    time.Duration `key:"cache.ttl" reload:"hot" env:"CACHE_TTL" default:"10m" min:"1s" desc:"Время жизни записи кэша"`
+	CacheShards int           `key:"cache.shards" env:"CACHE_SHARDS" default:"64" min:"1" max:"65536" desc:"Количество шардов кэша, округляется вверх до степени двойки"`
+
+	CacheManifestStaleTTL    time.Duration `key:"cache.policies.manifest.stale_ttl" reload:"hot" env:"CACHE_MANIFEST_STALE_TTL" default:"30s" min:"0s" max:"24h" desc:"Окно отдачи устаревшей записи манифеста с фоновым обновлением (0 — не отдавать устаревшей)"`
+	CacheManifestNegativeTTL time.Duration `key:"cache.policies.manifest.negative_ttl" reload:"hot" env:"CACHE_MANIFEST_NEGATIVE_TTL" default:"30s" min:"0s" max:"24h" desc:"Время жизни негативной записи для отклонённого URL манифеста (0 — не кэшировать)"`
+	CacheSegmentStaleTTL     time.Duration `key:"cache.policies.segment.stale_ttl" reload:"hot" env:"CACHE_SEGMENT_STALE_TTL" default:"2m" min:"0s" max:"24h" desc:"Окно отдачи устаревшей записи сегмента с фоновым обновлением (0 — не отдавать устаревшей)"`
+	CacheSegmentNegativeTTL  time.Duration `key:"cache.policies.segment.negative_ttl" reload:"hot" env:"CACHE_SEGMENT_NEGATIVE_TTL" default:"30s" min:"0s" max:"24h" desc:"Время жизни негативной записи для отклонённого URL сегмента (0 — не кэшировать)"`
+	CacheOtherStaleTTL       time.Duration `key:"cache.policies.other.stale_ttl" reload:"hot" env:"CACHE_OTHER_STALE_TTL" default:"0s" min:"0s" max:"24h" desc:"Окно отдачи устаревшей записи прочего контента с фоновым обновлением (0 — не отдавать устаревшей)"`
+	CacheOtherNegativeTTL    time.Duration `key:"cache.policies.other.negative_ttl" reload:"hot" env:"CACHE_OTHER_NEGATIVE_TTL" default:"30s" min:"0s" max:"24h" desc:"Время жизни негативной записи для прочих отклонённых URL (0 — не кэшировать)"`
+
+	OriginTargetShare  float64 `key:"origin.target_share" reload:"hot" env:"ORIGIN_TARGET_SHARE" default:"0.1" min:"0" max:"1" desc:"Целевая доля запросов, отправляемых на origin (0, 1]"`
+	OriginTargetRPS    float64 `key:"origin.target_rps" reload:"hot" env:"ORIGIN_TARGET_RPS" default:"0" min:"0" desc:"Целевые запросы в секунду на один origin-сервер; если задано, заменяет target_share (0 — не используется)"`
+	OriginMaxRPS       float64 `key:"origin.max_rps" reload:"hot" env:"ORIGIN_MAX_RPS" default:"0" min:"0" desc:"Жёсткий лимит запросов в секунду на один origin-сервер поверх регулятора (0 — без лимита)"`
+	OriginHotThreshold int     `key:"origin.hot_threshold" reload:"hot" env:"ORIGIN_HOT_THRESHOLD" default:"100" min:"0" desc:"Видео с оценкой популярности не ниже порога всегда идут через CDN (0 — не учитывать)"`
+
+	ConcurrencyInitialLimit int     `key:"concurrency.initial_limit" reload:"hot" env:"CONCURRENCY_INITIAL_LIMIT" default:"100" min:"1" desc:"Начальный адаптивный лимит параллельных запросов"`
+	ConcurrencyMinLimit     int     `key:"concurrency.min_limit" reload:"hot" env:"CONCURRENCY_MIN_LIMIT" default:"10" min:"1" desc:"Нижняя граница адаптивного лимита"`
+	ConcurrencyMaxLimit     int     `key:"concurrency.max_limit" reload:"hot" env:"CONCURRENCY_MAX_LIMIT" default:"5000" min:"1" desc:"Верхняя граница адаптивного лимита"`
+	ConcurrencyTolerance    float64 `key:"concurrency.tolerance" reload:"hot" env:"CONCURRENCY_TOLERANCE" default:"1.5" min:"1" desc:"Допустимый рост задержки до уменьшения лимита"`
+
+	TenantPriorities     map[string]limiter.Priority `key:"priority.tenants" reload:"hot" env:"TENANT_PRIORITIES" enum:"low,normal,high,critical" desc:"Приоритеты арендаторов из grpc.auth.tenant_tokens или x-tenant шлюза: арендатор=приоритет"`
+	DefaultPriority      limiter.Priority            `key:"priority.default" reload:"hot" env:"DEFAULT_PRIORITY" default:"low" enum:"low,normal,high,critical" desc:"Приоритет запросов без арендатора и x-priority"`
+	PriorityTrustHeaders bool                        `key:"priority.trust_headers" reload:"hot" env:"PRIORITY_TRUST_HEADERS" default:"false" desc:"Принимать x-tenant и x-priority от любого клиента, а не только от шлюза из grpc.auth.gateway_tokens (только если сервис доступен лишь через доверенный шлюз)"`
+
+	AccessLog               string        `key:"access_log.output" env:"ACCESS_LOG" default:"stdout" desc:"Вывод журнала доступа: stdout, stderr, off или путь к файлу"`
+	AccessLogFormat         string        `key:"access_log.format" env:"ACCESS_LOG_FORMAT" default:"json" enum:"json,text" desc:"Формат журнала доступа"`
+	AccessLogMaxSizeMB      int           `key:"access_log.max_size_mb" env:"ACCESS_LOG_MAX_SIZE_MB" default:"100" min:"0" desc:"Размер файла журнала для ротации в мегабайтах (0 — без ограничения)"`
+	AccessLogRotateInterval time.Duration `key:"access_log.rotate_interval" env:"ACCESS_LOG_ROTATE_INTERVAL" default:"24h" min:"0s" desc:"Период ротации файла журнала (0 — без ротации по времени)"`
+	AccessLogMaxBackups     int           `key:"access_log.max_backups" env:"ACCESS_LOG_MAX_BACKUPS" default:"7" min:"0" desc:"Количество хранимых ротированных файлов (0 — хранить все)"`
+	AccessLogCompress       bool          `key:"access_log.compress" env:"ACCESS_LOG_COMPRESS" default:"true" desc:"Сжимать ротированные файлы gzip"`
+
+	LogLevel slog.Level `key:"log.level" reload:"hot" env:"LOG_LEVEL" default:"info" enum:"debug,info,warn,error" desc:"Начальный глобальный уровень логирования; меняется во время работы через /debug/loglevel"`
+	LogSinks []string   `key:"log.sinks" env:"LOG_SINKS" desc:"Приёмники асинхронного логгера; пусто — slog в stdout"`
+
+	LogOverflowPolicy       string        `key:"log.overflow.policy" env:"LOG_OVERFLOW_POLICY" default:"drop_newest" enum:"drop_newest,drop_oldest,block,spill" desc:"Поведение при переполнении канала логов"`
+	LogOverflowBlockTimeout time.Duration `key:"log.overflow.block_timeout" env:"LOG_OVERFLOW_BLOCK_TIMEOUT" default:"5ms" min:"1ns" desc:"Для block: максимальное ожидание места в канале"`
+	LogSpillPath            string        `key:"log.overflow.spill_path" env:"LOG_SPILL_PATH" default:"${TMPDIR}/videobalance-logs.spill" desc:"Для spill: файл переполнения"`
+	LogSpillMaxSizeMB       int           `key:"log.overflow.spill_max_size_mb" env:"LOG_SPILL_MAX_SIZE_MB" default:"100" min:"1" desc:"Для spill: размер файла переполнения в мегабайтах"`
+
+	WorkerCount           int           `key:"worker.count" env:"WORKER_COUNT" default:"16" min:"1" desc:"Начальное количество горутин исполнителя фоновых задач"`
+	WorkerMinCount        int           `key:"worker.min_count" env:"WORKER_MIN_COUNT" default:"4" min:"1" desc:"Нижняя граница количества горутин при автомасштабировании"`
+	WorkerMaxCount        int           `key:"worker.max_count" env:"WORKER_MAX_COUNT" default:"256" min:"1" desc:"Верхняя граница количества горутин при автомасштабировании"`
+	WorkerQueueSize       int           `key:"worker.queue_size" env:"WORKER_QUEUE_SIZE" default:"1024" min:"1" desc:"Ёмкость очереди исполнителя"`
+	WorkerTaskTimeout     time.Duration `key:"worker.task_timeout" env:"WORKER_TASK_TIMEOUT" default:"1m" min:"0s" desc:"Максимальное время выполнения фоновой задачи (0 — без ограничения)"`
+	WorkerTargetQueueWait time.Duration `key:"worker.target_queue_wait" env:"WORKER_TARGET_QUEUE_WAIT" default:"100ms" min:"1ms" desc:"Время ожидания задачи в очереди, при превышении которого пул растёт"`
+
+	LogRedactParams   []string `key:"log.redact.params" reload:"hot" env:"LOG_REDACT_PARAMS" desc:"Дополнительные параметры query, значения которых скрываются в логах"`
+	LogRedactHeaders  []string `key:"log.redact.headers" reload:"hot" env:"LOG_REDACT_HEADERS" desc:"Дополнительные заголовки и ключи атрибутов, значения которых скрываются"`
+	LogRedactPatterns []string `key:"log.redact.patterns" reload:"hot" env:"LOG_REDACT_PATTERNS" sep:";" desc:"Дополнительные регулярные выражения для скрытия"`
+
+	LogSamplingFirst      int           `key:"log.sampling.first" env:"LOG_SAMPLING_FIRST" default:"100" min:"0" desc:"Сколько одинаковых сообщений пропускать за окно (0 — семплирование выключено)"`
+	LogSamplingThereafter int           `key:"log.sampling.thereafter" env:"LOG_SAMPLING_THEREAFTER" default:"100" min:"0" desc:"После этого пропускать каждое M-е сообщение"`
+	LogSamplingInterval   time.Duration `key:"log.sampling.interval" env:"LOG_SAMPLING_INTERVAL" default:"1s" min:"1ms" desc:"Окно семплирования"`
+	LogSamplingKey        string        `key:"log.sampling.key" env:"LOG_SAMPLING_KEY" desc:"Атрибут, добавляемый к ключу семплирования (например, url)"`
+
+	TracingEndpoint    string  `key:"tracing.endpoint" env:"TRACING_ENDPOINT" desc:"URL коллектора OTLP, например http://otel-collector:4317; пусто — трассировки не экспортируются"`
+	TracingProtocol    string  `key:"tracing.protocol" env:"TRACING_PROTOCOL" default:"grpc" enum:"grpc,http" desc:"Протокол экспорта OTLP"`
+	TracingSampleRatio float64 `key:"tracing.sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"0.1" min:"0" max:"1" desc:"Доля новых трассировок, которые записываются; решение входящего traceparent соблюдается"`
+
+	ConfigWatchInterval time.Duration `key:"config.watch_interval" env:"CONFIG_WATCH_INTERVAL" default:"5s" min:"0s" desc:"Период проверки изменения файла конфигурации (0 — только по SIGHUP)"`
+	RemoteURL           string        `key:"config.remote.url" reload:"hot" env:"CONFIG_URL" desc:"URL удалённой конфигурации YAML или JSON; перекрывает файл, но не переменные окружения и флаги"`
+	RemotePublicKey     string        `key:"config.remote.public_key" reload:"hot" env:"CONFIG_PUBLIC_KEY" desc:"Открытый ключ Ed25519 в base64; если задан, удалённая конфигурация принимается только с верной подписью"`
+	RemotePollInterval  time.Duration `key:"config.remote.poll_interval" env:"CONFIG_POLL_INTERVAL" default:"30s" min:"0s" desc:"Период опроса удалённой конфигурации (0 — только по SIGHUP)"`
+	RemoteCachePath     string        `key:"config.remote.cache_path" reload:"hot" env:"CONFIG_CACHE_PATH" default:"${TMPDIR}/videobalance-config.cache" desc:"Файл последней корректной удалённой конфигурации, используемой при недоступности URL"`
+
+	sources map[string]string // Ключ → источник значения (default, файл, переменная окружения или флаг)
+	file    string            // Прочитанный файл конфигурации
+}
+
+// Переменная окружения с путём к файлу конфигурации; флаг -config имеет приоритет
+const configFileEnv = "CONFIG_FILE"
+
+// Замена значений параметров с тегом secret в логах и выводе diff
+const secretValue = "[REDACTED]"
+
+// Default возвращает конфигурацию со значениями по умолчанию
+func Default() *Config {
+	cfg := &Config{sources: make(map[string]string)}
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(cfg, expandDefault(f.def)); err != nil {
+			// Значения по умолчанию заданы в коде и проверяются при разработке
+			panic(fmt.Sprintf("config: некорректное значение по умолчанию %s: %v", f.key, err))
+		}
+		cfg.sources[f.key] = "default"
+	}
+	return cfg
 }
 
-// LoadConfig считывает переменные окружения или использует значения по умолчанию
-// Эта функция пытается загрузить значения конфигурации из переменных окружения.
-// Если переменная окружения не задана, то используется значение по умолчанию.
+// LoadConfig собирает конфигурацию из аргументов командной строки процесса, см. Load
 func LoadConfig() (*Config, error) {
-	// Получаем значение переменной окружения CDN_HOST.
-	cdnHost := os.Getenv("CDN_HOST")
-	if cdnHost == "" {
-		// Если переменная не задана, используем значение по умолчанию и предупреждаем об этом.
-		cdnHost = "cdn.example.com" // Значение по умолчанию для CDN хоста
-		slog.Warn("Переменная CDN_HOST не задана. Используется значение по умолчанию", "CDN_HOST", cdnHost)
-	} else {
-		// Если переменная задана, логируем её значение.
-		slog.Info("Переменная CDN_HOST загружена", "CDN_HOST", cdnHost)
-	}
-
-	// Получаем значение переменной окружения SERVER_PORT.
-	serverPort := os.Getenv("SERVER_PORT")
-	if serverPort == "" {
-		// Если переменная не задана, используем значение по умолчанию и предупреждаем об этом.
-		serverPort = ":443" // Значение по умолчанию для порта
-		slog.Warn("Переменная SERVER_PORT не задана. Используется значение по умолчанию", "SERVER_PORT", serverPort)
-	} else {
-		// Если переменная задана, логируем её значение.
-		slog.Info("Переменная SERVER_PORT загружена", "SERVER_PORT", serverPort)
-	}
-
-	// Возвращаем структуру конфигурации с загруженными значениями.
-	return &Config{
-		CDNHost:    cdnHost,
-		ServerPort: serverPort,
-	}, nil
+	return Load(os.Args[1:])
+}
+
+// Load собирает конфигурацию из аргументов командной строки args, см. Loader.Load.
+// Ошибки разбора и проверки всех полей возвращаются вместе.
+func Load(args []string) (*Config, error) {
+	return NewLoader(args).Load(context.Background())
+}
+
+// LoadFile читает конфигурацию из файла поверх значений по умолчанию, без переменных окружения и флагов
+func LoadFile(path string) (*Config, error) {
+	return LoadFrom(context.Background(), FileProvider{Path: path})
+}
+
+// Чтение файла: формат определяется по расширению, .json — JSON, иначе YAML
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("чтение файла конфигурации: %w", err)
+	}
+	return c.apply(data, strings.EqualFold(filepath.Ext(path), ".json"), "file "+path)
+}
+
+// Применение документа конфигурации; source записывается как источник значений
+func (c *Config) apply(data []byte, isJSON bool, source string) error {
+	var doc map[string]any
+	if isJSON {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("разбор %s: %w", source, err)
+		}
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("разбор %s: %w", source, err)
+	}
+
+	values := make(map[string]any)
+	var errs []error
+	flatten("", doc, values, &errs)
+	for key, value := range values {
+		f, ok := fieldsByKey[key]
+		if !ok {
+			if isSection(key) {
+				errs = append(errs, fmt.Errorf("%s: ожидается секция, а не значение", key))
+			} else {
+				errs = append(errs, fmt.Errorf("%s: неизвестный параметр", key))
+			}
+			continue
+		}
+		if err := f.setAny(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: некорректное значение %v: %w", key, value, err))
+			continue
+		}
+		c.sources[key] = source
+	}
+	return errors.Join(sortErrors(errs)...)
+}
+
+// File возвращает путь к прочитанному файлу конфигурации; пусто, если файл не задан
+func (c *Config) File() string {
+	return c.file
+}
+
+// Source возвращает источник значения параметра key: default, file <путь>, env <переменная> или flag
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// Validate проверяет все поля и возвращает все найденные ошибки вместе
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	// Ограничения из тегов enum, min и max
+	for _, f := range fields {
+		if err := f.check(c); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+
+	if c.CDNHost == "" {
+		add("cdn.host", "не может быть пустым")
+	}
+	if c.BalancerDomain == "" {
+		add("server.domain", "не может быть пустым")
+	}
+	addrs := map[string]string{}
+	for _, a := range []struct{ key, addr string }{
+		{"server.port", c.ServerPort},
+		{"health.addr", c.HealthAddr},
+		{"debug.addr", c.DebugAddr},
+	} {
+		_, port, err := net.SplitHostPort(a.addr)
+		if err != nil {
+			add(a.key, "некорректный адрес %q: %v", a.addr, err)
+			continue
+		}
+		if other, ok := addrs[port]; ok && port != "0" {
+			add(a.key, "порт %s уже используется параметром %s", port, other)
+		}
+		addrs[port] = a.key
+	}
+
+	if c.GRPCMaxTimeout > 0 && c.GRPCDefaultTimeout > c.GRPCMaxTimeout {
+		add("grpc.default_timeout", "значение %v больше grpc.max_timeout (%v)", c.GRPCDefaultTimeout, c.GRPCMaxTimeout)
+	}
+	if c.OriginTargetShare == 0 && c.OriginTargetRPS == 0 {
+		add("origin.target_share", "должна быть больше 0")
+	}
+	if c.OriginMaxRPS > 0 && c.OriginTargetRPS > c.OriginMaxRPS {
+		add("origin.target_rps", "значение %v больше origin.max_rps (%v)", c.OriginTargetRPS, c.OriginMaxRPS)
+	}
+	if c.ConcurrencyMinLimit > c.ConcurrencyInitialLimit || c.ConcurrencyInitialLimit > c.ConcurrencyMaxLimit {
+		add("concurrency", "лимиты должны удовлетворять min_limit (%d) ≤ initial_limit (%d) ≤ max_limit (%d)",
+			c.ConcurrencyMinLimit, c.ConcurrencyInitialLimit, c.ConcurrencyMaxLimit)
+	}
+	tokenTenants := make(map[string]string, len(c.GRPCAuthTenantTokens))
+	for tenant, token := range c.GRPCAuthTenantTokens {
+		switch other, dup := tokenTenants[token]; {
+		case strings.TrimSpace(tenant) == "" || token == "":
+			add("grpc.auth.tenant_tokens", "пустое имя арендатора или токен")
+		case dup:
+			add("grpc.auth.tenant_tokens", "у арендаторов %s и %s одинаковый токен", min(tenant, other), max(tenant, other))
+		}
+		tokenTenants[token] = tenant
+	}
+	for tenant := range c.TenantPriorities {
+		if strings.TrimSpace(tenant) == "" {
+			add("priority.tenants", "пустое имя арендатора")
+		}
+	}
+	if c.WorkerMinCount > c.WorkerCount || c.WorkerCount > c.WorkerMaxCount {
+		add("worker", "количество горутин должно удовлетворять min_count (%d) ≤ count (%d) ≤ max_count (%d)",
+			c.WorkerMinCount, c.WorkerCount, c.WorkerMaxCount)
+	}
+	if c.LogOverflowPolicy == "spill" && c.LogSpillPath == "" {
+		add("log.overflow.spill_path", "обязателен для политики spill")
+	}
+	for _, u := range []struct{ key, url string }{
+		{"tracing.endpoint", c.TracingEndpoint},
+		{"config.remote.url", c.RemoteURL},
+	} {
+		if u.url == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.url); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			add(u.key, "ожидается URL http или https, получено %q", u.url)
+		}
+	}
+	if _, err := ParsePublicKey(c.RemotePublicKey); err != nil {
+		add("config.remote.public_key", "%v", err)
+	}
+	for _, p := range c.LogRedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			add("log.redact.patterns", "%v", err)
+		}
+	}
+	return errors.Join(errs...)
 }
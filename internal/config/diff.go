@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change — изменение параметра между двумя конфигурациями
+type Change struct {
+	Key     string
+	Old     string
+	New     string
+	Hot     bool // Применяется без перезапуска
+	Added   bool // Элемент словаря добавлен, Old пусто
+	Removed bool // Элемент словаря удалён, New пусто
+}
+
+// String описывает изменение одной строкой: «+» — добавлено, «-» — удалено, «~» — изменено
+func (c Change) String() string {
+	var s string
+	switch {
+	case c.Added:
+		s = fmt.Sprintf("+ %s: %s", c.Key, c.New)
+	case c.Removed:
+		s = fmt.Sprintf("- %s: %s", c.Key, c.Old)
+	default:
+		s = fmt.Sprintf("~ %s: %s → %s", c.Key, quoteEmpty(c.Old), quoteEmpty(c.New))
+	}
+	if !c.Hot {
+		s += " (после перезапуска)"
+	}
+	return s
+}
+
+func quoteEmpty(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return s
+}
+
+// Diff возвращает изменённые параметры в порядке объявления полей Config.
+// Словари сравниваются по элементам: ключ изменения — key.элемент.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	for _, f := range fields {
+		if reflect.DeepEqual(f.value(old).Interface(), f.value(new).Interface()) {
+			continue
+		}
+		if f.typ.Kind() == reflect.Map {
+			changes = append(changes, f.diffMap(f.value(old), f.value(new))...)
+			continue
+		}
+		changes = append(changes, Change{Key: f.key, Old: f.format(old), New: f.format(new), Hot: f.hot})
+	}
+	return changes
+}
+
+// Изменения элементов словаря в порядке ключей
+func (f *field) diffMap(old, new reflect.Value) []Change {
+	keys := make(map[string]reflect.Value)
+	for _, m := range []reflect.Value{old, new} {
+		iter := m.MapRange()
+		for iter.Next() {
+			keys[fmt.Sprint(iter.Key().Interface())] = iter.Key()
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		o, n := old.MapIndex(keys[name]), new.MapIndex(keys[name])
+		c := Change{Key: f.key + "." + name, Hot: f.hot}
+		switch {
+		case !o.IsValid():
+			c.Added, c.New = true, f.itemText(n)
+		case !n.IsValid():
+			c.Removed, c.Old = true, f.itemText(o)
+		case reflect.DeepEqual(o.Interface(), n.Interface()):
+			continue
+		default:
+			c.Old, c.New = f.itemText(o), f.itemText(n)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// Текстовое представление элемента словаря; ключи секретного словаря видны, значения — нет
+func (f *field) itemText(v reflect.Value) string {
+	if f.secret {
+		return secretValue
+	}
+	return textValue(v)
+}
+
+// Текстовое представление значения параметра; словари сравниваются по элементам в diffMap
+func (f *field) format(c *Config) string {
+	v := f.value(c)
+	if f.secret && !v.IsZero() {
+		return secretValue
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = textValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return textValue(v)
+}
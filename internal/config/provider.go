@@ -0,0 +1,215 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Provider — источник значений конфигурации. Источники накладываются по очереди,
+// значения более позднего перекрывают значения более раннего.
+type Provider interface {
+	Name() string
+	Apply(ctx context.Context, c *Config) error
+}
+
+// LoadFrom собирает конфигурацию из значений по умолчанию и источников providers.
+// Ошибки всех источников и проверки возвращаются вместе.
+func LoadFrom(ctx context.Context, providers ...Provider) (*Config, error) {
+	cfg := Default()
+	var errs []error
+	for _, p := range providers {
+		if err := p.Apply(ctx, cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FileProvider читает файл YAML или JSON; формат определяется по расширению, .json — JSON, иначе YAML
+type FileProvider struct {
+	Path string
+}
+
+func (p FileProvider) Name() string { return "file " + p.Path }
+
+func (p FileProvider) Apply(_ context.Context, c *Config) error {
+	c.file = p.Path
+	return c.loadFile(p.Path)
+}
+
+// EnvProvider читает переменные окружения из тегов env; пустые переменные пропускаются
+type EnvProvider struct{}
+
+func (EnvProvider) Name() string { return "env" }
+
+func (EnvProvider) Apply(_ context.Context, c *Config) error {
+	var errs []error
+	for _, f := range fields {
+		raw, ok := os.LookupEnv(f.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := f.set(c, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: некорректное значение %s=%q: %w", f.key, f.env, raw, err))
+			continue
+		}
+		c.sources[f.key] = "env " + f.env
+	}
+	return errors.Join(errs...)
+}
+
+// Значения флагов командной строки в порядке указания
+type flagProvider []flagValue
+
+type flagValue struct {
+	field *field
+	raw   string
+}
+
+func (flagProvider) Name() string { return "flag" }
+
+func (p flagProvider) Apply(_ context.Context, c *Config) error {
+	var errs []error
+	for _, v := range p {
+		if err := v.field.set(c, v.raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: некорректное значение флага -%s=%q: %w", v.field.key, v.field.key, v.raw, err))
+			continue
+		}
+		c.sources[v.field.key] = "flag"
+	}
+	return errors.Join(errs...)
+}
+
+// Loader собирает конфигурацию из аргументов командной строки: файл, удалённый источник,
+// переменные окружения и флаги. Между вызовами Load сохраняется состояние удалённого
+// источника — ETag и последний полученный документ.
+type Loader struct {
+	args []string
+
+	mu     sync.Mutex
+	remote *HTTPProvider
+}
+
+// NewLoader создаёт загрузчик для аргументов командной строки args (без имени программы)
+func NewLoader(args []string) *Loader {
+	return &Loader{args: args}
+}
+
+// Load собирает конфигурацию. Источники по возрастанию приоритета: значения по умолчанию,
+// файл YAML или JSON (флаг -config или переменная CONFIG_FILE), удалённый источник
+// (config.remote.url), переменные окружения, флаги.
+func (l *Loader) Load(ctx context.Context) (*Config, error) {
+	fs := flag.NewFlagSet("balancer", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(configFileEnv), "Файл конфигурации YAML или JSON")
+	var flags flagProvider
+	for _, f := range fields {
+		fs.Func(f.key, f.desc, func(raw string) error {
+			flags = append(flags, flagValue{field: f, raw: raw})
+			return nil
+		})
+	}
+	if err := fs.Parse(l.args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("неожиданные аргументы: %s", strings.Join(fs.Args(), " "))
+	}
+
+	var file []Provider
+	if *configFile != "" {
+		file = append(file, FileProvider{Path: *configFile})
+	}
+	overrides := []Provider{EnvProvider{}, flags}
+
+	// Удалённый источник настраивается только локальными источниками,
+	// ошибки в них будут выведены при полной сборке
+	boot := Default()
+	for _, p := range slices.Concat(file, overrides) {
+		_ = p.Apply(ctx, boot)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.setRemote(boot); err != nil {
+		return nil, err
+	}
+	// Удалённый источник перекрывает файл, но не переменные окружения и флаги
+	var remote []Provider
+	if l.remote != nil {
+		remote = append(remote, l.remote)
+	}
+	providers := slices.Concat(file, remote, overrides)
+
+	cfg, err := LoadFrom(ctx, providers...)
+	if err != nil {
+		if l.remote != nil && l.remote.hasPending() {
+			// Новый удалённый документ отклоняется, если без него конфигурация корректна
+			_, prevErr := LoadFrom(ctx, slices.Concat(file, []Provider{l.remote.committed()}, overrides)...)
+			l.remote.discard(prevErr == nil)
+		}
+		return nil, err
+	}
+	if l.remote != nil {
+		l.remote.commit()
+	}
+
+	for _, f := range fields {
+		if cfg.sources[f.key] == "env "+f.env {
+			value := os.Getenv(f.env)
+			if f.secret {
+				value = secretValue
+			}
+			slog.Info("Переменная загружена", f.env, value)
+		}
+	}
+	if cfg.Source("cdn.host") == "default" {
+		slog.Warn("Хост CDN не задан. Используется значение по умолчанию", "CDN_HOST", cfg.CDNHost)
+	}
+	return cfg, nil
+}
+
+// Poll проверяет, изменился ли документ удалённого источника с прошлого запроса.
+// Без удалённого источника возвращает false.
+func (l *Loader) Poll(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	remote := l.remote
+	l.mu.Unlock()
+	if remote == nil {
+		return false, nil
+	}
+	return remote.Poll(ctx)
+}
+
+// Создание удалённого источника по параметрам config.remote; при неизменных параметрах
+// источник сохраняется вместе с состоянием
+func (l *Loader) setRemote(boot *Config) error {
+	if boot.RemoteURL == "" {
+		l.remote = nil
+		return nil
+	}
+	key, err := ParsePublicKey(boot.RemotePublicKey)
+	if err != nil {
+		// Ошибку ключа вернёт Validate вместе с остальными
+		l.remote = nil
+		return nil
+	}
+	opts := HTTPOptions{URL: boot.RemoteURL, PublicKey: key, CachePath: boot.RemoteCachePath}
+	if l.remote != nil && l.remote.sameOptions(opts) {
+		return nil
+	}
+	l.remote = NewHTTPProvider(opts)
+	return nil
+}
@@ -0,0 +1,370 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader — заголовок ответа с подписью Ed25519 тела документа в base64
+	SignatureHeader = "X-Config-Signature"
+
+	remoteTimeout    = 10 * time.Second // Таймаут запроса удалённой конфигурации по умолчанию
+	remoteBackoff    = 10 * time.Second // Первая пауза опроса после ошибки по умолчанию
+	remoteMaxBackoff = 5 * time.Minute  // Предельная пауза опроса после ошибок по умолчанию
+	maxRemoteSize    = 10 << 20         // Максимальный размер удалённого документа
+)
+
+// HTTPOptions задаёт удалённый источник конфигурации
+type HTTPOptions struct {
+	URL       string
+	PublicKey ed25519.PublicKey // Если задан, документ принимается только с верной подписью в SignatureHeader
+	CachePath string            // Файл последнего корректного документа; пусто — копия не сохраняется
+	Client    *http.Client      // По умолчанию клиент с таймаутом 10 секунд
+
+	// После ошибки Poll не обращается к URL в течение паузы, которая удваивается
+	// с каждой следующей ошибкой до MaxBackoff. По умолчанию 10 секунд и 5 минут.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// HTTPProvider получает документ конфигурации по HTTP. Повторные запросы условные
+// (If-None-Match), так что неизменный документ не передаётся заново. Новый документ
+// становится действующим только после успешной проверки всей конфигурации (commit);
+// отклонённый (discard) больше не применяется, пока сервер не отдаст другой. Если URL
+// недоступен, используется последний действующий документ, а после перезапуска —
+// сохранённый на диске.
+type HTTPProvider struct {
+	opts HTTPOptions
+
+	mu       sync.Mutex
+	doc      *remoteDoc // Действующий документ: с ним конфигурация прошла проверку
+	pending  *remoteDoc // Полученный документ, ещё не прошедший проверку всей конфигурации
+	rejected *remoteDoc // Документ, с которым конфигурация не прошла проверку
+	saved    *remoteDoc // Документ, сохранённый в CachePath
+
+	failures int       // Ошибки запроса подряд
+	lastErr  error     // Последняя ошибка запроса
+	retryAt  time.Time // До этого момента Poll не обращается к URL
+}
+
+// Документ удалённой конфигурации; в таком виде он сохраняется на диск
+type remoteDoc struct {
+	ETag      string `json:"etag,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	JSON      bool   `json:"json"`
+	Data      string `json:"data"`
+}
+
+// NewHTTPProvider создаёт удалённый источник; первый запрос выполняется в Apply или Poll
+func NewHTTPProvider(opts HTTPOptions) *HTTPProvider {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: remoteTimeout}
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = remoteBackoff
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(remoteMaxBackoff, opts.Backoff)
+	}
+	return &HTTPProvider{opts: opts}
+}
+
+// ParsePublicKey разбирает открытый ключ Ed25519 в base64; пустая строка — ключ не задан
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("ключ не в base64: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("длина ключа Ed25519 должна быть %d байт, получено %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (p *HTTPProvider) Name() string { return "remote " + p.opts.URL }
+
+// Apply запрашивает документ и накладывает на c новый документ или, если нового нет
+// или запрос не удался, действующий либо сохранённый на диске.
+// Пауза после ошибок не соблюдается: явная перезагрузка всегда обращается к URL.
+func (p *HTTPProvider) Apply(ctx context.Context, c *Config) error {
+	_, err := p.fetch(ctx)
+
+	p.mu.Lock()
+	if err != nil && p.doc == nil && p.pending == nil {
+		p.doc = p.loadCache()
+	}
+	doc := p.doc
+	if p.pending != nil {
+		doc = p.pending
+	}
+	p.mu.Unlock()
+
+	if doc == nil {
+		return fmt.Errorf("%s: %w; сохранённой копии нет", p.Name(), err)
+	}
+	if err != nil {
+		slog.Warn("Удалённая конфигурация недоступна, используется последняя полученная", "url", p.opts.URL, "etag", doc.ETag, "ошибка", err)
+	}
+	return c.apply([]byte(doc.Data), doc.JSON, p.Name())
+}
+
+// Poll запрашивает документ и возвращает true, если получен новый документ.
+// Документ с неверной подписью или синтаксисом отклоняется, прежний остаётся в силе.
+// После ошибки URL не запрашивается до истечения паузы, а Poll возвращает последнюю ошибку.
+func (p *HTTPProvider) Poll(ctx context.Context) (bool, error) {
+	p.mu.Lock()
+	retryAt, lastErr := p.retryAt, p.lastErr
+	p.mu.Unlock()
+	if time.Now().Before(retryAt) {
+		return false, fmt.Errorf("повтор запроса после %s: %w", retryAt.Format(time.TimeOnly), lastErr)
+	}
+	return p.fetch(ctx)
+}
+
+// fetch выполняет запрос и ведёт счёт ошибок подряд для паузы Poll
+func (p *HTTPProvider) fetch(ctx context.Context) (bool, error) {
+	changed, err := p.request(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.failures, p.lastErr, p.retryAt = 0, nil, time.Time{}
+		return changed, nil
+	}
+	p.failures++
+	p.lastErr = err
+	p.retryAt = time.Now().Add(p.backoff(p.failures))
+	return false, err
+}
+
+// Пауза после n ошибок подряд
+func (p *HTTPProvider) backoff(n int) time.Duration {
+	delay := p.opts.Backoff
+	for i := 1; i < n && delay < p.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.opts.MaxBackoff)
+}
+
+func (p *HTTPProvider) request(ctx context.Context) (bool, error) {
+	// Условный запрос — относительно последнего полученного документа
+	p.mu.Lock()
+	prev := cmp.Or(p.pending, p.rejected, p.doc)
+	p.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/yaml, application/json")
+	if prev != nil && prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if prev == nil {
+			return false, errors.New("ответ 304 без предыдущего документа")
+		}
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("ответ %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return false, err
+	}
+	if len(data) > maxRemoteSize {
+		return false, fmt.Errorf("документ больше %d байт", maxRemoteSize)
+	}
+	doc := &remoteDoc{
+		ETag:      resp.Header.Get("ETag"),
+		Signature: resp.Header.Get(SignatureHeader),
+		JSON:      strings.Contains(resp.Header.Get("Content-Type"), "json") || strings.HasSuffix(req.URL.Path, ".json"),
+		Data:      string(data),
+	}
+	if err := p.check(doc); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.doc != nil && p.doc.same(doc):
+		// Содержимое не изменилось, обновляется только ETag
+		p.doc, p.pending, p.rejected = doc, nil, nil
+		return false, nil
+	case p.rejected != nil && p.rejected.same(doc):
+		p.rejected = doc
+		return false, nil
+	}
+	p.pending, p.rejected = doc, nil
+	slog.Info("Получена удалённая конфигурация", "url", p.opts.URL, "etag", doc.ETag, "size", len(data))
+	return true, nil
+}
+
+func (d *remoteDoc) same(other *remoteDoc) bool {
+	return d.Data == other.Data && d.JSON == other.JSON
+}
+
+// Проверка подписи и разбор документа
+func (p *HTTPProvider) check(doc *remoteDoc) error {
+	if p.opts.PublicKey != nil {
+		sig, err := base64.StdEncoding.DecodeString(doc.Signature)
+		if doc.Signature == "" || err != nil {
+			return fmt.Errorf("нет подписи в заголовке %s", SignatureHeader)
+		}
+		if !ed25519.Verify(p.opts.PublicKey, []byte(doc.Data), sig) {
+			return errors.New("неверная подпись")
+		}
+	}
+
+	// Параметры удалённого источника задаются только локально
+	scratch := &Config{sources: make(map[string]string)}
+	if err := scratch.apply([]byte(doc.Data), doc.JSON, p.Name()); err != nil {
+		return err
+	}
+	var errs []error
+	for key := range scratch.sources {
+		if strings.HasPrefix(key, "config.") {
+			errs = append(errs, fmt.Errorf("%s: задаётся только локально", key))
+		}
+	}
+	return errors.Join(sortErrors(errs)...)
+}
+
+// commit делает новый документ действующим и сохраняет его на диск как последний корректный.
+// Вызывается после успешной проверки всей конфигурации.
+func (p *HTTPProvider) commit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending != nil {
+		p.doc, p.pending = p.pending, nil
+	}
+	if p.opts.CachePath == "" || p.doc == nil || p.doc == p.saved {
+		return
+	}
+	data, err := json.Marshal(p.doc)
+	if err == nil {
+		err = writeFileAtomic(p.opts.CachePath, data)
+	}
+	if err != nil {
+		slog.Warn("Не удалось сохранить копию удалённой конфигурации", "path", p.opts.CachePath, "ошибка", err)
+		return
+	}
+	p.saved = p.doc
+}
+
+// discard сбрасывает новый документ, если с ним конфигурация не прошла проверку.
+// Действующим остаётся прежний документ. Если ошибка в самом документе (reject),
+// он больше не применяется, пока сервер его не заменит; иначе запрашивается снова.
+func (p *HTTPProvider) discard(reject bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		return
+	}
+	if reject {
+		slog.Warn("Удалённая конфигурация отклонена, действует прежняя", "url", p.opts.URL, "etag", p.pending.ETag)
+		p.rejected = p.pending
+	}
+	p.pending = nil
+}
+
+func (p *HTTPProvider) hasPending() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending != nil
+}
+
+// committed возвращает источник, применяющий только действующий документ, без запроса
+func (p *HTTPProvider) committed() Provider {
+	return committedProvider{p}
+}
+
+type committedProvider struct {
+	p *HTTPProvider
+}
+
+func (c committedProvider) Name() string { return c.p.Name() }
+
+func (c committedProvider) Apply(_ context.Context, cfg *Config) error {
+	c.p.mu.Lock()
+	doc := c.p.doc
+	c.p.mu.Unlock()
+	if doc == nil {
+		return nil
+	}
+	return cfg.apply([]byte(doc.Data), doc.JSON, c.p.Name())
+}
+
+// Чтение сохранённого документа; подпись проверяется заново
+func (p *HTTPProvider) loadCache() *remoteDoc {
+	if p.opts.CachePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p.opts.CachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Не удалось прочитать копию удалённой конфигурации", "path", p.opts.CachePath, "ошибка", err)
+		}
+		return nil
+	}
+	doc := &remoteDoc{}
+	if err = json.Unmarshal(data, doc); err == nil {
+		err = p.check(doc)
+	}
+	if err != nil {
+		slog.Warn("Копия удалённой конфигурации отклонена", "path", p.opts.CachePath, "ошибка", err)
+		return nil
+	}
+	p.saved = doc
+	slog.Warn("Используется сохранённая копия удалённой конфигурации", "path", p.opts.CachePath)
+	return doc
+}
+
+func (p *HTTPProvider) sameOptions(opts HTTPOptions) bool {
+	return p.opts.URL == opts.URL && p.opts.CachePath == opts.CachePath && bytes.Equal(p.opts.PublicKey, opts.PublicKey)
+}
+
+// Запись через временный файл, чтобы при сбое не осталось обрезанной копии
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
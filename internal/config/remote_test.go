@@ -0,0 +1,368 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Сервер удалённой конфигурации с управляемым ответом
+type configServer struct {
+	mu       sync.Mutex
+	doc      string
+	etag     string
+	sig      string // Подпись в SignatureHeader; пусто — без заголовка
+	status   int    // Если задан, возвращается вместо документа
+	requests int
+	ifMatch  []string // If-None-Match каждого запроса
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.ifMatch = append(s.ifMatch, r.Header.Get("If-None-Match"))
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	if s.sig != "" {
+		w.Header().Set(SignatureHeader, s.sig)
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write([]byte(s.doc))
+}
+
+func (s *configServer) set(f func(s *configServer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+func (s *configServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func newConfigServer(t *testing.T, doc, etag string) (*configServer, string) {
+	t.Helper()
+	s := &configServer{doc: doc, etag: etag}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL + "/balancer.yaml"
+}
+
+func applyRemote(t *testing.T, p *HTTPProvider) (*Config, error) {
+	t.Helper()
+	cfg := Default()
+	err := p.Apply(context.Background(), cfg)
+	return cfg, err
+}
+
+func TestHTTPProviderETag(t *testing.T) {
+	s, url := newConfigServer(t, "cdn:\n  host: first.example.com\n", `"v1"`)
+	p := NewHTTPProvider(HTTPOptions{URL: url})
+	ctx := context.Background()
+
+	if changed, err := p.Poll(ctx); err != nil || !changed {
+		t.Fatalf("первый Poll = %v, %v; ожидается true, nil", changed, err)
+	}
+	if changed, err := p.Poll(ctx); err != nil || changed {
+		t.Fatalf("Poll с неизменным ETag = %v, %v; ожидается false, nil", changed, err)
+	}
+
+	s.set(func(s *configServer) { s.doc, s.etag = "cdn:\n  host: second.example.com\n", `"v2"` })
+	if changed, err := p.Poll(ctx); err != nil || !changed {
+		t.Fatalf("Poll после изменения = %v, %v; ожидается true, nil", changed, err)
+	}
+	cfg, err := applyRemote(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CDNHost != "second.example.com" {
+		t.Errorf("cdn.host = %q, ожидается second.example.com", cfg.CDNHost)
+	}
+	if got := cfg.Source("cdn.host"); got != p.Name() {
+		t.Errorf("источник cdn.host = %q, ожидается %q", got, p.Name())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{"", `"v1"`, `"v1"`, `"v2"`}
+	if strings.Join(s.ifMatch, " ") != strings.Join(want, " ") {
+		t.Errorf("If-None-Match = %q, ожидается %q", s.ifMatch, want)
+	}
+}
+
+func TestHTTPProviderNotModifiedWithoutDocument(t *testing.T) {
+	s, url := newConfigServer(t, "", "")
+	s.set(func(s *configServer) { s.status = http.StatusNotModified })
+	p := NewHTTPProvider(HTTPOptions{URL: url})
+	if _, err := p.Poll(context.Background()); err == nil {
+		t.Fatal("ожидается ошибка для 304 без предыдущего документа")
+	}
+}
+
+func TestHTTPProviderSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(doc string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(doc)))
+	}
+	good := "cdn:\n  host: signed.example.com\n"
+	forged := "cdn:\n  host: forged.example.com\n"
+
+	for _, tc := range []struct {
+		name    string
+		doc     string
+		sig     string
+		wantErr string
+	}{
+		{name: "верная подпись", doc: good, sig: sign(good)},
+		{name: "подпись другого документа", doc: forged, sig: sign(good), wantErr: "неверная подпись"},
+		{name: "без подписи", doc: forged, wantErr: "нет подписи"},
+		{name: "подпись не в base64", doc: forged, sig: "%%%", wantErr: "нет подписи"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, url := newConfigServer(t, tc.doc, "")
+			s.set(func(s *configServer) { s.sig = tc.sig })
+			p := NewHTTPProvider(HTTPOptions{URL: url, PublicKey: pub})
+			changed, err := p.Poll(context.Background())
+			if tc.wantErr == "" {
+				if err != nil || !changed {
+					t.Fatalf("Poll = %v, %v; ожидается true, nil", changed, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Poll: ошибка %v, ожидается %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	// Отклонённый документ не заменяет принятый ранее
+	s, url := newConfigServer(t, good, `"v1"`)
+	s.set(func(s *configServer) { s.sig = sign(good) })
+	p := NewHTTPProvider(HTTPOptions{URL: url, PublicKey: pub, Backoff: time.Nanosecond})
+	if _, err := p.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.set(func(s *configServer) { s.doc, s.etag = forged, `"v2"` })
+	cfg, err := applyRemote(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CDNHost != "signed.example.com" {
+		t.Errorf("cdn.host = %q, ожидается прежний signed.example.com", cfg.CDNHost)
+	}
+}
+
+func TestHTTPProviderRejectsRemoteKeys(t *testing.T) {
+	_, url := newConfigServer(t, "config:\n  remote:\n    url: http://other.example.com/\n", "")
+	p := NewHTTPProvider(HTTPOptions{URL: url})
+	_, err := p.Poll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "config.remote.url: задаётся только локально") {
+		t.Fatalf("Poll: ошибка %v, ожидается запрет config.remote.url", err)
+	}
+}
+
+func TestHTTPProviderFallback(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "remote.cache")
+	s, url := newConfigServer(t, "cdn:\n  host: good.example.com\n", `"v1"`)
+	opts := HTTPOptions{URL: url, CachePath: cache}
+
+	p := NewHTTPProvider(opts)
+	if _, err := applyRemote(t, p); err != nil {
+		t.Fatal(err)
+	}
+	p.commit()
+
+	s.set(func(s *configServer) { s.status = http.StatusInternalServerError })
+
+	// Тот же источник применяет последний полученный документ
+	cfg, err := applyRemote(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CDNHost != "good.example.com" {
+		t.Errorf("cdn.host = %q, ожидается последний полученный good.example.com", cfg.CDNHost)
+	}
+
+	// После перезапуска документ читается с диска
+	cfg, err = applyRemote(t, NewHTTPProvider(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CDNHost != "good.example.com" {
+		t.Errorf("cdn.host = %q, ожидается сохранённый good.example.com", cfg.CDNHost)
+	}
+
+	// Копия без подписи отклоняется, если задан ключ
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := opts
+	signed.PublicKey = pub
+	if _, err := applyRemote(t, NewHTTPProvider(signed)); err == nil {
+		t.Error("ожидается ошибка: сохранённая копия без подписи")
+	}
+
+	// Без копии на диске ошибка возвращается
+	empty := opts
+	empty.CachePath = filepath.Join(t.TempDir(), "missing.cache")
+	if _, err := applyRemote(t, NewHTTPProvider(empty)); err == nil {
+		t.Error("ожидается ошибка: сервер недоступен и копии нет")
+	}
+}
+
+func TestHTTPProviderBackoff(t *testing.T) {
+	s, url := newConfigServer(t, "cdn:\n  host: good.example.com\n", `"v1"`)
+	s.set(func(s *configServer) { s.status = http.StatusServiceUnavailable })
+	const backoff = 50 * time.Millisecond
+	p := NewHTTPProvider(HTTPOptions{URL: url, Backoff: backoff, MaxBackoff: 4 * backoff})
+	ctx := context.Background()
+
+	for i, want := range []time.Duration{backoff, 2 * backoff, 4 * backoff, 4 * backoff} {
+		start := time.Now()
+		if _, err := p.Poll(ctx); err == nil {
+			t.Fatalf("ошибка %d: Poll без ошибки", i+1)
+		}
+		requests := s.count()
+		// Во время паузы URL не запрашивается, возвращается последняя ошибка
+		_, err := p.Poll(ctx)
+		if err == nil || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("ошибка %d: Poll во время паузы вернул %v", i+1, err)
+		}
+		if s.count() != requests {
+			t.Fatalf("ошибка %d: запрос во время паузы", i+1)
+		}
+		p.mu.Lock()
+		got := p.retryAt.Sub(start)
+		p.mu.Unlock()
+		if got < want || got > want+backoff {
+			t.Fatalf("пауза после ошибки %d = %v, ожидается %v", i+1, got, want)
+		}
+		time.Sleep(time.Until(start.Add(got)))
+	}
+
+	// Успешный запрос сбрасывает паузу
+	s.set(func(s *configServer) { s.status = 0 })
+	if changed, err := p.Poll(ctx); err != nil || !changed {
+		t.Fatalf("Poll после восстановления = %v, %v", changed, err)
+	}
+	if changed, err := p.Poll(ctx); err != nil || changed {
+		t.Fatalf("следующий Poll = %v, %v; ожидается false, nil без паузы", changed, err)
+	}
+
+	// Явная перезагрузка обращается к URL и во время паузы
+	s.set(func(s *configServer) { s.status = http.StatusServiceUnavailable })
+	if _, err := p.Poll(ctx); err == nil {
+		t.Fatal("ожидается ошибка")
+	}
+	requests := s.count()
+	if _, err := applyRemote(t, p); err != nil {
+		t.Fatal(err)
+	}
+	if s.count() != requests+1 {
+		t.Errorf("Apply во время паузы не запросил URL")
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	p := NewHTTPProvider(HTTPOptions{URL: "http://localhost/"})
+	for n, want := range map[int]time.Duration{
+		1:  remoteBackoff,
+		2:  2 * remoteBackoff,
+		3:  4 * remoteBackoff,
+		10: remoteMaxBackoff,
+		64: remoteMaxBackoff,
+	} {
+		if got := p.backoff(n); got != want {
+			t.Errorf("backoff(%d) = %v, ожидается %v", n, got, want)
+		}
+	}
+}
+
+// Документ, с которым конфигурация не проходит проверку, не становится действующим:
+// перезагрузки продолжают использовать прежний, пока сервер не отдаст исправленный
+func TestLoaderRejectsInvalidRemote(t *testing.T) {
+	s, url := newConfigServer(t, "cdn:\n  host: good.example.com\n", `"v1"`)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "balancer.yaml")
+	writeFile := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("server:\n  domain: one.example.com\n")
+	loader := NewLoader([]string{
+		"-config", file,
+		"-config.remote.url", url,
+		"-config.remote.cache_path", filepath.Join(dir, "remote.cache"),
+	})
+	ctx := context.Background()
+	load := func(wantHost string) {
+		t.Helper()
+		cfg, err := loader.Load(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.CDNHost != wantHost {
+			t.Fatalf("cdn.host = %q, ожидается %q", cfg.CDNHost, wantHost)
+		}
+	}
+	poll := func(want bool) {
+		t.Helper()
+		if changed, err := loader.Poll(ctx); err != nil || changed != want {
+			t.Fatalf("Poll = %v, %v; ожидается %v, nil", changed, err, want)
+		}
+	}
+	load("good.example.com")
+
+	// Пустой cdn.host не проходит Validate
+	s.set(func(s *configServer) { s.doc, s.etag = "cdn:\n  host: \"\"\n", `"v2"` })
+	poll(true)
+	if _, err := loader.Load(ctx); err == nil || !strings.Contains(err.Error(), "cdn.host") {
+		t.Fatalf("Load: ошибка %v, ожидается ошибка cdn.host", err)
+	}
+	poll(false)
+
+	// Перезагрузка из-за локального файла использует прежний документ
+	writeFile("server:\n  domain: two.example.com\n")
+	load("good.example.com")
+	poll(false)
+
+	// Исправленный документ применяется
+	s.set(func(s *configServer) { s.doc, s.etag = "cdn:\n  host: fixed.example.com\n", `"v3"` })
+	poll(true)
+	load("fixed.example.com")
+
+	// Ошибка в локальном файле не отклоняет новый удалённый документ
+	s.set(func(s *configServer) { s.doc, s.etag = "cdn:\n  host: next.example.com\n", `"v4"` })
+	writeFile("server:\n  unknown: 1\n")
+	poll(true)
+	if _, err := loader.Load(ctx); err == nil {
+		t.Fatal("ожидается ошибка локального файла")
+	}
+	writeFile("server:\n  domain: two.example.com\n")
+	load("next.example.com")
+}
@@ -0,0 +1,435 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field — параметр конфигурации, описанный тегами поля Config
+type field struct {
+	key, env, def, desc string
+	enum                []string
+	min, max            string
+	sep                 string // Разделитель элементов списка в env и флагах
+	hot                 bool   // Применяется при перезагрузке без перезапуска
+	secret              bool   // Значение не выводится в логи и сравнение конфигураций
+	index               []int
+	typ                 reflect.Type
+}
+
+var (
+	fields      = parseFields()
+	fieldsByKey = indexFields(fields)
+
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func parseFields() []*field {
+	t := reflect.TypeOf(Config{})
+	var list []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		f := &field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			desc:   sf.Tag.Get("desc"),
+			min:    sf.Tag.Get("min"),
+			max:    sf.Tag.Get("max"),
+			sep:    sf.Tag.Get("sep"),
+			hot:    sf.Tag.Get("reload") == "hot",
+			secret: sf.Tag.Get("secret") == "true",
+			index:  sf.Index,
+			typ:    sf.Type,
+		}
+		if enum := sf.Tag.Get("enum"); enum != "" {
+			f.enum = strings.Split(enum, ",")
+		}
+		if f.sep == "" {
+			f.sep = ","
+		}
+		list = append(list, f)
+	}
+	return list
+}
+
+func indexFields(list []*field) map[string]*field {
+	m := make(map[string]*field, len(list))
+	for _, f := range list {
+		if _, ok := m[f.key]; ok {
+			panic("config: повторяющийся ключ " + f.key)
+		}
+		m[f.key] = f
+	}
+	return m
+}
+
+// Подстановка в значения по умолчанию, зависящие от окружения
+func expandDefault(def string) string {
+	return strings.ReplaceAll(def, "${TMPDIR}", strings.TrimRight(os.TempDir(), "/"))
+}
+
+func (f *field) value(c *Config) reflect.Value {
+	return reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+}
+
+// set разбирает строковое значение из переменной окружения, флага или значения по умолчанию
+func (f *field) set(c *Config, raw string) error {
+	v := f.value(c)
+	switch {
+	case f.typ.Kind() == reflect.Slice:
+		return f.setList(v, splitList(raw, f.sep))
+	case f.typ.Kind() == reflect.Map:
+		items := make(map[string]string)
+		for _, item := range splitList(raw, f.sep) {
+			k, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("элемент %q: ожидается ключ=значение", item)
+			}
+			items[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		return f.setMap(v, items)
+	}
+	return setScalar(v, raw)
+}
+
+// setAny применяет значение из файла: скаляр, список или словарь
+func (f *field) setAny(c *Config, value any) error {
+	v := f.value(c)
+	switch x := value.(type) {
+	case nil:
+		v.Set(reflect.Zero(f.typ))
+		return nil
+	case []any:
+		if f.typ.Kind() != reflect.Slice {
+			return fmt.Errorf("ожидается одно значение, а не список")
+		}
+		items := make([]string, 0, len(x))
+		for _, item := range x {
+			items = append(items, scalarString(item))
+		}
+		return f.setList(v, items)
+	case map[string]any:
+		if f.typ.Kind() != reflect.Map {
+			return fmt.Errorf("ожидается одно значение, а не словарь")
+		}
+		items := make(map[string]string, len(x))
+		for k, item := range x {
+			items[k] = scalarString(item)
+		}
+		return f.setMap(v, items)
+	}
+	if f.typ.Kind() == reflect.Slice {
+		return f.setList(v, splitList(scalarString(value), f.sep))
+	}
+	if f.typ.Kind() == reflect.Map {
+		return fmt.Errorf("ожидается словарь")
+	}
+	return setScalar(v, scalarString(value))
+}
+
+func (f *field) setList(v reflect.Value, items []string) error {
+	if len(items) == 0 {
+		v.Set(reflect.Zero(f.typ))
+		return nil
+	}
+	v.Set(reflect.ValueOf(items))
+	return nil
+}
+
+func (f *field) setMap(v reflect.Value, items map[string]string) error {
+	m := reflect.MakeMapWithSize(f.typ, len(items))
+	for k, raw := range items {
+		elem := reflect.New(f.typ.Elem()).Elem()
+		if err := setScalar(elem, raw); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(k), elem)
+	}
+	v.Set(m)
+	return nil
+}
+
+func setScalar(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+// Строковое представление скаляра из YAML или JSON
+func scalarString(value any) string {
+	switch x := value.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// splitList разбивает список по разделителю; пустые элементы пропускаются
+func splitList(raw, sep string) []string {
+	var list []string
+	for _, item := range strings.Split(raw, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Разворачивание вложенных секций документа в ключи через точку
+func flatten(prefix string, doc map[string]any, out map[string]any, errs *[]error) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		section, isMap := v.(map[string]any)
+		if f, ok := fieldsByKey[key]; isMap && (!ok || f.typ.Kind() != reflect.Map) {
+			if ok {
+				*errs = append(*errs, fmt.Errorf("%s: ожидается значение, а не секция", key))
+				continue
+			}
+			flatten(key, section, out, errs)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// Является ли key секцией, содержащей параметры
+func isSection(key string) bool {
+	for _, f := range fields {
+		if strings.HasPrefix(f.key, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Ошибки разбора файла упорядочиваются, чтобы вывод не зависел от порядка обхода словаря
+func sortErrors(errs []error) []error {
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// Текстовое представление значения для проверки enum
+func textValue(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// check проверяет ограничения enum, min и max
+func (f *field) check(c *Config) error {
+	v := f.value(c)
+
+	if len(f.enum) > 0 {
+		values := []reflect.Value{v}
+		if v.Kind() == reflect.Map {
+			values = values[:0]
+			iter := v.MapRange()
+			for iter.Next() {
+				values = append(values, iter.Value())
+			}
+		}
+		for _, value := range values {
+			text := strings.ToLower(textValue(value))
+			if !contains(f.enum, text) {
+				return fmt.Errorf("значение %q не входит в список допустимых: %s", text, strings.Join(f.enum, ", "))
+			}
+		}
+	}
+
+	if f.min == "" && f.max == "" {
+		return nil
+	}
+	var value, lo, hi float64
+	var parse func(string) (float64, error)
+	switch {
+	case f.typ == durationType:
+		value = float64(v.Int())
+		parse = func(s string) (float64, error) {
+			d, err := time.ParseDuration(s)
+			return float64(d), err
+		}
+	case v.Kind() == reflect.Int:
+		value = float64(v.Int())
+		parse = func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	case v.Kind() == reflect.Float64:
+		value = v.Float()
+		parse = func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	default:
+		return nil
+	}
+	if f.min != "" {
+		lo, _ = parse(f.min)
+		if value < lo {
+			return fmt.Errorf("значение %s меньше минимального %s", textValue(v), f.min)
+		}
+	}
+	if f.max != "" {
+		hi, _ = parse(f.max)
+		if value > hi {
+			return fmt.Errorf("значение %s больше максимального %s", textValue(v), f.max)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema возвращает JSON Schema файла конфигурации
+func Schema() []byte {
+	root := map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Конфигурация videobalance",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           map[string]any{},
+	}
+	for _, f := range fields {
+		node := root
+		parts := strings.Split(f.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			props := node["properties"].(map[string]any)
+			next, ok := props[part].(map[string]any)
+			if !ok {
+				next = map[string]any{"type": "object", "additionalProperties": false, "properties": map[string]any{}}
+				props[part] = next
+			}
+			node = next
+		}
+		node["properties"].(map[string]any)[parts[len(parts)-1]] = f.schema()
+	}
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append(data, '\n')
+}
+
+func (f *field) schema() map[string]any {
+	desc := f.desc
+	if f.env != "" {
+		desc += ". Переменная окружения " + f.env
+	}
+	if f.hot {
+		desc += ". Применяется без перезапуска"
+	}
+	s := map[string]any{"description": desc}
+
+	scalar := func(t reflect.Type) map[string]any {
+		switch {
+		case t == durationType:
+			return map[string]any{"type": "string", "pattern": `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`}
+		case reflect.PointerTo(t).Implements(textUnmarshalerType), t.Kind() == reflect.String:
+			return map[string]any{"type": "string"}
+		case t.Kind() == reflect.Int:
+			return map[string]any{"type": "integer"}
+		case t.Kind() == reflect.Float64:
+			return map[string]any{"type": "number"}
+		case t.Kind() == reflect.Bool:
+			return map[string]any{"type": "boolean"}
+		}
+		return map[string]any{}
+	}
+
+	switch f.typ.Kind() {
+	case reflect.Slice:
+		s["type"] = "array"
+		s["items"] = scalar(f.typ.Elem())
+	case reflect.Map:
+		values := scalar(f.typ.Elem())
+		if len(f.enum) > 0 {
+			values["enum"] = f.enum
+		}
+		s["type"] = "object"
+		s["additionalProperties"] = values
+	default:
+		for k, v := range scalar(f.typ) {
+			s[k] = v
+		}
+		if len(f.enum) > 0 {
+			s["enum"] = f.enum
+		}
+		if f.typ != durationType {
+			if f.min != "" {
+				s["minimum"] = json.Number(f.min)
+			}
+			if f.max != "" {
+				s["maximum"] = json.Number(f.max)
+			}
+		}
+	}
+
+	if f.def != "" {
+		switch s["type"] {
+		case "integer", "number", "boolean":
+			s["default"] = json.RawMessage(f.def)
+		default:
+			s["default"] = expandDefault(f.def)
+		}
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"videobalance/internal/apierr"
+)
+
+const authorizationHeader = "authorization"
+
+// AuthOptions задаёт проверку токенов Bearer из метаданных authorization.
+// Методы задаются полным именем или его префиксом, например /videobalance.Admin/.
+// TenantTokens и GatewayTokens принимаются там же, где Tokens.
+type AuthOptions struct {
+	Tokens        []string          // Токены для методов вне Restricted; пусто — эти методы без проверки
+	AdminTokens   []string          // Токены для всех методов; пусто — Restricted принимает Tokens
+	TenantTokens  map[string]string // Арендатор → токен: арендатор вызова определяется по токену
+	GatewayTokens []string          // Токены доверенного шлюза, который передаёт арендатора и приоритет в метаданных
+	Restricted    []string          // Методы, требующие AdminTokens
+	Exempt        []string          // Методы без проверки, например /grpc.health.v1.Health/
+}
+
+// Caller — клиент, определённый по токену вызова
+type Caller struct {
+	Tenant  string // Арендатор из TenantTokens
+	Gateway bool   // Токен из GatewayTokens
+	Admin   bool   // Токен из AdminTokens
+}
+
+type callerKey struct{}
+
+// CallerFrom возвращает клиента, определённого Auth по токену вызова.
+// ok == false, если токена нет, он неизвестен или Auth не используется.
+func CallerFrom(ctx context.Context) (caller Caller, ok bool) {
+	caller, ok = ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// Auth проверяет токен вызова. Без токена или с неизвестным токеном вызов отклоняется
+// с UNAUTHENTICATED, а с токеном без доступа к методу из Restricted — с PERMISSION_DENIED.
+// Клиент, определённый по токену, доступен обработчику через CallerFrom.
+// Если токены не заданы, проверка не выполняется. Одинаковый токен у нескольких
+// арендаторов — ошибка конфигурации, и Auth паникует.
+func Auth(opts AuthOptions) Interceptor {
+	if len(opts.Tokens) == 0 && len(opts.AdminTokens) == 0 && len(opts.TenantTokens) == 0 && len(opts.GatewayTokens) == 0 {
+		return Interceptor{}
+	}
+	// Иначе арендатор вызова зависел бы от порядка обхода TenantTokens
+	tenants := make(map[string]string, len(opts.TenantTokens))
+	for tenant, token := range opts.TenantTokens {
+		if other, dup := tenants[token]; dup {
+			panic(fmt.Sprintf("interceptor: у арендаторов %s и %s одинаковый токен", min(tenant, other), max(tenant, other)))
+		}
+		tenants[token] = tenant
+	}
+	return fromAround(func(ctx context.Context, fullMethod string, next func(context.Context) error) error {
+		caller, known, err := opts.check(ctx, fullMethod)
+		if err != nil {
+			return err
+		}
+		if known {
+			ctx = context.WithValue(ctx, callerKey{}, caller)
+		}
+		return next(ctx)
+	})
+}
+
+func (o *AuthOptions) check(ctx context.Context, fullMethod string) (Caller, bool, error) {
+	if matchMethod(o.Exempt, fullMethod) {
+		return Caller{}, false, nil
+	}
+	token, hasToken := bearerToken(ctx)
+	var caller Caller
+	var known bool
+	if hasToken {
+		caller, known = o.identify(token)
+	}
+
+	restricted := matchMethod(o.Restricted, fullMethod)
+	if !restricted && len(o.Tokens) == 0 && len(o.TenantTokens) == 0 && len(o.GatewayTokens) == 0 {
+		return caller, known, nil
+	}
+	switch {
+	case !hasToken:
+		return Caller{}, false, status.Error(codes.Unauthenticated, "нужен токен доступа в метаданных authorization: Bearer <токен>")
+	case !known:
+		return Caller{}, false, status.Error(codes.Unauthenticated, "неверный токен доступа")
+	case restricted && !caller.Admin && len(o.AdminTokens) > 0:
+		return Caller{}, false, apierr.Status(&apierr.Error{
+			Err:      fmt.Errorf("%w: токен не даёт доступа к методу %s", apierr.ErrForbidden, fullMethod),
+			Metadata: map[string]string{"method": fullMethod},
+		}).Err()
+	}
+	return caller, true, nil
+}
+
+// Определение клиента по токену. Токен сравнивается со всеми списками,
+// чтобы время проверки не зависело от того, в каком из них он найден.
+func (o *AuthOptions) identify(token string) (Caller, bool) {
+	caller := Caller{
+		Admin:   matchToken(o.AdminTokens, token),
+		Gateway: matchToken(o.GatewayTokens, token),
+	}
+	plain := matchToken(o.Tokens, token)
+	for tenant, t := range o.TenantTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			caller.Tenant = tenant
+		}
+	}
+	return caller, caller.Admin || caller.Gateway || plain || caller.Tenant != ""
+}
+
+// Токен из метаданных authorization: Bearer <токен>
+func bearerToken(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(authorizationHeader) {
+		scheme, token, ok := strings.Cut(v, " ")
+		if ok && strings.EqualFold(scheme, "bearer") && token != "" {
+			return strings.TrimSpace(token), true
+		}
+	}
+	return "", false
+}
+
+// Сравнение со всеми токенами за время, не зависящее от того, какой из них совпал
+func matchToken(tokens []string, token string) bool {
+	found := 0
+	for _, t := range tokens {
+		found |= subtle.ConstantTimeCompare([]byte(t), []byte(token))
+	}
+	return found == 1
+}
+
+func matchMethod(patterns []string, fullMethod string) bool {
+	for _, p := range patterns {
+		if strings.HasPrefix(fullMethod, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	balancerMethod = "/videobalance.Balancer/Redirect"
+	adminMethod    = "/videobalance.Admin/SetLogLevel"
+	healthMethod   = "/grpc.health.v1.Health/Check"
+)
+
+// Вызов унарного перехватчика с обработчиком, запоминающим контекст
+func callUnary(t *testing.T, i Interceptor, ctx context.Context, method string) (context.Context, bool, error) {
+	t.Helper()
+	var handled context.Context
+	_, err := i.Unary(ctx, "запрос", &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		handled = ctx
+		return "ответ", nil
+	})
+	return handled, handled != nil, err
+}
+
+func withToken(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", token))
+}
+
+func TestAuth(t *testing.T) {
+	full := AuthOptions{
+		Tokens:        []string{"user-token"},
+		AdminTokens:   []string{"admin-token"},
+		TenantTokens:  map[string]string{"acme": "acme-token"},
+		GatewayTokens: []string{"gateway-token"},
+		Restricted:    []string{"/videobalance.Admin/"},
+		Exempt:        []string{"/grpc.health.v1.Health/"},
+	}
+	adminOnly := AuthOptions{
+		AdminTokens: []string{"admin-token"},
+		Restricted:  []string{"/videobalance.Admin/"},
+		Exempt:      []string{"/grpc.health.v1.Health/"},
+	}
+	noAdmin := AuthOptions{
+		Tokens:     []string{"user-token"},
+		Restricted: []string{"/videobalance.Admin/"},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		opts       AuthOptions
+		method     string
+		auth       string
+		wantCode   codes.Code
+		wantCaller *Caller // nil — клиент не определён
+	}{
+		{name: "без токенов проверки нет", opts: AuthOptions{Restricted: []string{"/videobalance.Admin/"}}, method: adminMethod},
+		{name: "токен Balancer", opts: full, method: balancerMethod, auth: "Bearer user-token", wantCaller: &Caller{}},
+		{name: "схема без учёта регистра", opts: full, method: balancerMethod, auth: "bearer user-token", wantCaller: &Caller{}},
+		{name: "без токена", opts: full, method: balancerMethod, wantCode: codes.Unauthenticated},
+		{name: "не Bearer", opts: full, method: balancerMethod, auth: "Basic user-token", wantCode: codes.Unauthenticated},
+		{name: "неизвестный токен", opts: full, method: balancerMethod, auth: "Bearer other", wantCode: codes.Unauthenticated},
+		{name: "префикс токена", opts: full, method: balancerMethod, auth: "Bearer user-tok", wantCode: codes.Unauthenticated},
+		{name: "токен длиннее", opts: full, method: balancerMethod, auth: "Bearer user-token2", wantCode: codes.Unauthenticated},
+		{name: "арендатор", opts: full, method: balancerMethod, auth: "Bearer acme-token", wantCaller: &Caller{Tenant: "acme"}},
+		{name: "шлюз", opts: full, method: balancerMethod, auth: "Bearer gateway-token", wantCaller: &Caller{Gateway: true}},
+		{name: "администратор в Balancer", opts: full, method: balancerMethod, auth: "Bearer admin-token", wantCaller: &Caller{Admin: true}},
+		{name: "Admin с токеном администратора", opts: full, method: adminMethod, auth: "Bearer admin-token", wantCaller: &Caller{Admin: true}},
+		{name: "Admin с токеном Balancer", opts: full, method: adminMethod, auth: "Bearer user-token", wantCode: codes.PermissionDenied},
+		{name: "Admin с токеном арендатора", opts: full, method: adminMethod, auth: "Bearer acme-token", wantCode: codes.PermissionDenied},
+		{name: "Admin без токена", opts: full, method: adminMethod, wantCode: codes.Unauthenticated},
+		{name: "health без токена", opts: full, method: healthMethod},
+		{name: "health с неверным токеном", opts: full, method: healthMethod, auth: "Bearer other"},
+		{name: "только Admin: Balancer открыт", opts: adminOnly, method: balancerMethod},
+		{name: "только Admin: Balancer с токеном", opts: adminOnly, method: balancerMethod, auth: "Bearer admin-token", wantCaller: &Caller{Admin: true}},
+		{name: "только Admin: Admin без токена", opts: adminOnly, method: adminMethod, wantCode: codes.Unauthenticated},
+		{name: "без AdminTokens Admin принимает Tokens", opts: noAdmin, method: adminMethod, auth: "Bearer user-token", wantCaller: &Caller{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			auth := Auth(tc.opts)
+			if auth.Unary == nil {
+				// Без токенов перехватчик не нужен
+				if tc.wantCode != codes.OK || tc.wantCaller != nil {
+					t.Fatal("перехватчик не создан")
+				}
+				return
+			}
+			ctx, handled, err := callUnary(t, auth, withToken(tc.auth), tc.method)
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("код %v, ожидается %v (%v)", code, tc.wantCode, err)
+			}
+			if handled != (tc.wantCode == codes.OK) {
+				t.Fatalf("обработчик вызван: %v", handled)
+			}
+			if !handled {
+				return
+			}
+			caller, ok := CallerFrom(ctx)
+			if ok != (tc.wantCaller != nil) {
+				t.Fatalf("CallerFrom ok = %v, ожидается %v", ok, tc.wantCaller != nil)
+			}
+			if ok && caller != *tc.wantCaller {
+				t.Errorf("клиент %+v, ожидается %+v", caller, *tc.wantCaller)
+			}
+		})
+	}
+}
+
+// Токен сверяется со всеми списками: совпадение в нескольких даёт все права,
+// а порядок токенов в списке не влияет на результат
+func TestAuthIdentify(t *testing.T) {
+	opts := AuthOptions{
+		Tokens:        []string{"a", "shared"},
+		AdminTokens:   []string{"shared", "b"},
+		GatewayTokens: []string{"c", "shared"},
+		TenantTokens:  map[string]string{"acme": "shared", "live": "d"},
+	}
+	for _, tc := range []struct {
+		token  string
+		want   Caller
+		wantOK bool
+	}{
+		{token: "a", wantOK: true},
+		{token: "b", want: Caller{Admin: true}, wantOK: true},
+		{token: "c", want: Caller{Gateway: true}, wantOK: true},
+		{token: "d", want: Caller{Tenant: "live"}, wantOK: true},
+		{token: "shared", want: Caller{Tenant: "acme", Gateway: true, Admin: true}, wantOK: true},
+		{token: ""},
+		{token: "sharedx"},
+		{token: "share"},
+	} {
+		caller, ok := opts.identify(tc.token)
+		if ok != tc.wantOK || caller != tc.want {
+			t.Errorf("identify(%q) = %+v, %v; ожидается %+v, %v", tc.token, caller, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+// Арендатор определяется по токену однозначно: одинаковые токены отклоняются при создании
+func TestAuthDuplicateTenantTokens(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tokens  map[string]string
+		wantErr bool
+	}{
+		{name: "разные токены", tokens: map[string]string{"acme": "a", "live": "b"}},
+		{name: "одинаковые токены", tokens: map[string]string{"acme": "shared", "live": "shared", "news": "c"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if (r != nil) != tc.wantErr {
+					t.Fatalf("паника %v, ожидается %v", r, tc.wantErr)
+				}
+				if msg, _ := r.(string); tc.wantErr && !strings.Contains(msg, "acme и live") {
+					t.Errorf("сообщение %q не называет арендаторов", msg)
+				}
+			}()
+			Auth(AuthOptions{TenantTokens: tc.tokens})
+		})
+	}
+}
+
+func TestMatchToken(t *testing.T) {
+	tokens := []string{"first", "second-token", "third"}
+	for _, tc := range []struct {
+		token string
+		want  bool
+	}{
+		{"first", true},
+		{"second-token", true},
+		{"third", true},
+		{"second", false},
+		{"second-token-", false},
+		{"THIRD", false},
+		{"", false},
+	} {
+		if got := matchToken(tokens, tc.token); got != tc.want {
+			t.Errorf("matchToken(%q) = %v, ожидается %v", tc.token, got, tc.want)
+		}
+	}
+	if matchToken(nil, "") {
+		t.Error("пустой список не должен принимать пустой токен")
+	}
+}
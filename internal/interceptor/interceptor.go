@@ -0,0 +1,151 @@
+// Package interceptor содержит перехватчики gRPC для сквозных задач: восстановление
+// после паники, идентификатор запроса, логирование, ограничение срока вызова и авторизацию.
+// Каждый перехватчик создаётся отдельно и собирается в цепочку функцией Chain.
+package interceptor
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"videobalance/internal/logs"
+)
+
+// Interceptor — перехватчик унарных и потоковых вызовов; любой из них может быть nil
+type Interceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Chain собирает перехватчики в параметры сервера. Первый в списке перехватчик — внешний:
+// он получает вызов первым и видит результат остальных.
+func Chain(list ...Interceptor) []grpc.ServerOption {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	for _, i := range list {
+		if i.Unary != nil {
+			unary = append(unary, i.Unary)
+		}
+		if i.Stream != nil {
+			stream = append(stream, i.Stream)
+		}
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+}
+
+// around выполняет вызов next (с изменённым при необходимости контекстом) и может заменить его ошибку
+type around func(ctx context.Context, fullMethod string, next func(context.Context) error) error
+
+// Перехватчик унарных и потоковых вызовов из общей функции
+func fromAround(f around) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			var resp any
+			err := f(ctx, info.FullMethod, func(ctx context.Context) error {
+				var err error
+				resp, err = handler(ctx, req)
+				return err
+			})
+			return resp, err
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return f(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+				if ctx == ss.Context() {
+					return handler(srv, ss)
+				}
+				return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+			})
+		},
+	}
+}
+
+// Поток с контекстом, изменённым перехватчиком
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// Recovery превращает панику обработчика в ошибку INTERNAL; стек пишется в лог
+// (метод и идентификатор запроса добавляет к записи Logging)
+func Recovery(logger *slog.Logger) Interceptor {
+	return fromAround(func(ctx context.Context, _ string, next func(context.Context) error) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.ErrorContext(ctx, "Паника при обработке вызова gRPC", "паника", p, "стек", string(debug.Stack()))
+				err = status.Error(codes.Internal, "внутренняя ошибка сервера")
+			}
+		}()
+		return next(ctx)
+	})
+}
+
+// Logging добавляет идентификатор запроса и метод к записям логов, сделанным с контекстом вызова,
+// и пишет завершение вызова: ошибки сервера — с уровнем warn, остальные вызовы — debug.
+// Идентификатор берётся из AssignRequestID, поэтому Logging ставится после него.
+func Logging(logger *slog.Logger) Interceptor {
+	return fromAround(func(ctx context.Context, fullMethod string, next func(context.Context) error) error {
+		ctx = logs.ContextWith(ctx, "request_id", RequestID(ctx), "method", fullMethod)
+		start := time.Now()
+		err := next(ctx)
+
+		code := status.Code(err)
+		level := slog.LevelDebug
+		if serverFault(code) {
+			level = slog.LevelWarn
+		}
+		if logger.Enabled(ctx, level) {
+			args := []any{"код", code.String(), "время", time.Since(start)}
+			if err != nil {
+				args = append(args, "ошибка", err)
+			}
+			logger.Log(ctx, level, "Вызов gRPC завершён", args...)
+		}
+		return err
+	})
+}
+
+// Ошибки, которые указывают на сбой сервера, а не на неверный запрос
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// DeadlineOptions задаёт срок унарных вызовов
+type DeadlineOptions struct {
+	Default time.Duration // Срок вызова без deadline клиента (0 — без срока)
+	Max     time.Duration // Больший deadline клиента сокращается до Max (0 — без ограничения)
+}
+
+// Deadline ограничивает срок унарных вызовов. Потоковые вызовы (прогрев кэша) могут
+// длиться долго, и их срок задаёт клиент. Вызов с уже истёкшим сроком не выполняется.
+func Deadline(opts DeadlineOptions) Interceptor {
+	unary := fromAround(func(ctx context.Context, _ string, next func(context.Context) error) error {
+		timeout := opts.Default
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+			if timeout <= 0 {
+				return status.Error(codes.DeadlineExceeded, "срок вызова истёк до начала обработки")
+			}
+		}
+		if opts.Max > 0 && (timeout <= 0 || timeout > opts.Max) {
+			timeout = opts.Max
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return next(ctx)
+	}).Unary
+	return Interceptor{Unary: unary}
+}
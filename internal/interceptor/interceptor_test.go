@@ -0,0 +1,122 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Поток без соединения: перехватчикам нужен только контекст
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func TestRecovery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		handler  func() error
+		wantCode codes.Code
+		wantLog  bool
+	}{
+		{name: "без ошибки", handler: func() error { return nil }},
+		{name: "ошибка обработчика", handler: func() error { return status.Error(codes.NotFound, "нет") }, wantCode: codes.NotFound},
+		{name: "паника", handler: func() error { panic("сбой") }, wantCode: codes.Internal, wantLog: true},
+		{name: "паника с ошибкой", handler: func() error { panic(errors.New("сбой")) }, wantCode: codes.Internal, wantLog: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			recovery := Recovery(slog.New(slog.NewTextHandler(&buf, nil)))
+
+			_, err := recovery.Unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: balancerMethod},
+				func(context.Context, any) (any, error) { return nil, tc.handler() })
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("унарный вызов: код %v, ожидается %v", code, tc.wantCode)
+			}
+
+			err = recovery.Stream(nil, &fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/videobalance.Admin/Warm"},
+				func(any, grpc.ServerStream) error { return tc.handler() })
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("потоковый вызов: код %v, ожидается %v", code, tc.wantCode)
+			}
+
+			logged := strings.Count(buf.String(), "Паника при обработке вызова gRPC")
+			if tc.wantLog && (logged != 2 || !strings.Contains(buf.String(), "сбой")) {
+				t.Errorf("паника не записана в лог: %s", buf.String())
+			}
+			if !tc.wantLog && logged != 0 {
+				t.Errorf("лишняя запись в логе: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	const slack = time.Second // Запас на время между созданием контекста и проверкой
+	for _, tc := range []struct {
+		name     string
+		opts     DeadlineOptions
+		client   time.Duration // Deadline клиента; 0 — без deadline
+		want     time.Duration // Ожидаемый срок в обработчике; 0 — без срока
+		wantCode codes.Code
+	}{
+		{name: "без сроков"},
+		{name: "срок по умолчанию", opts: DeadlineOptions{Default: 30 * time.Second}, want: 30 * time.Second},
+		{name: "только максимум", opts: DeadlineOptions{Max: time.Minute}, want: time.Minute},
+		{name: "по умолчанию больше максимума", opts: DeadlineOptions{Default: 2 * time.Minute, Max: time.Minute}, want: time.Minute},
+		{name: "срок клиента меньше максимума", opts: DeadlineOptions{Default: 30 * time.Second, Max: time.Minute}, client: 10 * time.Second, want: 10 * time.Second},
+		{name: "срок клиента больше максимума", opts: DeadlineOptions{Max: time.Minute}, client: 5 * time.Minute, want: time.Minute},
+		{name: "срок клиента без максимума", opts: DeadlineOptions{Default: 30 * time.Second}, client: 5 * time.Minute, want: 5 * time.Minute},
+		{name: "срок клиента истёк", opts: DeadlineOptions{Default: 30 * time.Second}, client: -time.Second, wantCode: codes.DeadlineExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.client != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.client)
+				defer cancel()
+			}
+
+			var got time.Duration
+			var hasDeadline, handled bool
+			_, err := Deadline(tc.opts).Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: balancerMethod},
+				func(ctx context.Context, _ any) (any, error) {
+					handled = true
+					var deadline time.Time
+					deadline, hasDeadline = ctx.Deadline()
+					got = time.Until(deadline)
+					return nil, nil
+				})
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("код %v, ожидается %v", code, tc.wantCode)
+			}
+			if handled != (tc.wantCode == codes.OK) {
+				t.Fatalf("обработчик вызван: %v", handled)
+			}
+			if !handled {
+				return
+			}
+			if hasDeadline != (tc.want > 0) {
+				t.Fatalf("срок задан: %v, ожидается %v", hasDeadline, tc.want > 0)
+			}
+			if hasDeadline && (got > tc.want || got < tc.want-slack) {
+				t.Errorf("срок %v, ожидается %v", got, tc.want)
+			}
+		})
+	}
+
+	// Потоковые вызовы срок не получают
+	if Deadline(DeadlineOptions{Default: time.Second, Max: time.Second}).Stream != nil {
+		t.Error("Deadline не должен ограничивать потоковые вызовы")
+	}
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RequestIDHeader — заголовок запроса и ответа с идентификатором запроса
+	RequestIDHeader = "x-request-id"
+
+	maxRequestIDLen = 128 // Более длинный идентификатор клиента заменяется новым
+)
+
+type requestIDKey struct{}
+
+// AssignRequestID назначает вызову идентификатор: из метаданных x-request-id или новый.
+// Идентификатор доступен обработчику через RequestID и возвращается клиенту в заголовке ответа.
+func AssignRequestID() Interceptor {
+	return fromAround(func(ctx context.Context, _ string, next func(context.Context) error) error {
+		id := RequestID(ctx)
+		// Ошибка означает, что заголовки уже отправлены или вызов не gRPC
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+		return next(context.WithValue(ctx, requestIDKey{}, id))
+	})
+}
+
+// RequestID возвращает идентификатор, назначенный AssignRequestID. Без перехватчика
+// он берётся из метаданных x-request-id, а при их отсутствии генерируется новый.
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxRequestIDLen {
+			return ids[0]
+		}
+	}
+	return newRequestID()
+}
+
+// newRequestID генерирует случайный идентификатор из 16 hex-символов
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,73 @@
+package interceptor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var generatedID = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestAssignRequestID(t *testing.T) {
+	long := strings.Repeat("a", maxRequestIDLen)
+	for _, tc := range []struct {
+		name string
+		md   []string
+		want string // Пусто — ожидается новый идентификатор
+	}{
+		{name: "без метаданных"},
+		{name: "идентификатор клиента", md: []string{RequestIDHeader, "client-id-1"}, want: "client-id-1"},
+		{name: "первый из нескольких", md: []string{RequestIDHeader, "first", RequestIDHeader, "second"}, want: "first"},
+		{name: "пустой заменяется", md: []string{RequestIDHeader, ""}},
+		{name: "ровно 128 символов", md: []string{RequestIDHeader, long}, want: long},
+		{name: "длиннее 128 символов заменяется", md: []string{RequestIDHeader, long + "b"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(tc.md...))
+			}
+
+			var first, second string
+			_, err := AssignRequestID().Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: balancerMethod},
+				func(ctx context.Context, _ any) (any, error) {
+					first, second = RequestID(ctx), RequestID(ctx)
+					return nil, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first != second {
+				t.Fatalf("идентификатор меняется внутри вызова: %q и %q", first, second)
+			}
+			check := func(where, id string) {
+				t.Helper()
+				if tc.want != "" && id != tc.want {
+					t.Errorf("%s: идентификатор %q, ожидается %q", where, id, tc.want)
+				}
+				if tc.want == "" && !generatedID.MatchString(id) {
+					t.Errorf("%s: идентификатор %q, ожидается новый из 16 hex-символов", where, id)
+				}
+			}
+			check("перехватчик", first)
+			// Без перехватчика RequestID разбирает метаданные по тем же правилам
+			check("без перехватчика", RequestID(ctx))
+		})
+	}
+}
+
+// Новые идентификаторы не повторяются
+func TestNewRequestID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := newRequestID()
+		if seen[id] {
+			t.Fatalf("повтор идентификатора %q", id)
+		}
+		seen[id] = true
+	}
+}
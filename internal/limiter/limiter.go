@@ -0,0 +1,274 @@
+package limiter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultInitialLimit = 100                    // Начальный лимит параллельных запросов
+	defaultMinLimit     = 10                     // Нижняя граница лимита
+	defaultMaxLimit     = 5000                   // Верхняя граница лимита
+	defaultTolerance    = 1.5                    // Допустимый рост задержки относительно базовой
+	defaultSmoothing    = 0.2                    // Доля нового значения при пересчёте лимита
+	defaultWindow       = 100 * time.Millisecond // Период пересчёта лимита
+	minWindowSamples    = 10                     // Минимум измерений в окне для пересчёта без отказов
+	baselineDrift       = 0.001                  // Рост базовой задержки за окно, чтобы учитывать её изменение (≈1%/с)
+	backoffRatio        = 0.9                    // Уменьшение лимита при отказах из-за перегрузки
+)
+
+// Outcome — результат запроса для расчёта лимита
+type Outcome int
+
+const (
+	Success Outcome = iota // Запрос обработан, задержка учитывается
+	Dropped                // Запрос не успел или упал из-за перегрузки, лимит уменьшается
+	Ignore                 // Запрос не характеризует нагрузку (например, клиент отменил его)
+)
+
+// Options задаёт параметры ограничителя. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	Tolerance    float64       // Во сколько раз кратковременная задержка может превышать базовую без уменьшения лимита
+	Smoothing    float64       // Сглаживание изменений лимита (0..1]
+	Window       time.Duration // Период пересчёта лимита
+
+	// Гарантированная доля лимита для приоритета (0..1). Пока лимит не исчерпан,
+	// запросы любого приоритета занимают свободные места; при исчерпанном лимите
+	// запрос принимается, только если его приоритет не превысил свою долю.
+	Reservations map[Priority]float64
+}
+
+// Stats — текущее состояние ограничителя
+type Stats struct {
+	Limit    int
+	InFlight int
+	Rejected uint64        // Отклонённые запросы с момента запуска
+	ShortRTT time.Duration // Средняя задержка за последнее окно
+	BaseRTT  time.Duration // Базовая задержка без нагрузки
+
+	Priorities []PriorityStats
+}
+
+// PriorityStats — состояние запросов одного приоритета
+type PriorityStats struct {
+	Priority    string
+	Reservation float64 // Гарантированная доля лимита
+	InFlight    int
+	Rejected    uint64
+}
+
+// Limiter адаптивно ограничивает количество параллельных запросов.
+// Раз в окно лимит пересчитывается по отношению базовой задержки к кратковременной:
+// пока задержка не растёт, лимит увеличивается на √limit, с ростом задержки —
+// уменьшается пропорционально. Базовая задержка — минимальная средняя задержка окна,
+// которая медленно растёт, чтобы учитывать изменение времени обработки. Отказы из-за
+// перегрузки уменьшают лимит мультипликативно. Запросы сверх лимита отклоняются сразу,
+// в первую очередь запросы низкого приоритета (см. Options.Reservations).
+type Limiter struct {
+	opts         Options
+	reservations [numPriorities]float64
+	now          func() time.Time // Часы подменяются в тестах
+
+	limit    atomic.Int64
+	inFlight atomic.Int64
+	rejected atomic.Uint64
+
+	priorityInFlight [numPriorities]atomic.Int64
+	priorityRejected [numPriorities]atomic.Uint64
+
+	// Измерения текущего окна
+	rttSum      atomic.Int64 // Сумма задержек в наносекундах
+	samples     atomic.Int64
+	drops       atomic.Int64
+	maxInFlight atomic.Int64
+
+	lastUpdate atomic.Int64 // Время последнего пересчёта в наносекундах Unix
+	updating   atomic.Bool  // Пересчёт уже выполняется другой горутиной
+
+	// Состояние изменяется только в update под флагом updating
+	estimate float64 // Лимит без округления
+	baseRTT  float64 // Базовая задержка
+	shortRTT atomic.Int64
+	baseRTTn atomic.Int64 // baseRTT для Stats
+}
+
+// New создаёт ограничитель
+func New(opts Options) *Limiter {
+	return newLimiter(opts, time.Now)
+}
+
+func newLimiter(opts Options, now func() time.Time) *Limiter {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = defaultMinLimit
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = defaultMaxLimit
+	}
+	if opts.MaxLimit < opts.MinLimit {
+		opts.MaxLimit = opts.MinLimit
+	}
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = defaultInitialLimit
+	}
+	opts.InitialLimit = min(max(opts.InitialLimit, opts.MinLimit), opts.MaxLimit)
+	if opts.Tolerance < 1 {
+		opts.Tolerance = defaultTolerance
+	}
+	if opts.Smoothing <= 0 || opts.Smoothing > 1 {
+		opts.Smoothing = defaultSmoothing
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultWindow
+	}
+
+	l := &Limiter{opts: opts, estimate: float64(opts.InitialLimit), reservations: defaultReservations, now: now}
+	for p, share := range opts.Reservations {
+		if p >= PriorityLow && p < numPriorities && share >= 0 && share <= 1 {
+			l.reservations[p] = share
+		}
+	}
+	l.limit.Store(int64(opts.InitialLimit))
+	l.lastUpdate.Store(now().UnixNano())
+	return l
+}
+
+// Token — разрешение на выполнение запроса; Release вызывается ровно один раз
+type Token struct {
+	l        *Limiter
+	priority Priority
+	start    time.Time
+}
+
+// Acquire занимает место для запроса с приоритетом p. Если лимит исчерпан и приоритет
+// уже занял свою гарантированную долю, возвращает false без ожидания.
+func (l *Limiter) Acquire(p Priority) (Token, bool) {
+	p = p.clamp()
+	for {
+		current := l.inFlight.Load()
+		if limit := l.limit.Load(); current >= limit {
+			reserved := int64(float64(limit) * l.reservations[p])
+			if l.priorityInFlight[p].Load() >= reserved {
+				l.rejected.Add(1)
+				l.priorityRejected[p].Add(1)
+				return Token{}, false
+			}
+		}
+		if l.inFlight.CompareAndSwap(current, current+1) {
+			l.priorityInFlight[p].Add(1)
+			for {
+				peak := l.maxInFlight.Load()
+				if current+1 <= peak || l.maxInFlight.CompareAndSwap(peak, current+1) {
+					break
+				}
+			}
+			return Token{l: l, priority: p, start: l.now()}, true
+		}
+	}
+}
+
+// Release освобождает место и учитывает результат запроса
+func (t Token) Release(outcome Outcome) {
+	l := t.l
+	if l == nil {
+		return
+	}
+	l.inFlight.Add(-1)
+	l.priorityInFlight[t.priority].Add(-1)
+
+	now := l.now()
+	switch outcome {
+	case Success:
+		l.rttSum.Add(int64(now.Sub(t.start)))
+		l.samples.Add(1)
+	case Dropped:
+		l.drops.Add(1)
+	}
+	l.maybeUpdate(now)
+}
+
+// Пересчёт лимита не чаще раза в окно; выполняет одна из освобождающих горутин
+func (l *Limiter) maybeUpdate(now time.Time) {
+	last := l.lastUpdate.Load()
+	if now.UnixNano()-last < int64(l.opts.Window) {
+		return
+	}
+	if l.samples.Load() < minWindowSamples && l.drops.Load() == 0 {
+		return
+	}
+	if !l.updating.CompareAndSwap(false, true) {
+		return
+	}
+	defer l.updating.Store(false)
+	if !l.lastUpdate.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	l.update()
+}
+
+func (l *Limiter) update() {
+	sum := l.rttSum.Swap(0)
+	samples := l.samples.Swap(0)
+	drops := l.drops.Swap(0)
+	peak := l.maxInFlight.Swap(l.inFlight.Load())
+
+	limit := l.estimate
+	next := limit
+
+	if samples > 0 {
+		short := float64(sum) / float64(samples)
+		l.shortRTT.Store(int64(short))
+
+		if l.baseRTT == 0 || short < l.baseRTT {
+			l.baseRTT = short
+		} else {
+			l.baseRTT *= 1 + baselineDrift
+		}
+		l.baseRTTn.Store(int64(l.baseRTT))
+
+		gradient := math.Max(0.5, math.Min(1, l.opts.Tolerance*l.baseRTT/short))
+		next = limit*gradient + math.Sqrt(limit)
+	}
+	if drops > 0 {
+		next = math.Min(next, limit*backoffRatio)
+	}
+
+	// Лимит не растёт, если он не используется хотя бы наполовину
+	if next > limit && float64(peak) < limit/2 {
+		next = limit
+	}
+
+	limit = (1-l.opts.Smoothing)*limit + l.opts.Smoothing*next
+	limit = math.Max(float64(l.opts.MinLimit), math.Min(float64(l.opts.MaxLimit), limit))
+	l.estimate = limit
+	l.limit.Store(int64(limit))
+}
+
+// Limit возвращает текущий лимит
+func (l *Limiter) Limit() int {
+	return int(l.limit.Load())
+}
+
+// Stats возвращает текущее состояние ограничителя
+func (l *Limiter) Stats() Stats {
+	stats := Stats{
+		Limit:      int(l.limit.Load()),
+		InFlight:   int(l.inFlight.Load()),
+		Rejected:   l.rejected.Load(),
+		ShortRTT:   time.Duration(l.shortRTT.Load()),
+		BaseRTT:    time.Duration(l.baseRTTn.Load()),
+		Priorities: make([]PriorityStats, 0, numPriorities),
+	}
+	for p := PriorityCritical; p >= PriorityLow; p-- {
+		stats.Priorities = append(stats.Priorities, PriorityStats{
+			Priority:    p.String(),
+			Reservation: l.reservations[p],
+			InFlight:    int(l.priorityInFlight[p].Load()),
+			Rejected:    l.priorityRejected[p].Load(),
+		})
+	}
+	return stats
+}
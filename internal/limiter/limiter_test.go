@@ -0,0 +1,139 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+// Симуляция нагрузки на подменённых часах
+type simulation struct {
+	l   *Limiter
+	now time.Time
+}
+
+func newSimulation(opts Options) *simulation {
+	s := &simulation{now: time.Unix(1_700_000_000, 0)}
+	s.l = newLimiter(opts, func() time.Time { return s.now })
+	return s
+}
+
+// Одно окно пересчёта: занимается доля use текущего лимита, через rtt все запросы
+// завершаются с результатом outcome, затем часы доходят до конца окна
+func (s *simulation) window(use float64, rtt time.Duration, outcome Outcome) {
+	n := max(int(float64(s.l.Limit())*use), 1)
+	tokens := make([]Token, 0, n)
+	for i := 0; i < n; i++ {
+		token, ok := s.l.Acquire(PriorityNormal)
+		if !ok {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	s.now = s.now.Add(rtt)
+	for _, token := range tokens {
+		token.Release(outcome)
+	}
+	s.now = s.now.Add(s.l.opts.Window - rtt)
+}
+
+// Этап симуляции: windows окон с одинаковой нагрузкой
+type phase struct {
+	windows int
+	use     float64 // Занятая доля лимита
+	rtt     time.Duration
+	outcome Outcome
+}
+
+func TestLimit(t *testing.T) {
+	const rtt = 10 * time.Millisecond
+	warmup := phase{windows: 20, use: 1, rtt: rtt}
+
+	for _, tc := range []struct {
+		name   string
+		opts   Options
+		before []phase // Этапы до сравнения
+		after  phase   // Этап, после которого лимит сравнивается с прежним
+		want   int     // 1 — лимит растёт, -1 — уменьшается, 0 — не меняется
+		exact  int     // Если не 0, ожидаемое значение лимита после этапа
+	}{
+		{name: "рост при постоянной задержке", after: phase{windows: 20, use: 1, rtt: rtt}, want: 1},
+		{name: "рост продолжается после разогрева", before: []phase{warmup}, after: phase{windows: 20, use: 1, rtt: rtt}, want: 1},
+		{name: "уменьшение при росте задержки", before: []phase{warmup}, after: phase{windows: 10, use: 1, rtt: 4 * rtt}, want: -1},
+		{name: "рост в пределах допуска", before: []phase{warmup}, after: phase{windows: 10, use: 1, rtt: rtt * 14 / 10}, want: 1},
+		{name: "уменьшение при отказах", before: []phase{warmup}, after: phase{windows: 5, use: 1, rtt: rtt, outcome: Dropped}, want: -1},
+		{
+			// Первое окно после разогрева учитывает оставшиеся от него измерения
+			name:   "отменённые запросы не учитываются",
+			before: []phase{warmup, {windows: 1, use: 1, rtt: 4 * rtt, outcome: Ignore}},
+			after:  phase{windows: 10, use: 1, rtt: 4 * rtt, outcome: Ignore},
+			want:   0,
+		},
+		{name: "не растёт при использовании меньше половины", after: phase{windows: 20, use: 0.4, rtt: rtt}, want: 0},
+		{name: "верхняя граница", opts: Options{InitialLimit: 100, MaxLimit: 150}, after: phase{windows: 100, use: 1, rtt: rtt}, want: 1, exact: 150},
+		{name: "нижняя граница", opts: Options{InitialLimit: 100, MinLimit: 20}, after: phase{windows: 100, use: 1, rtt: rtt, outcome: Dropped}, want: -1, exact: 20},
+		{name: "нижняя граница при росте задержки", opts: Options{InitialLimit: 30, MinLimit: 20}, before: []phase{warmup}, after: phase{windows: 100, use: 1, rtt: 8 * rtt}, want: -1, exact: 20},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := newSimulation(tc.opts)
+			for _, p := range tc.before {
+				for i := 0; i < p.windows; i++ {
+					sim.window(p.use, p.rtt, p.outcome)
+				}
+			}
+
+			prev := sim.l.Limit()
+			for i := 0; i < tc.after.windows; i++ {
+				sim.window(tc.after.use, tc.after.rtt, tc.after.outcome)
+				limit := sim.l.Limit()
+				if limit < sim.l.opts.MinLimit || limit > sim.l.opts.MaxLimit {
+					t.Fatalf("окно %d: лимит %d вне [%d, %d]", i, limit, sim.l.opts.MinLimit, sim.l.opts.MaxLimit)
+				}
+			}
+
+			got := sim.l.Limit()
+			switch {
+			case tc.want > 0 && got <= prev, tc.want < 0 && got >= prev, tc.want == 0 && got != prev:
+				t.Errorf("лимит %d → %d, ожидалось направление %d", prev, got, tc.want)
+			}
+			if tc.exact != 0 && got != tc.exact {
+				t.Errorf("лимит %d, ожидается %d", got, tc.exact)
+			}
+			if st := sim.l.Stats(); st.InFlight != 0 || st.Limit != got {
+				t.Errorf("Stats() = %+v", st)
+			}
+		})
+	}
+}
+
+// Лимит пересчитывается не чаще раза в окно и только при достаточном числе измерений
+func TestLimitUpdateWindow(t *testing.T) {
+	// Измерений меньше minWindowSamples: пересчёта нет
+	sim := newSimulation(Options{})
+	initial := sim.l.Limit()
+	sim.window(float64(minWindowSamples-1)/float64(initial), time.Millisecond, Success)
+	if got := sim.l.Limit(); got != initial {
+		t.Fatalf("лимит %d после окна с %d измерениями, ожидается %d", got, minWindowSamples-1, initial)
+	}
+
+	// Окно ещё не закончилось: пересчёта нет, даже при отказе
+	sim = newSimulation(Options{})
+	release := func() {
+		token, ok := sim.l.Acquire(PriorityNormal)
+		if !ok {
+			t.Fatal("запрос отклонён при свободном лимите")
+		}
+		token.Release(Dropped)
+	}
+	sim.now = sim.now.Add(sim.l.opts.Window / 2)
+	release()
+	if got := sim.l.Limit(); got != initial {
+		t.Fatalf("лимит %d до конца окна, ожидается %d", got, initial)
+	}
+
+	// Отказ пересчитывает лимит без минимума измерений
+	sim.now = sim.now.Add(sim.l.opts.Window / 2)
+	release()
+	if got := sim.l.Limit(); got >= initial {
+		t.Fatalf("лимит %d после отказа, ожидается меньше %d", got, initial)
+	}
+}
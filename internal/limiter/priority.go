@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Priority — приоритет запроса при перегрузке. Запросы с меньшим приоритетом отклоняются первыми.
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // Анонимные и бесплатные пользователи
+	PriorityNormal                   // Зарегистрированные пользователи
+	PriorityHigh                     // Платные подписчики
+	PriorityCritical                 // Прямые трансляции
+	numPriorities
+)
+
+// Гарантированная доля лимита для каждого приоритета по умолчанию
+var defaultReservations = [numPriorities]float64{
+	PriorityLow:      0.1,
+	PriorityNormal:   0.2,
+	PriorityHigh:     0.3,
+	PriorityCritical: 0.4,
+}
+
+const (
+	minRetryAfter = 100 * time.Millisecond // Минимальная задержка повтора отклонённого запроса
+	maxRetryAfter = 10 * time.Second       // Максимальная задержка повтора
+	retryJitter   = 0.2                    // Разброс задержки повтора, чтобы клиенты не повторяли запросы одновременно
+)
+
+var priorityNames = [numPriorities]string{
+	PriorityLow:      "low",
+	PriorityNormal:   "normal",
+	PriorityHigh:     "high",
+	PriorityCritical: "critical",
+}
+
+func (p Priority) String() string {
+	if p < 0 || p >= numPriorities {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority разбирает приоритет: low, normal, high или critical
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for p, name := range priorityNames {
+		if s == name {
+			return Priority(p), nil
+		}
+	}
+	return 0, fmt.Errorf("неизвестный приоритет %q, допустимые: %s", s, strings.Join(priorityNames[:], ", "))
+}
+
+// MarshalText возвращает имя приоритета
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText разбирает имя приоритета, см. ParsePriority
+func (p *Priority) UnmarshalText(text []byte) error {
+	parsed, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Приведение приоритета к допустимому диапазону
+func (p Priority) clamp() Priority {
+	return min(max(p, PriorityLow), PriorityCritical)
+}
+
+// RetryAfter возвращает рекомендуемую задержку повтора для отклонённого запроса.
+// Она растёт с задержкой обработки и тем больше, чем ниже приоритет.
+func (l *Limiter) RetryAfter(p Priority) time.Duration {
+	base := max(minRetryAfter, 4*time.Duration(l.shortRTT.Load()))
+	d := base * time.Duration(numPriorities-p.clamp())
+	d = time.Duration(float64(d) * (1 + retryJitter*(2*rand.Float64()-1)))
+	return min(d, maxRetryAfter)
+}
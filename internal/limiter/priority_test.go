@@ -0,0 +1,187 @@
+package limiter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Запросы, занимающие места до проверки
+type hold struct {
+	priority Priority
+	n        int
+}
+
+func TestAcquirePriority(t *testing.T) {
+	const limit = 10
+	for _, tc := range []struct {
+		name         string
+		reservations map[Priority]float64
+		holds        []hold
+		try          Priority
+		want         bool
+	}{
+		{name: "свободный лимит принимает low", try: PriorityLow, want: true},
+		{name: "свободный лимит сверх доли", holds: []hold{{PriorityLow, 9}}, try: PriorityLow, want: true},
+		{name: "исчерпанный лимит отклоняет low", holds: []hold{{PriorityLow, limit}}, try: PriorityLow},
+		{name: "normal сверх своей доли", holds: []hold{{PriorityNormal, limit}}, try: PriorityNormal},
+		{name: "normal в своей доле", holds: []hold{{PriorityLow, limit}}, try: PriorityNormal, want: true},
+		{name: "high в своей доле", holds: []hold{{PriorityNormal, limit}}, try: PriorityHigh, want: true},
+		{name: "critical в своей доле", holds: []hold{{PriorityLow, 6}, {PriorityCritical, 3}, {PriorityHigh, 1}}, try: PriorityCritical, want: true},
+		{name: "critical сверх своей доли", holds: []hold{{PriorityLow, 6}, {PriorityCritical, 4}}, try: PriorityCritical},
+		{name: "low отклоняется раньше critical", holds: []hold{{PriorityNormal, 9}, {PriorityLow, 1}}, try: PriorityLow},
+		{name: "critical принимается при тех же местах", holds: []hold{{PriorityNormal, 9}, {PriorityLow, 1}}, try: PriorityCritical, want: true},
+		{name: "low в своей доле", holds: []hold{{PriorityCritical, limit}}, reservations: map[Priority]float64{PriorityLow: 0.5}, try: PriorityLow, want: true},
+		{name: "нулевая доля", holds: []hold{{PriorityLow, limit}}, reservations: map[Priority]float64{PriorityHigh: 0}, try: PriorityHigh},
+		{name: "недопустимая доля не применяется", holds: []hold{{PriorityLow, limit}}, reservations: map[Priority]float64{PriorityHigh: 2}, try: PriorityHigh, want: true},
+		{name: "приоритет выше допустимого", holds: []hold{{PriorityLow, limit}}, try: Priority(10), want: true},
+		{name: "приоритет ниже допустимого", holds: []hold{{PriorityLow, limit}}, try: Priority(-1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := New(Options{InitialLimit: limit, MinLimit: limit, MaxLimit: limit, Reservations: tc.reservations})
+			for _, h := range tc.holds {
+				for i := 0; i < h.n; i++ {
+					if _, ok := l.Acquire(h.priority); !ok {
+						t.Fatalf("запрос %s отклонён при заполнении", h.priority)
+					}
+				}
+			}
+
+			token, ok := l.Acquire(tc.try)
+			if ok != tc.want {
+				t.Fatalf("Acquire(%s) = %v, ожидается %v", tc.try, ok, tc.want)
+			}
+
+			st := l.Stats()
+			p := tc.try.clamp()
+			for _, ps := range st.Priorities {
+				if ps.Priority != p.String() {
+					continue
+				}
+				wantRejected := uint64(0)
+				if !tc.want {
+					wantRejected = 1
+				}
+				if ps.Rejected != wantRejected || st.Rejected != wantRejected {
+					t.Errorf("отклонено %d запросов %s и %d всего, ожидается %d", ps.Rejected, p, st.Rejected, wantRejected)
+				}
+			}
+			if ok {
+				token.Release(Ignore)
+			}
+		})
+	}
+}
+
+// Освобождённое место снова доступно приоритету
+func TestReleaseFreesReservation(t *testing.T) {
+	l := New(Options{InitialLimit: 10, MinLimit: 10, MaxLimit: 10})
+	var tokens []Token
+	for i := 0; i < 10; i++ {
+		token, _ := l.Acquire(PriorityLow)
+		tokens = append(tokens, token)
+	}
+	if _, ok := l.Acquire(PriorityLow); ok {
+		t.Fatal("запрос low принят при исчерпанном лимите")
+	}
+	tokens[0].Release(Ignore)
+	if _, ok := l.Acquire(PriorityLow); !ok {
+		t.Fatal("запрос low отклонён после освобождения места")
+	}
+	// Пустой токен (отклонённый запрос) ничего не освобождает
+	Token{}.Release(Success)
+	if st := l.Stats(); st.InFlight != 10 {
+		t.Fatalf("InFlight = %d, ожидается 10", st.InFlight)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Priority
+		wantErr bool
+	}{
+		{in: "low", want: PriorityLow},
+		{in: "normal", want: PriorityNormal},
+		{in: "High", want: PriorityHigh},
+		{in: " CRITICAL ", want: PriorityCritical},
+		{in: "", wantErr: true},
+		{in: "urgent", wantErr: true},
+		{in: "1", wantErr: true},
+	} {
+		got, err := ParsePriority(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParsePriority(%q) = %v, %v; ожидается %v, ошибка %v", tc.in, got, err, tc.want, tc.wantErr)
+		}
+
+		// UnmarshalText разбирает то же самое, а при ошибке оставляет прежнее значение
+		p := PriorityNormal
+		err = json.Unmarshal([]byte(`"`+tc.in+`"`), &p)
+		want := tc.want
+		if tc.wantErr {
+			want = PriorityNormal
+		}
+		if (err != nil) != tc.wantErr || p != want {
+			t.Errorf("UnmarshalText(%q) = %v, %v; ожидается %v, ошибка %v", tc.in, p, err, want, tc.wantErr)
+		}
+	}
+}
+
+func TestPriorityText(t *testing.T) {
+	for p := PriorityLow; p < numPriorities; p++ {
+		text, err := p.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed Priority
+		if err := parsed.UnmarshalText(text); err != nil || parsed != p {
+			t.Errorf("%s: после разбора %v, %v", p, parsed, err)
+		}
+	}
+	if s := Priority(7).String(); s != "Priority(7)" {
+		t.Errorf("String() = %q для недопустимого приоритета", s)
+	}
+}
+
+// Задержка повтора в среднем тем больше, чем ниже приоритет; разброс не выходит за retryJitter
+func TestRetryAfter(t *testing.T) {
+	const samples = 200
+	for _, tc := range []struct {
+		name     string
+		shortRTT time.Duration
+		base     time.Duration // Задержка для critical без разброса
+	}{
+		{name: "без измерений", base: minRetryAfter},
+		{name: "короткая задержка", shortRTT: time.Millisecond, base: minRetryAfter},
+		{name: "долгая задержка", shortRTT: 100 * time.Millisecond, base: 400 * time.Millisecond},
+		{name: "ограничение сверху", shortRTT: 5 * time.Second, base: maxRetryAfter},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := New(Options{})
+			l.shortRTT.Store(int64(tc.shortRTT))
+
+			var mean [numPriorities]time.Duration
+			for p := PriorityLow; p < numPriorities; p++ {
+				lo, hi := time.Duration(1<<63-1), time.Duration(0)
+				for i := 0; i < samples; i++ {
+					d := l.RetryAfter(p)
+					lo, hi = min(lo, d), max(hi, d)
+					mean[p] += d / samples
+				}
+				want := min(tc.base*time.Duration(numPriorities-p), maxRetryAfter)
+				if float64(lo) < float64(want)*(1-retryJitter) || hi > maxRetryAfter ||
+					float64(hi) > float64(want)*(1+retryJitter) {
+					t.Errorf("%s: задержка в [%v, %v], ожидается %v ± %.0f%%", p, lo, hi, want, retryJitter*100)
+				}
+			}
+			if tc.base == maxRetryAfter {
+				return
+			}
+			for p := PriorityLow; p < PriorityCritical; p++ {
+				if mean[p] <= mean[p+1] {
+					t.Errorf("%s: средняя задержка %v не больше, чем для %s (%v)", p, mean[p], p+1, mean[p+1])
+				}
+			}
+		})
+	}
+}
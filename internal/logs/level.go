@@ -0,0 +1,359 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Пакеты, уровень логирования которых можно менять отдельно от глобального
+var Packages = []string{"server", "grpc", "cache", "worker", "util", "scheduler"}
+
+// MinLevel — минимальный уровень для обработчика, обёрнутого LevelHandler:
+// отбор по уровню выполняет LevelHandler, поэтому сам обработчик не должен отсеивать записи
+const MinLevel = slog.LevelDebug
+
+var levels = newLevelRegistry()
+
+type (
+	packageKey struct{}
+	attrsKey   struct{}
+)
+
+// levelRegistry хранит глобальный уровень и переопределения по пакетам
+type levelRegistry struct {
+	global slog.LevelVar
+
+	mu        sync.RWMutex
+	overrides map[string]slog.Level   // Пакет → уровень; нет записи — используется глобальный
+	reverts   map[string]*levelRevert // Пакет ("" — глобальный) → отложенный возврат уровня
+}
+
+type levelRevert struct {
+	timer *time.Timer
+	at    time.Time
+}
+
+func newLevelRegistry() *levelRegistry {
+	return &levelRegistry{
+		overrides: make(map[string]slog.Level),
+		reverts:   make(map[string]*levelRevert),
+	}
+}
+
+// Действующий уровень пакета
+func (r *levelRegistry) level(pkg string) slog.Level {
+	if pkg != "" {
+		r.mu.RLock()
+		level, ok := r.overrides[pkg]
+		r.mu.RUnlock()
+		if ok {
+			return level
+		}
+	}
+	return r.global.Level()
+}
+
+// Установка уровня; reset снимает переопределение пакета.
+// При revertAfter > 0 предыдущее значение восстанавливается по таймеру.
+func (r *levelRegistry) set(pkg string, level slog.Level, reset bool, revertAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prevLevel, prevSet := r.global.Level(), true
+	if pkg != "" {
+		prevLevel, prevSet = r.overrides[pkg]
+	}
+
+	// Новое изменение отменяет отложенный возврат предыдущего
+	if rv, ok := r.reverts[pkg]; ok {
+		rv.timer.Stop()
+		delete(r.reverts, pkg)
+	}
+
+	r.apply(pkg, level, reset)
+
+	if revertAfter > 0 {
+		rv := &levelRevert{at: time.Now().Add(revertAfter)}
+		rv.timer = time.AfterFunc(revertAfter, func() {
+			r.mu.Lock()
+			if r.reverts[pkg] != rv {
+				r.mu.Unlock()
+				return
+			}
+			delete(r.reverts, pkg)
+			r.apply(pkg, prevLevel, !prevSet)
+			level := r.describe(pkg)
+			r.mu.Unlock()
+
+			slog.Info("Уровень логирования возвращён", "package", pkg, "level", level)
+		})
+		r.reverts[pkg] = rv
+	}
+}
+
+// Вызывается под mu
+func (r *levelRegistry) apply(pkg string, level slog.Level, reset bool) {
+	switch {
+	case pkg == "":
+		r.global.Set(level)
+	case reset:
+		delete(r.overrides, pkg)
+	default:
+		r.overrides[pkg] = level
+	}
+}
+
+// Уровень пакета для вывода; вызывается под mu
+func (r *levelRegistry) describe(pkg string) string {
+	if pkg == "" {
+		return r.global.Level().String()
+	}
+	if level, ok := r.overrides[pkg]; ok {
+		return level.String()
+	}
+	return "inherit"
+}
+
+// LevelState — текущие уровни логирования
+type LevelState struct {
+	Global   string               `json:"global"`
+	Packages map[string]string    `json:"packages"`          // Пакет → уровень или inherit
+	Reverts  map[string]time.Time `json:"reverts,omitempty"` // Пакет (global — глобальный) → время возврата
+}
+
+func (r *levelRegistry) state() LevelState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := LevelState{Global: r.describe(""), Packages: make(map[string]string, len(Packages))}
+	for _, pkg := range Packages {
+		s.Packages[pkg] = r.describe(pkg)
+	}
+	for pkg, rv := range r.reverts {
+		if s.Reverts == nil {
+			s.Reverts = make(map[string]time.Time)
+		}
+		if pkg == "" {
+			pkg = "global"
+		}
+		s.Reverts[pkg] = rv.at
+	}
+	return s
+}
+
+// SetLevel устанавливает глобальный уровень (pkg == "") или уровень пакета.
+// При revertAfter > 0 предыдущий уровень восстанавливается через это время.
+func SetLevel(pkg string, level slog.Level, revertAfter time.Duration) error {
+	if err := checkPackage(pkg); err != nil {
+		return err
+	}
+	levels.set(pkg, level, false, revertAfter)
+	return nil
+}
+
+// ResetLevel снимает переопределение уровня пакета, после чего действует глобальный уровень
+func ResetLevel(pkg string, revertAfter time.Duration) error {
+	if pkg == "" {
+		return fmt.Errorf("глобальный уровень нельзя сбросить, его можно только установить")
+	}
+	if err := checkPackage(pkg); err != nil {
+		return err
+	}
+	levels.set(pkg, 0, true, revertAfter)
+	return nil
+}
+
+// Levels возвращает текущие уровни логирования
+func Levels() LevelState {
+	return levels.state()
+}
+
+// LevelEnabled сообщает, пишутся ли сообщения уровня level для пакета pkg ("" — без пакета)
+func LevelEnabled(pkg string, level slog.Level) bool {
+	return level >= levels.level(pkg)
+}
+
+func checkPackage(pkg string) error {
+	if pkg == "" {
+		return nil
+	}
+	for _, p := range Packages {
+		if p == pkg {
+			return nil
+		}
+	}
+	return fmt.Errorf("неизвестный пакет %q, допустимые: %s", pkg, strings.Join(Packages, ", "))
+}
+
+// ParseLevel разбирает уровень: debug, info, warn, error или число
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("неизвестный уровень логирования %q", s)
+	}
+	return level, nil
+}
+
+// LevelHandler отбирает записи по глобальному уровню или уровню пакета,
+// переданному в контексте логгером из For, и добавляет атрибуты контекста из ContextWith
+type LevelHandler struct {
+	next slog.Handler
+}
+
+// NewLevelHandler оборачивает обработчик управлением уровнем во время работы.
+// Обёрнутый обработчик должен пропускать записи начиная с MinLevel.
+func NewLevelHandler(next slog.Handler) *LevelHandler {
+	return &LevelHandler{next: next}
+}
+
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	pkg, _ := ctx.Value(packageKey{}).(string)
+	return LevelEnabled(pkg, level) && h.next.Enabled(ctx, level)
+}
+
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LevelHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{next: h.next.WithGroup(name)}
+}
+
+// ContextWith добавляет атрибуты args (в формате slog.Logger.With) к записям, сделанным
+// с возвращённым контекстом через методы *Context, например идентификатор запроса
+func ContextWith(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	attrs := slog.Group("", args...).Value.Group()
+	return context.WithValue(ctx, attrsKey{}, append(prev[:len(prev):len(prev)], attrs...))
+}
+
+// For возвращает логгер пакета: уровень берётся из переопределения пакета,
+// а запись выполняет текущий обработчик slog.Default
+func For(pkg string) *slog.Logger {
+	return slog.New(&packageHandler{pkg: pkg})
+}
+
+// packageHandler передаёт записи обработчику по умолчанию, добавляя пакет в контекст.
+// Обработчик по умолчанию определяется при каждом вызове, поэтому логгер можно
+// создать до настройки slog в main. WithAttrs и WithGroup применяются к нему заново.
+type packageHandler struct {
+	pkg  string
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h *packageHandler) handler() slog.Handler {
+	next := slog.Default().Handler()
+	for _, wrap := range h.wrap {
+		next = wrap(next)
+	}
+	return next
+}
+
+func (h *packageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(withPackage(ctx, h.pkg), level)
+}
+
+func (h *packageHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(withPackage(ctx, h.pkg), r)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *packageHandler) with(wrap func(slog.Handler) slog.Handler) slog.Handler {
+	return &packageHandler{pkg: h.pkg, wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], wrap)}
+}
+
+func withPackage(ctx context.Context, pkg string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, packageKey{}, pkg)
+}
+
+// LevelsHTTPHandler — служебный HTTP-обработчик уровней логирования.
+// GET возвращает текущие уровни. PUT или POST меняет уровень, параметры:
+// level (debug, info, warn, error; reset — наследовать глобальный),
+// package (пусто — глобальный) и revert (через сколько вернуть прежний уровень, например 10m).
+func LevelsHTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := setLevelFromRequest(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Levels())
+	})
+}
+
+func setLevelFromRequest(r *http.Request) error {
+	q := r.URL.Query()
+	pkg := q.Get("package")
+	value := q.Get("level")
+	if value == "" {
+		return fmt.Errorf("не указан уровень")
+	}
+
+	var revertAfter time.Duration
+	if s := q.Get("revert"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return fmt.Errorf("некорректная длительность revert %q", s)
+		}
+		revertAfter = d
+	}
+
+	return ChangeLevel(pkg, value, revertAfter)
+}
+
+// ChangeLevel устанавливает уровень пакета pkg ("" — глобальный) по строке, как служебные
+// HTTP и gRPC обработчики: debug, info, warn, error, число или reset — наследовать глобальный
+func ChangeLevel(pkg, value string, revertAfter time.Duration) error {
+	if strings.EqualFold(value, "reset") {
+		if err := ResetLevel(pkg, revertAfter); err != nil {
+			return err
+		}
+	} else {
+		level, err := ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		if err := SetLevel(pkg, level, revertAfter); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Изменён уровень логирования", "package", pkg, "level", value, "revert", revertAfter)
+	return nil
+}
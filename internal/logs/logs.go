@@ -1,20 +1,36 @@
 package logs
 
 import (
+	"context"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type LogMessage struct {
-	Level slog.Level
-	Msg   string
-	Args  []interface{}
+	Time    time.Time
+	Level   slog.Level
+	Msg     string
+	Args    []interface{}
+	Package string // Пакет-источник для выбора уровня логирования ("" — глобальный уровень)
 }
 
 var (
 	maxChannelSize = 100000                                // Размер канала логирования
+	flushSize      = 1000                                  // Количество сообщений, после которого буфер сбрасывается
+	flushInterval  = 1 * time.Second                       // Максимальное время накопления сообщений в буфере
+	maxArgLength   = 100                                   // Максимальная длина строкового аргумента в байтах
 	logChannel     = make(chan LogMessage, maxChannelSize) // Канал для асинхронного логирования
-	logBuffer      = make([]LogMessage, 0, maxChannelSize) // Буфер для накопления логов
+	logBuffer      = make([]LogMessage, 0, flushSize)      // Буфер для накопления логов
+
+	flushRequests = make(chan chan struct{}) // Запросы на принудительный сброс буфера
+	stopConsumer  = make(chan struct{})      // Сигнал остановки обработчика
+	consumerDone  = make(chan struct{})      // Закрывается после завершения обработчика
+	closed        atomic.Bool                // Логгер закрыт, сообщения пишутся синхронно
+	closeOnce     sync.Once
+
+	droppedCount atomic.Uint64 // Количество потерянных сообщений
 )
 
 // Функция для ленивой инициализации канала логирования
@@ -28,54 +44,160 @@ func getLogChannel() chan LogMessage {
 
 // Асинхронное логирование
 func AsyncLog(level slog.Level, msg string, args ...interface{}) {
+	AsyncLogFor("", level, msg, args...)
+}
+
+// AsyncLogFor логирует асинхронно с учётом уровня, заданного для пакета pkg
+func AsyncLogFor(pkg string, level slog.Level, msg string, args ...interface{}) {
+	// Сообщения ниже текущего уровня отбрасываются до постановки в очередь
+	if !LevelEnabled(pkg, level) {
+		return
+	}
+
+	// Секреты скрываются до постановки в очередь, чтобы не попасть ни в один приёмник
+	defaultRedactor.Load().args(args)
+
 	// Ограничение размера данных в логах
 	for i, arg := range args {
 		switch v := arg.(type) {
 		case string:
-			if len(v) > 100 { // Ограничиваем строковые данные до 100 символов
-				args[i] = v[:100]
-			}
+			args[i] = Truncate(v, maxArgLength)
 		}
 	}
 
-	logMsg := LogMessage{Level: level, Msg: msg, Args: args}
+	logMsg := LogMessage{Time: time.Now(), Level: level, Msg: msg, Args: args, Package: pkg}
+
+	// После закрытия обработчика сообщения пишутся сразу в slog, чтобы не потерять их
+	if closed.Load() {
+		writeToSlog(context.Background(), []LogMessage{logMsg})
+		return
+	}
+
 	select {
 	case getLogChannel() <- logMsg:
 	default:
-		// Если канал переполнен, пробуем повторить через несколько миллисекунд
-		time.Sleep(100 * time.Millisecond)
-		select {
-		case getLogChannel() <- logMsg:
-		default:
-			slog.Warn("Не удалось записать лог, канал переполнен", "msg", msg)
-		}
+		// Канал переполнен: поведение задаётся политикой переполнения (см. SetOverflow)
+		handleOverflow(logMsg)
+	}
+}
+
+// Dropped возвращает общее количество сообщений, потерянных из-за переполнения канала
+func Dropped() uint64 {
+	return droppedCount.Load()
+}
+
+// Flush записывает все сообщения, поставленные в очередь до вызова, включая буферы приёмников
+func Flush(ctx context.Context) error {
+	if closed.Load() {
+		return nil
+	}
+
+	done := make(chan struct{})
+	select {
+	case flushRequests <- done:
+	case <-consumerDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return flushSinks(ctx)
 }
 
+// Close записывает оставшиеся сообщения и останавливает обработчик.
+// Сообщения, поступившие после Close, пишутся синхронно.
+func Close() {
+	closeOnce.Do(func() {
+		closed.Store(true)
+		close(stopConsumer)
+		<-consumerDone
+
+		// Сообщения, отправленные одновременно с закрытием, могли остаться в канале
+		drainChannel()
+		flushBuffer()
+		closeSpill()
+		reportDropped()
+
+		closeSinks()
+	})
+}
+
+// Передача сообщений приёмникам, а если они не настроены — в slog
 func processLogs(logs []LogMessage) {
+	if !dispatchToSinks(logs) {
+		writeToSlog(context.Background(), logs)
+	}
+}
+
+func writeToSlog(ctx context.Context, logs []LogMessage) {
+	logger := slog.Default()
 	for _, logMsg := range logs {
-		switch logMsg.Level {
-		case slog.LevelInfo:
-			slog.Info(logMsg.Msg, logMsg.Args...)
-		case slog.LevelError:
-			slog.Error(logMsg.Msg, logMsg.Args...)
+		msgCtx := withPackage(ctx, logMsg.Package)
+		if !logger.Enabled(msgCtx, logMsg.Level) {
+			continue
+		}
+		_ = logger.Handler().Handle(msgCtx, newRecord(logMsg))
+	}
+}
+
+// Запись накопленных сообщений и очистка буфера
+func flushBuffer() {
+	if len(logBuffer) == 0 {
+		return
+	}
+	processLogs(logBuffer)
+	logBuffer = logBuffer[:0]
+}
+
+// Перенос в буфер всех сообщений, уже находящихся в канале
+func drainChannel() {
+	for {
+		select {
+		case logMsg := <-logChannel:
+			logBuffer = append(logBuffer, logMsg)
+			if len(logBuffer) >= flushSize {
+				flushBuffer()
+			}
 		default:
-			slog.Debug(logMsg.Msg, logMsg.Args...)
+			return
 		}
 	}
 }
 
 func init() {
-	// Создаем несколько горутин для обработки логов
-	// Горутин для асинхронного логирования
+	// Горутина для асинхронного логирования: буфер сбрасывается
+	// по достижении flushSize или по истечении flushInterval, смотря что наступит раньше
 	go func() {
-		for logMsg := range logChannel {
-			logBuffer = append(logBuffer, logMsg)
+		defer close(consumerDone)
+
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
 
-			// Когда количество логов в буфере достигает максимума, обрабатываем их
-			if len(logBuffer) >= maxChannelSize {
-				processLogs(logBuffer)
-				logBuffer = nil // Очищаем буфер
+		for {
+			select {
+			case logMsg := <-logChannel:
+				logBuffer = append(logBuffer, logMsg)
+				if len(logBuffer) >= flushSize {
+					flushBuffer()
+				}
+			case <-ticker.C:
+				flushBuffer()
+				replaySpill(false)
+				reportDropped()
+			case done := <-flushRequests:
+				drainChannel()
+				replaySpill(true)
+				flushBuffer()
+				close(done)
+			case <-stopConsumer:
+				drainChannel()
+				flushBuffer()
+				return
 			}
 		}
 	}()
@@ -0,0 +1,459 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy — поведение AsyncLog при заполненном канале
+type OverflowPolicy string
+
+const (
+	OverflowDropNewest OverflowPolicy = "drop_newest" // Отбросить новое сообщение (по умолчанию)
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // Вытеснить самое старое сообщение из канала
+	OverflowBlock      OverflowPolicy = "block"       // Ждать места в канале не дольше BlockTimeout
+	OverflowSpill      OverflowPolicy = "spill"       // Записать сообщение в файл на диске
+)
+
+const (
+	defaultBlockTimeout = 5 * time.Millisecond // Время ожидания места в канале по умолчанию
+	defaultSpillMaxSize = 100 << 20            // Размер файла переполнения по умолчанию
+	dropOldestAttempts  = 3                    // Попытки вытеснения при конкурентных производителях
+	spillReplaySuffix   = ".replay"            // Суффикс файла, сообщения из которого переигрываются
+	maxSpillLine        = 1 << 20              // Более длинная строка файла переполнения пропускается
+)
+
+// OverflowOptions задаёт политику переполнения канала асинхронного логгера
+type OverflowOptions struct {
+	Policy       OverflowPolicy
+	BlockTimeout time.Duration // Для block: максимальное ожидание в горутине вызывающего
+	SpillPath    string        // Для spill: путь к файлу переполнения
+	SpillMaxSize int64         // Для spill: размер файла в байтах, после которого сообщения отбрасываются
+}
+
+// OverflowStats — счётчики переполнения канала
+type OverflowStats struct {
+	DroppedNewest uint64 // Отброшенные новые сообщения
+	DroppedOldest uint64 // Вытесненные из канала старые сообщения
+	Blocked       uint64 // Сообщения, дождавшиеся места в канале
+	BlockTimeouts uint64 // Сообщения, отброшенные по истечении BlockTimeout
+	Spilled       uint64 // Сообщения, записанные в файл переполнения
+	SpillDropped  uint64 // Сообщения, отброшенные из-за ошибки или размера файла переполнения
+	Replayed      uint64 // Сообщения, прочитанные из файла переполнения и записанные
+}
+
+// Состояние политики; заменяется целиком при SetOverflow
+type overflowState struct {
+	opts  OverflowOptions
+	spill *spillFile // Только для OverflowSpill
+}
+
+var (
+	overflow atomic.Pointer[overflowState]
+
+	overflowDroppedNewest atomic.Uint64
+	overflowDroppedOldest atomic.Uint64
+	overflowBlocked       atomic.Uint64
+	overflowBlockTimeouts atomic.Uint64
+	overflowSpilled       atomic.Uint64
+	overflowSpillDropped  atomic.Uint64
+	overflowReplayed      atomic.Uint64
+
+	reportedDropped uint64 // Потери, о которых уже сообщено; используется только обработчиком
+)
+
+func init() {
+	overflow.Store(&overflowState{opts: OverflowOptions{Policy: OverflowDropNewest}})
+}
+
+// SetOverflow устанавливает политику переполнения канала. Для spill сообщения,
+// оставшиеся в файле с прошлого запуска, будут записаны обработчиком.
+func SetOverflow(opts OverflowOptions) error {
+	if opts.Policy == "" {
+		opts.Policy = OverflowDropNewest
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = defaultBlockTimeout
+	}
+	if opts.SpillMaxSize <= 0 {
+		opts.SpillMaxSize = defaultSpillMaxSize
+	}
+
+	state := &overflowState{opts: opts}
+	switch opts.Policy {
+	case OverflowDropNewest, OverflowDropOldest, OverflowBlock:
+	case OverflowSpill:
+		if opts.SpillPath == "" {
+			return fmt.Errorf("для политики %s не указан файл переполнения", opts.Policy)
+		}
+		spill, err := openSpillFile(opts.SpillPath, opts.SpillMaxSize)
+		if err != nil {
+			return fmt.Errorf("не удалось открыть файл переполнения: %w", err)
+		}
+		state.spill = spill
+	default:
+		return fmt.Errorf("неизвестная политика переполнения %q", opts.Policy)
+	}
+
+	if prev := overflow.Swap(state); prev.spill != nil {
+		// Сообщения остаются на диске и будут записаны при следующем запуске
+		if err := prev.spill.close(); err != nil {
+			slog.Error("Не удалось закрыть файл переполнения", "file", prev.spill.path, "error", err)
+		}
+	}
+	return nil
+}
+
+// Overflow возвращает счётчики переполнения канала
+func Overflow() OverflowStats {
+	return OverflowStats{
+		DroppedNewest: overflowDroppedNewest.Load(),
+		DroppedOldest: overflowDroppedOldest.Load(),
+		Blocked:       overflowBlocked.Load(),
+		BlockTimeouts: overflowBlockTimeouts.Load(),
+		Spilled:       overflowSpilled.Load(),
+		SpillDropped:  overflowSpillDropped.Load(),
+		Replayed:      overflowReplayed.Load(),
+	}
+}
+
+// Обработка сообщения, для которого нет места в канале. Вызывается из горутины производителя.
+func handleOverflow(logMsg LogMessage) {
+	state := overflow.Load()
+	switch state.opts.Policy {
+	case OverflowDropOldest:
+		// Другие производители могут занять освободившееся место, поэтому попыток несколько
+		for i := 0; i < dropOldestAttempts; i++ {
+			select {
+			case <-logChannel:
+				overflowDroppedOldest.Add(1)
+				droppedCount.Add(1)
+			default:
+			}
+			select {
+			case logChannel <- logMsg:
+				return
+			default:
+			}
+		}
+		overflowDroppedNewest.Add(1)
+		droppedCount.Add(1)
+
+	case OverflowBlock:
+		timer := time.NewTimer(state.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case logChannel <- logMsg:
+			overflowBlocked.Add(1)
+		case <-timer.C:
+			overflowBlockTimeouts.Add(1)
+			droppedCount.Add(1)
+		}
+
+	case OverflowSpill:
+		if err := state.spill.write(logMsg); err != nil {
+			overflowSpillDropped.Add(1)
+			droppedCount.Add(1)
+			return
+		}
+		overflowSpilled.Add(1)
+
+	default:
+		overflowDroppedNewest.Add(1)
+		droppedCount.Add(1)
+	}
+}
+
+// Запись сообщений из файла переполнения, когда в канале снова есть место.
+// Вызывается только обработчиком; force — при закрытии, независимо от заполненности канала.
+func replaySpill(force bool) {
+	spill := overflow.Load().spill
+	if spill == nil || (!force && len(logChannel) > cap(logChannel)/2) {
+		return
+	}
+
+	name, err := spill.take()
+	if err != nil {
+		slog.Error("Не удалось подготовить файл переполнения к записи", "file", spill.path, "error", err)
+		return
+	}
+	if name == "" {
+		return
+	}
+
+	// Файл удаляется и после ошибки чтения: иначе он переигрывался бы на каждом такте,
+	// а непрочитанный остаток всё равно не восстановить
+	if err := replaySpillFile(name); err != nil {
+		overflowSpillDropped.Add(1)
+		droppedCount.Add(1)
+		slog.Error("Не удалось дочитать файл переполнения, остаток сообщений потерян", "file", name, "error", err)
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("Не удалось удалить файл переполнения", "file", name, "error", err)
+	}
+}
+
+func replaySpillFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Сообщения из файла старше находящихся в буфере, поэтому буфер записывается после них
+	pending := append([]LogMessage(nil), logBuffer...)
+	logBuffer = logBuffer[:0]
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	batch := make([]LogMessage, 0, flushSize)
+	var readErr error
+	for readErr == nil {
+		var line []byte
+		var tooLong bool
+		line, tooLong, readErr = readSpillLine(r)
+		if len(line) == 0 && !tooLong {
+			continue
+		}
+		// Повреждённая или слишком длинная строка пропускается, остальные сообщения переигрываются
+		var rec spillRecord
+		if tooLong || json.Unmarshal(line, &rec) != nil {
+			overflowSpillDropped.Add(1)
+			droppedCount.Add(1)
+			continue
+		}
+		batch = append(batch, rec.message())
+		if len(batch) >= flushSize {
+			processLogs(batch)
+			overflowReplayed.Add(uint64(len(batch)))
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		processLogs(batch)
+		overflowReplayed.Add(uint64(len(batch)))
+	}
+
+	logBuffer = append(logBuffer, pending...)
+	if errors.Is(readErr, io.EOF) {
+		return nil
+	}
+	return readErr
+}
+
+// Чтение строки файла переполнения. Строка длиннее maxSpillLine дочитывается до конца,
+// но не сохраняется: возвращается tooLong.
+func readSpillLine(r *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxSpillLine {
+				line, tooLong = nil, true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return bytes.TrimRight(line, "\r\n"), tooLong, err
+		}
+	}
+}
+
+// Сообщение о потерях за прошедший интервал вместо предупреждения на каждое сообщение
+func reportDropped() {
+	total := droppedCount.Load()
+	if total == reportedDropped {
+		return
+	}
+	stats := Overflow()
+	slog.Warn("Канал асинхронного логгера переполнен, сообщения потеряны",
+		"lost", total-reportedDropped,
+		"policy", overflow.Load().opts.Policy,
+		"dropped_newest", stats.DroppedNewest,
+		"dropped_oldest", stats.DroppedOldest,
+		"block_timeouts", stats.BlockTimeouts,
+		"spill_dropped", stats.SpillDropped,
+	)
+	reportedDropped = total
+}
+
+// Закрытие файла переполнения после записи оставшихся в нём сообщений
+func closeSpill() {
+	spill := overflow.Load().spill
+	if spill == nil {
+		return
+	}
+	replaySpill(true)
+	if err := spill.close(); err != nil {
+		slog.Error("Не удалось закрыть файл переполнения", "file", spill.path, "error", err)
+	}
+}
+
+// spillRecord — сообщение в файле переполнения. Аргументы сохраняются как пары
+// ключ-значение; значения, не представимые в JSON, записываются строкой.
+type spillRecord struct {
+	Time    time.Time     `json:"time"`
+	Level   slog.Level    `json:"level"`
+	Msg     string        `json:"msg"`
+	Package string        `json:"package,omitempty"`
+	Args    []interface{} `json:"args,omitempty"`
+}
+
+func newSpillRecord(logMsg LogMessage) spillRecord {
+	rec := spillRecord{Time: logMsg.Time, Level: logMsg.Level, Msg: logMsg.Msg, Package: logMsg.Package}
+	newRecord(logMsg).Attrs(func(a slog.Attr) bool {
+		rec.Args = append(rec.Args, a.Key, spillValue(a.Value))
+		return true
+	})
+	return rec
+}
+
+func spillValue(v slog.Value) interface{} {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := make(map[string]interface{}, len(v.Group()))
+		for _, a := range v.Group() {
+			group[a.Key] = spillValue(a.Value)
+		}
+		return group
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+	return v.Any()
+}
+
+func (r spillRecord) message() LogMessage {
+	return LogMessage{Time: r.Time, Level: r.Level, Msg: r.Msg, Args: r.Args, Package: r.Package}
+}
+
+// spillFile — файл переполнения, в который одновременно пишут производители
+type spillFile struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+func openSpillFile(path string, maxSize int64) (*spillFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &spillFile{path: path, maxSize: maxSize}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Вызывается под mu или до публикации
+func (s *spillFile) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.w, s.size = f, bufio.NewWriterSize(f, 64*1024), info.Size()
+	return nil
+}
+
+var errSpillFull = errors.New("файл переполнения заполнен")
+
+func (s *spillFile) write(logMsg LogMessage) error {
+	line, err := json.Marshal(newSpillRecord(logMsg))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.size+int64(len(line)) > s.maxSize {
+		return errSpillFull
+	}
+	n, err := s.w.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// take передаёт накопленные сообщения на запись: текущий файл переименовывается,
+// а производители продолжают писать в новый. Возвращает имя файла для чтения
+// или пустую строку, если сообщений нет. Файл, не дочитанный ранее, возвращается первым.
+func (s *spillFile) take() (string, error) {
+	replay := s.path + spillReplaySuffix
+	if _, err := os.Stat(replay); err == nil {
+		return replay, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return "", nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return "", err
+	}
+	if s.size == 0 {
+		return "", nil
+	}
+	if err := s.file.Close(); err != nil {
+		return "", err
+	}
+	s.file = nil
+	renameErr := os.Rename(s.path, replay)
+	if err := s.open(); err != nil {
+		return "", err
+	}
+	if renameErr != nil {
+		return "", renameErr
+	}
+	return replay, nil
+}
+
+func (s *spillFile) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+
+	// Пустой файл не нужен при следующем запуске
+	if err == nil && s.size == 0 {
+		if rerr := os.Remove(s.path); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+			err = rerr
+		}
+	}
+	return err
+}
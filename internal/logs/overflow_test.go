@@ -0,0 +1,81 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSpillLine(t *testing.T) {
+	long := strings.Repeat("x", maxSpillLine+10)
+	r := bufio.NewReaderSize(strings.NewReader("первая\n"+long+"\nвторая\r\nбез перевода строки"), 16)
+
+	for _, want := range []struct {
+		line    string
+		tooLong bool
+	}{{"первая", false}, {"", true}, {"вторая", false}, {"без перевода строки", false}} {
+		line, tooLong, err := readSpillLine(r)
+		if string(line) != want.line || tooLong != want.tooLong {
+			t.Fatalf("readSpillLine() = %.20q, %v, %v; ожидалось %q, %v", line, tooLong, err, want.line, want.tooLong)
+		}
+	}
+	if _, _, err := readSpillLine(r); err == nil {
+		t.Fatal("ожидался конец файла")
+	}
+}
+
+// Слишком длинная или повреждённая строка не прерывает переигрывание: остальные
+// сообщения записываются, а файл удаляется и не переигрывается повторно
+func TestReplaySpillSkipsBadLines(t *testing.T) {
+	var out bytes.Buffer
+	prevDefault := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	defer slog.SetDefault(prevDefault)
+
+	path := filepath.Join(t.TempDir(), "spill.log")
+	if err := SetOverflow(OverflowOptions{Policy: OverflowSpill, SpillPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetOverflow(OverflowOptions{}) }()
+
+	var data []byte
+	for _, msg := range []string{"первое", "второе"} {
+		line, _ := json.Marshal(newSpillRecord(LogMessage{Time: time.Now(), Level: slog.LevelInfo, Msg: msg}))
+		data = append(data, line...)
+		data = append(data, '\n')
+		if msg == "первое" {
+			data = append(data, strings.Repeat("x", maxSpillLine+1)...)
+			data = append(data, "\n{не json\n"...)
+		}
+	}
+	if err := os.WriteFile(path+spillReplaySuffix, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Переигрывание выполняет горутина логгера при сбросе
+	before := Overflow()
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := Overflow()
+
+	if got := after.Replayed - before.Replayed; got != 2 {
+		t.Errorf("переиграно %d сообщений, ожидалось 2", got)
+	}
+	if got := after.SpillDropped - before.SpillDropped; got != 2 {
+		t.Errorf("пропущено %d строк, ожидалось 2", got)
+	}
+	if !strings.Contains(out.String(), "первое") || !strings.Contains(out.String(), "второе") {
+		t.Errorf("сообщения не записаны: %s", out.String())
+	}
+	if _, err := os.Stat(path + spillReplaySuffix); !os.IsNotExist(err) {
+		t.Fatalf("файл переигрывания не удалён: %v", err)
+	}
+}
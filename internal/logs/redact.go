@@ -0,0 +1,233 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Redacted заменяет скрытые значения в логах
+const Redacted = "[REDACTED]"
+
+// Правила скрытия, действующие всегда; RedactOptions их дополняет
+var (
+	defaultRedactParams = []string{
+		"token", "access_token", "refresh_token", "sig", "signature", "key", "api_key",
+		"secret", "password", "auth", "user_id", "userid", "uid", "session",
+	}
+	defaultRedactHeaders = []string{
+		"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key", "x-auth-token",
+	}
+	defaultRedactPatterns = []string{
+		`(?i)\bbearer\s+([a-z0-9._~+/-]+=*)`,                  // Токен в заголовке Authorization
+		`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`, // JWT
+		`://([^/\s:@]+:[^/\s@]+)@`,                            // Учётные данные в URL
+	}
+)
+
+var defaultRedactor atomic.Pointer[Redactor]
+
+func init() {
+	r, err := NewRedactor(RedactOptions{})
+	if err != nil {
+		panic(err)
+	}
+	defaultRedactor.Store(r)
+}
+
+// RedactOptions задаёт дополнительные правила скрытия секретов
+type RedactOptions struct {
+	QueryParams []string // Параметры query, значения которых скрываются (?token=… → ?token=[REDACTED])
+	Headers     []string // Заголовки и ключи атрибутов, значения которых скрываются целиком
+	Patterns    []string // Регулярные выражения; скрывается первая группа, а без групп — всё совпадение
+}
+
+// Redactor скрывает секреты в строках и атрибутах логов
+type Redactor struct {
+	params   *regexp.Regexp
+	keys     map[string]struct{} // Ключи атрибутов в нижнем регистре, значения которых скрываются целиком
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor создаёт набор правил из правил по умолчанию и opts
+func NewRedactor(opts RedactOptions) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]struct{})}
+
+	params := append(append([]string{}, defaultRedactParams...), opts.QueryParams...)
+	quoted := make([]string, 0, len(params))
+	for _, p := range params {
+		if p = strings.TrimSpace(p); p != "" {
+			quoted = append(quoted, regexp.QuoteMeta(p))
+			r.keys[strings.ToLower(p)] = struct{}{}
+		}
+	}
+	r.params = regexp.MustCompile(`(?i)([?&;](?:` + strings.Join(quoted, "|") + `)=)[^&#\s"]*`)
+
+	for _, h := range append(append([]string{}, defaultRedactHeaders...), opts.Headers...) {
+		if h = strings.TrimSpace(h); h != "" {
+			r.keys[strings.ToLower(h)] = struct{}{}
+		}
+	}
+
+	for _, p := range append(append([]string{}, defaultRedactPatterns...), opts.Patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное регулярное выражение для скрытия %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// SetRedactor заменяет правила, которые используют все логгеры
+func SetRedactor(r *Redactor) {
+	if r != nil {
+		defaultRedactor.Store(r)
+	}
+}
+
+// Redact скрывает секреты в строке по текущим правилам
+func Redact(s string) string {
+	return defaultRedactor.Load().String(s)
+}
+
+// String скрывает значения секретных параметров query и совпадения с регулярными выражениями
+func (r *Redactor) String(s string) string {
+	if strings.ContainsRune(s, '=') {
+		s = r.params.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	for _, re := range r.patterns {
+		s = redactPattern(re, s)
+	}
+	return s
+}
+
+func redactPattern(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllString(s, Redacted)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		if m[2] < 0 {
+			continue
+		}
+		b.WriteString(s[last:m[2]])
+		b.WriteString(Redacted)
+		last = m[3]
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Attr скрывает значение атрибута целиком, если его ключ секретный, иначе — секреты в значении
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	if _, ok := r.keys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, Redacted)
+	}
+	a.Value = r.value(a.Value)
+	return a
+}
+
+func (r *Redactor) value(v slog.Value) slog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(r.String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, a := range group {
+			attrs[i] = r.Attr(a)
+		}
+		return slog.GroupValue(attrs...)
+	case slog.KindAny:
+		// Ошибки и значения со String часто содержат URL
+		var s string
+		switch x := v.Any().(type) {
+		case error:
+			s = x.Error()
+		case fmt.Stringer:
+			s = x.String()
+		default:
+			return v
+		}
+		if redacted := r.String(s); redacted != s {
+			return slog.StringValue(redacted)
+		}
+	}
+	return v
+}
+
+// Скрытие секретов в аргументах AsyncLog в формате slog: пары ключ-значение и slog.Attr
+func (r *Redactor) args(args []interface{}) {
+	for i := 0; i < len(args); i++ {
+		switch v := args[i].(type) {
+		case slog.Attr:
+			args[i] = r.Attr(v)
+		case string:
+			if i+1 < len(args) {
+				args[i+1] = r.Attr(slog.Any(v, args[i+1])).Value.Any()
+				i++
+			}
+		}
+	}
+}
+
+// Truncate обрезает строку до max байт, не разрывая многобайтовые символы.
+// К обрезанной строке добавляется многоточие.
+func Truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// RedactingHandler скрывает секреты в сообщениях и атрибутах по текущим правилам
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler оборачивает обработчик скрытием секретов
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactor := defaultRedactor.Load()
+	out := slog.NewRecord(r.Time, r.Level, redactor.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactor.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactor := defaultRedactor.Load()
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactor.Attr(a)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
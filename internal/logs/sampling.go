@@ -0,0 +1,218 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSamplingInterval = 1 * time.Second // Окно семплирования по умолчанию
+	maxSamplingKeys         = 10000           // Максимальное количество ключей в окне
+	maxSummaryKeys          = 100             // Максимальное количество ключей в сводке за окно
+	overflowSamplingKey     = "\x00overflow"  // Общий ключ для сообщений сверх maxSamplingKeys
+)
+
+// SamplingOptions задаёт правила семплирования
+type SamplingOptions struct {
+	First      int           // Сколько сообщений с одним ключом пропускать за окно
+	Thereafter int           // После First пропускать каждое M-е сообщение (0 — не пропускать больше ни одного)
+	Interval   time.Duration // Длина окна; по его окончании выводится сводка подавленных сообщений
+	KeyAttr    string        // Атрибут, значение которого добавляется к ключу (например, video); пусто — ключ только сообщение
+}
+
+// SamplingHandler ограничивает количество одинаковых сообщений: первые First
+// за окно пропускаются, затем каждое Thereafter-е. Ключ — уровень и текст сообщения
+// и, при необходимости, значение атрибута KeyAttr. Ошибки пропускаются всегда.
+// По окончании окна в обёрнутый обработчик пишется сводка подавленных сообщений.
+type SamplingHandler struct {
+	next    slog.Handler
+	sampler *sampler
+}
+
+// sampler — общее состояние семплирования для обработчика и его производных
+type sampler struct {
+	opts   SamplingOptions
+	base   slog.Handler // Обработчик для сводок, без атрибутов и групп производных обработчиков
+	window atomic.Pointer[samplingWindow]
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type samplingWindow struct {
+	counters sync.Map // Ключ → *samplingCounter
+	keys     atomic.Int64
+}
+
+type samplingCounter struct {
+	level      slog.Level
+	msg        string
+	keyValue   string
+	overflow   bool // Общий счётчик для сообщений сверх maxSamplingKeys
+	seen       atomic.Uint64
+	suppressed atomic.Uint64
+}
+
+// NewSamplingHandler оборачивает обработчик семплированием и запускает вывод сводок
+func NewSamplingHandler(next slog.Handler, opts SamplingOptions) *SamplingHandler {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultSamplingInterval
+	}
+	if opts.First < 0 {
+		opts.First = 0
+	}
+	if opts.Thereafter < 0 {
+		opts.Thereafter = 0
+	}
+
+	s := &sampler{
+		opts: opts,
+		base: next,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	s.window.Store(&samplingWindow{})
+	go s.run()
+
+	return &SamplingHandler{next: next, sampler: s}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError || h.sampler.allow(r) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{next: h.next.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{next: h.next.WithGroup(name), sampler: h.sampler}
+}
+
+// Close выводит сводку за текущее окно и останавливает фоновую горутину
+func (h *SamplingHandler) Close() {
+	h.sampler.stopOnce.Do(func() {
+		close(h.sampler.stop)
+		<-h.sampler.done
+	})
+}
+
+// Решение о пропуске записи в текущем окне
+func (s *sampler) allow(r slog.Record) bool {
+	keyValue := ""
+	if s.opts.KeyAttr != "" {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == s.opts.KeyAttr {
+				keyValue = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	key := r.Level.String() + "\x00" + r.Message + "\x00" + keyValue
+
+	w := s.window.Load()
+	value, ok := w.counters.Load(key)
+	if !ok {
+		counter := &samplingCounter{level: r.Level, msg: r.Message, keyValue: keyValue}
+		// Количество ключей в окне ограничено, остальные сообщения делят общий счётчик
+		if w.keys.Load() >= maxSamplingKeys {
+			key = overflowSamplingKey
+			counter = &samplingCounter{level: slog.LevelInfo, overflow: true}
+		}
+		var loaded bool
+		value, loaded = w.counters.LoadOrStore(key, counter)
+		if !loaded {
+			w.keys.Add(1)
+		}
+	}
+	c := value.(*samplingCounter)
+
+	n := c.seen.Add(1)
+	first := uint64(s.opts.First)
+	if n <= first {
+		return true
+	}
+	if s.opts.Thereafter > 0 && (n-first)%uint64(s.opts.Thereafter) == 0 {
+		return true
+	}
+	c.suppressed.Add(1)
+	return false
+}
+
+func (s *sampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.rotate()
+		case <-s.stop:
+			s.rotate()
+			return
+		}
+	}
+}
+
+// Начало нового окна и вывод сводки подавленных сообщений за прошедшее
+func (s *sampler) rotate() {
+	old := s.window.Swap(&samplingWindow{})
+
+	var suppressed []*samplingCounter
+	var total uint64
+	old.counters.Range(func(_, value any) bool {
+		c := value.(*samplingCounter)
+		if n := c.suppressed.Load(); n > 0 {
+			suppressed = append(suppressed, c)
+			total += n
+		}
+		return true
+	})
+	if total == 0 {
+		return
+	}
+
+	sort.Slice(suppressed, func(i, j int) bool {
+		return suppressed[i].suppressed.Load() > suppressed[j].suppressed.Load()
+	})
+	if len(suppressed) > maxSummaryKeys {
+		suppressed = suppressed[:maxSummaryKeys]
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	for _, c := range suppressed {
+		if !s.base.Enabled(ctx, c.level) {
+			continue
+		}
+		r := slog.NewRecord(now, c.level, "Сообщения подавлены семплированием", 0)
+		if c.overflow {
+			r.AddAttrs(slog.String("message", "(прочие)"))
+		} else {
+			r.AddAttrs(slog.String("message", c.msg))
+		}
+		if s.opts.KeyAttr != "" && c.keyValue != "" {
+			r.AddAttrs(slog.String(s.opts.KeyAttr, c.keyValue))
+		}
+		r.AddAttrs(
+			slog.Uint64("suppressed", c.suppressed.Load()),
+			slog.Duration("interval", s.opts.Interval),
+		)
+		_ = s.base.Handle(ctx, r)
+	}
+}
@@ -0,0 +1,225 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const summaryMsg = "Сообщения подавлены семплированием"
+
+// Обработчик, запоминающий записи
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+// Записи, прошедшие семплирование, и сводки, выведенные при Close
+func (h *recordingHandler) split() (passed, summaries []slog.Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.records {
+		if r.Message == summaryMsg {
+			summaries = append(summaries, r)
+		} else {
+			passed = append(passed, r)
+		}
+	}
+	return passed, summaries
+}
+
+func attrs(r slog.Record) map[string]slog.Value {
+	m := make(map[string]slog.Value)
+	r.Attrs(func(a slog.Attr) bool {
+		m[a.Key] = a.Value
+		return true
+	})
+	return m
+}
+
+// Окно длиннее теста: сводка выводится только при Close
+func newTestSampler(opts SamplingOptions) (*SamplingHandler, *recordingHandler) {
+	rec := &recordingHandler{}
+	opts.Interval = time.Hour
+	return NewSamplingHandler(rec, opts), rec
+}
+
+func TestSamplingFirstThereafter(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		first, thereafter int
+		n                 int
+		want              int // Пропущено сообщений
+	}{
+		{name: "только первые", first: 3, n: 10, want: 3},
+		{name: "первые и каждое третье", first: 2, thereafter: 3, n: 11, want: 5},
+		{name: "каждое второе", thereafter: 2, n: 10, want: 5},
+		{name: "без подавления", thereafter: 1, n: 5, want: 5},
+		{name: "меньше First", first: 10, n: 4, want: 4},
+		{name: "всё подавляется", n: 4},
+		{name: "отрицательные значения", first: -1, thereafter: -1, n: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, rec := newTestSampler(SamplingOptions{First: tc.first, Thereafter: tc.thereafter})
+			logger := slog.New(h)
+			for i := 0; i < tc.n; i++ {
+				logger.Info("повтор", "i", i)
+			}
+			h.Close()
+
+			passed, summaries := rec.split()
+			if len(passed) != tc.want {
+				t.Fatalf("пропущено %d сообщений, ожидается %d", len(passed), tc.want)
+			}
+			if tc.want == tc.n {
+				if len(summaries) != 0 {
+					t.Fatalf("сводка без подавленных сообщений: %v", summaries)
+				}
+				return
+			}
+			if len(summaries) != 1 {
+				t.Fatalf("сводок %d, ожидается 1", len(summaries))
+			}
+			a := attrs(summaries[0])
+			if a["message"].String() != "повтор" || a["suppressed"].Uint64() != uint64(tc.n-tc.want) || a["interval"].Duration() != time.Hour {
+				t.Errorf("сводка %v", a)
+			}
+			if summaries[0].Level != slog.LevelInfo {
+				t.Errorf("уровень сводки %v, ожидается уровень сообщения", summaries[0].Level)
+			}
+		})
+	}
+}
+
+// Ошибки не семплируются и в сводку не попадают
+func TestSamplingErrorsPass(t *testing.T) {
+	h, rec := newTestSampler(SamplingOptions{First: 1})
+	logger := slog.New(h)
+	for i := 0; i < 5; i++ {
+		logger.Error("сбой")
+		logger.Warn("предупреждение")
+	}
+	h.Close()
+
+	passed, summaries := rec.split()
+	errs := 0
+	for _, r := range passed {
+		if r.Level == slog.LevelError {
+			errs++
+		}
+	}
+	if errs != 5 || len(passed) != 6 {
+		t.Fatalf("пропущено %d ошибок и %d сообщений всего, ожидается 5 и 6", errs, len(passed))
+	}
+	if len(summaries) != 1 || attrs(summaries[0])["message"].String() != "предупреждение" || summaries[0].Level != slog.LevelWarn {
+		t.Fatalf("сводки %v", summaries)
+	}
+}
+
+// Ключ включает уровень и значение KeyAttr; производные обработчики делят счётчики
+func TestSamplingKeys(t *testing.T) {
+	h, rec := newTestSampler(SamplingOptions{First: 1, KeyAttr: "video"})
+	logger := slog.New(h)
+	for i := 0; i < 3; i++ {
+		logger.Info("запрос", "video", "a")
+		logger.Info("запрос", "video", "b")
+		logger.Warn("запрос", "video", "a")
+		logger.With("tenant", "acme").Info("запрос", "video", "a")
+	}
+	h.Close()
+
+	passed, summaries := rec.split()
+	if len(passed) != 3 {
+		t.Fatalf("пропущено %d сообщений, ожидается по одному на ключ", len(passed))
+	}
+	got := make(map[string]uint64)
+	for _, r := range summaries {
+		a := attrs(r)
+		got[r.Level.String()+" "+a["video"].String()] = a["suppressed"].Uint64()
+	}
+	want := map[string]uint64{"INFO a": 5, "INFO b": 2, "WARN a": 2}
+	for key, n := range want {
+		if got[key] != n {
+			t.Errorf("%s: подавлено %d, ожидается %d (%v)", key, got[key], n, got)
+		}
+	}
+}
+
+// Сообщения сверх maxSamplingKeys делят общий счётчик, а сводка ограничена maxSummaryKeys
+func TestSamplingKeyOverflow(t *testing.T) {
+	h, rec := newTestSampler(SamplingOptions{First: 1})
+	logger := slog.New(h)
+	const extra = 50
+	for i := 0; i < maxSamplingKeys+extra; i++ {
+		msg := "сообщение " + strconv.Itoa(i)
+		logger.Info(msg)
+		if i < 2*maxSummaryKeys {
+			logger.Info(msg)
+		}
+	}
+	if n := h.sampler.window.Load().keys.Load(); n != maxSamplingKeys+1 {
+		t.Fatalf("ключей в окне %d, ожидается %d", n, maxSamplingKeys+1)
+	}
+	h.Close()
+
+	passed, summaries := rec.split()
+	// Из сообщений сверх лимита проходит только первое
+	if len(passed) != maxSamplingKeys+1 {
+		t.Fatalf("пропущено %d сообщений, ожидается %d", len(passed), maxSamplingKeys+1)
+	}
+	if len(summaries) != maxSummaryKeys {
+		t.Fatalf("сводок %d, ожидается %d", len(summaries), maxSummaryKeys)
+	}
+	// Общий счётчик подавил больше всех и идёт первым
+	if a := attrs(summaries[0]); a["message"].String() != "(прочие)" || a["suppressed"].Uint64() != extra-1 {
+		t.Errorf("первая сводка %v", a)
+	}
+}
+
+// Close выводит сводку один раз, повторный вызов ничего не делает
+func TestSamplingClose(t *testing.T) {
+	h, rec := newTestSampler(SamplingOptions{})
+	slog.New(h).Info("тихо")
+	h.Close()
+	h.Close()
+
+	if _, summaries := rec.split(); len(summaries) != 1 {
+		t.Fatalf("сводок %d, ожидается 1", len(summaries))
+	}
+}
+
+// По окончании окна сводка выводится без Close
+func TestSamplingInterval(t *testing.T) {
+	rec := &recordingHandler{}
+	h := NewSamplingHandler(rec, SamplingOptions{First: 1, Interval: 10 * time.Millisecond})
+	defer h.Close()
+	logger := slog.New(h)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		logger.Info("окно")
+		logger.Info("окно")
+		if _, summaries := rec.split(); len(summaries) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("сводка за окно не выведена")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
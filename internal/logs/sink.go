@@ -0,0 +1,425 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSinkBatchSize    = 500                    // Максимальный размер пакета для приёмника
+	defaultSinkInterval     = 1 * time.Second        // Максимальное время накопления пакета
+	defaultSinkBufferSize   = 10000                  // Ёмкость буфера приёмника
+	defaultSinkMaxRetries   = 3                      // Количество повторов при ошибке записи
+	defaultSinkRetryBackoff = 200 * time.Millisecond // Начальная пауза между повторами
+	defaultSinkWriteTimeout = 5 * time.Second        // Тайм-аут одной попытки записи
+)
+
+// Sink — приёмник сообщений асинхронного логгера.
+// Write вызывается из одной горутины; пакет нельзя сохранять после возврата.
+type Sink interface {
+	Write(ctx context.Context, batch []LogMessage) error
+	Close() error
+}
+
+// SinkOptions задаёт фильтрацию, пакетирование и повторы для приёмника.
+// Нулевые значения заменяются значениями по умолчанию.
+type SinkOptions struct {
+	MinLevel      slog.Level    // Сообщения ниже этого уровня приёмнику не передаются
+	BatchSize     int           // Максимальный размер пакета
+	FlushInterval time.Duration // Максимальное время накопления пакета
+	BufferSize    int           // Ёмкость буфера; при переполнении новые сообщения отбрасываются
+	MaxRetries    int           // Количество повторов при ошибке записи
+	RetryBackoff  time.Duration // Начальная пауза между повторами, удваивается с каждой попыткой
+}
+
+// SinkStats — счётчики приёмника
+type SinkStats struct {
+	Name    string
+	Written uint64 // Успешно записанные сообщения
+	Dropped uint64 // Отброшенные из-за переполнения буфера
+	Failed  uint64 // Потерянные после исчерпания повторов
+}
+
+// permanentError — ошибка, при которой повтор записи бессмысленен
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку записи как неисправимую: пакет не будет повторно отправляться
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// sinkRunner накапливает сообщения для одного приёмника и пишет их пакетами в своей горутине
+type sinkRunner struct {
+	name string
+	sink Sink
+	opts SinkOptions
+
+	queue    chan LogMessage
+	flushReq chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+var (
+	sinksMu sync.Mutex
+	sinks   atomic.Pointer[[]*sinkRunner] // Зарегистрированные приёмники; пусто — запись в slog
+)
+
+// AddSink регистрирует приёмник. Пока не зарегистрирован ни один приёмник,
+// сообщения пишутся в slog.Default.
+func AddSink(name string, sink Sink, opts SinkOptions) {
+	r := newSinkRunner(name, sink, opts)
+
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	var runners []*sinkRunner
+	if current := sinks.Load(); current != nil {
+		runners = append(runners, *current...)
+	}
+	runners = append(runners, r)
+	sinks.Store(&runners)
+}
+
+// Запуск горутины приёмника с параметрами по умолчанию вместо нулевых
+func newSinkRunner(name string, sink Sink, opts SinkOptions) *sinkRunner {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultSinkBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultSinkInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultSinkBufferSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultSinkMaxRetries
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultSinkRetryBackoff
+	}
+
+	r := &sinkRunner{
+		name:     name,
+		sink:     sink,
+		opts:     opts,
+		queue:    make(chan LogMessage, opts.BufferSize),
+		flushReq: make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Sinks возвращает счётчики зарегистрированных приёмников
+func Sinks() []SinkStats {
+	current := sinks.Load()
+	if current == nil {
+		return nil
+	}
+	stats := make([]SinkStats, 0, len(*current))
+	for _, r := range *current {
+		stats = append(stats, SinkStats{
+			Name:    r.name,
+			Written: r.written.Load(),
+			Dropped: r.dropped.Load(),
+			Failed:  r.failed.Load(),
+		})
+	}
+	return stats
+}
+
+// Передача пакета приёмникам с учётом их уровня. Возвращает false, если приёмников нет.
+func dispatchToSinks(batch []LogMessage) bool {
+	current := sinks.Load()
+	if current == nil || len(*current) == 0 {
+		return false
+	}
+	for _, r := range *current {
+		for _, logMsg := range batch {
+			if logMsg.Level < r.opts.MinLevel {
+				continue
+			}
+			select {
+			case r.queue <- logMsg:
+			default:
+				r.dropped.Add(1)
+				droppedCount.Add(1)
+			}
+		}
+	}
+	return true
+}
+
+// Принудительная запись буферов всех приёмников
+func flushSinks(ctx context.Context) error {
+	current := sinks.Load()
+	if current == nil {
+		return nil
+	}
+	for _, r := range *current {
+		done := make(chan struct{})
+		select {
+		case r.flushReq <- done:
+		case <-r.done:
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Остановка всех приёмников с записью оставшихся сообщений
+func closeSinks() {
+	sinksMu.Lock()
+	current := sinks.Swap(nil)
+	sinksMu.Unlock()
+	if current == nil {
+		return
+	}
+	for _, r := range *current {
+		close(r.stop)
+	}
+	for _, r := range *current {
+		<-r.done
+	}
+}
+
+func (r *sinkRunner) run() {
+	defer close(r.done)
+
+	batch := make([]LogMessage, 0, r.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			r.write(batch)
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case logMsg := <-r.queue:
+				batch = append(batch, logMsg)
+				if len(batch) >= r.opts.BatchSize {
+					flush()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case logMsg := <-r.queue:
+			batch = append(batch, logMsg)
+			if len(batch) >= r.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case done := <-r.flushReq:
+			drain()
+			flush()
+			close(done)
+		case <-r.stop:
+			drain()
+			flush()
+			if err := r.sink.Close(); err != nil {
+				slog.Error("Ошибка закрытия приёмника логов", "sink", r.name, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// Запись пакета с повторами и экспоненциальной паузой
+func (r *sinkRunner) write(batch []LogMessage) {
+	backoff := r.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultSinkWriteTimeout)
+		err := r.sink.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			r.written.Add(uint64(len(batch)))
+			return
+		}
+
+		var permanent permanentError
+		if attempt >= r.opts.MaxRetries || errors.As(err, &permanent) {
+			r.failed.Add(uint64(len(batch)))
+			droppedCount.Add(uint64(len(batch)))
+			slog.Error("Не удалось записать логи в приёмник", "sink", r.name, "count", len(batch), "error", err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// newRecord собирает slog.Record из сообщения асинхронного логгера
+func newRecord(logMsg LogMessage) slog.Record {
+	t := logMsg.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	record := slog.NewRecord(t, logMsg.Level, logMsg.Msg, 0)
+	record.Add(logMsg.Args...)
+	return record
+}
+
+// encodeJSONLines кодирует пакет в формат NDJSON: по одному JSON-объекту на строку
+func encodeJSONLines(batch []LogMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4})
+	for _, logMsg := range batch {
+		if err := handler.Handle(context.Background(), newRecord(logMsg)); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// slogSink пишет сообщения в slog.Default
+type slogSink struct{}
+
+func (slogSink) Write(ctx context.Context, batch []LogMessage) error {
+	writeToSlog(ctx, batch)
+	return nil
+}
+
+func (slogSink) Close() error { return nil }
+
+// ParseSink создаёт приёмник по строке вида:
+//
+//	stdout
+//	file:///var/log/balancer.log
+//	syslog+udp://host:514, syslog+tcp://host:601
+//	http://collector/ingest, https://collector/ingest
+//
+// Общие параметры в query: level (debug, info, warn, error), batch, interval, buffer, retries.
+// Для syslog дополнительно поддерживается app — имя приложения.
+func ParseSink(spec string) (Sink, SinkOptions, error) {
+	var opts SinkOptions
+	if spec == "stdout" {
+		return slogSink{}, opts, nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, opts, fmt.Errorf("некорректный приёмник логов %q: %w", spec, err)
+	}
+	query := u.Query()
+	if err := parseSinkOptions(query, &opts); err != nil {
+		return nil, opts, fmt.Errorf("некорректный приёмник логов %q: %w", spec, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		sink, err := NewFileSink(path)
+		return sink, opts, err
+	case "syslog+udp", "syslog+tcp":
+		app := query.Get("app")
+		if app == "" {
+			app = "videobalance"
+		}
+		sink, err := NewSyslogSink(strings.TrimPrefix(u.Scheme, "syslog+"), u.Host, app)
+		return sink, opts, err
+	case "http", "https":
+		u.RawQuery = query.Encode()
+		sink, err := NewHTTPSink(u.String())
+		return sink, opts, err
+	default:
+		return nil, opts, fmt.Errorf("неизвестный тип приёмника логов %q", u.Scheme)
+	}
+}
+
+// Разбор общих параметров приёмника. Разобранные параметры удаляются из query.
+func parseSinkOptions(query url.Values, opts *SinkOptions) error {
+	if v := query.Get("level"); v != "" {
+		if err := opts.MinLevel.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("level: %w", err)
+		}
+	}
+	intParams := map[string]*int{"batch": &opts.BatchSize, "buffer": &opts.BufferSize, "retries": &opts.MaxRetries}
+	for name, dst := range intParams {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	if v := query.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("interval: %w", err)
+		}
+		opts.FlushInterval = d
+	}
+	if opts.MaxRetries == 0 && query.Get("retries") != "" {
+		// Явный retries=0 отключает повторы, а нулевое значение в SinkOptions означает значение по умолчанию
+		opts.MaxRetries = -1
+	}
+	for _, name := range []string{"level", "batch", "buffer", "retries", "interval", "app"} {
+		query.Del(name)
+	}
+	return nil
+}
+
+// ConfigureSinks регистрирует приёмники по списку строк, см. ParseSink
+func ConfigureSinks(specs []string) error {
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		sink, opts, err := ParseSink(spec)
+		if err != nil {
+			return err
+		}
+		// В имени приёмника не должно быть пароля из URL
+		name := spec
+		if u, err := url.Parse(spec); err == nil && u.User != nil {
+			name = u.Redacted()
+		}
+		AddSink(name, sink, opts)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dir", "balancer.log")
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), []LogMessage{
+		{Time: ts, Level: slog.LevelInfo, Msg: "первое", Args: []interface{}{"video", "a.m3u8"}},
+		{Time: ts, Level: slog.LevelError, Msg: "второе", Args: []interface{}{"code", 3}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повторное открытие дописывает файл
+	sink, err = NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), []LogMessage{{Time: ts, Level: slog.LevelWarn, Msg: "третье"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("строка %q не JSON: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ msg, level string }{{"первое", "INFO"}, {"второе", "ERROR"}, {"третье", "WARN"}}
+	if len(lines) != len(want) {
+		t.Fatalf("в файле %d строк, ожидалось %d", len(lines), len(want))
+	}
+	for i, w := range want {
+		if lines[i]["msg"] != w.msg || lines[i]["level"] != w.level {
+			t.Errorf("строка %d: %v, ожидалось msg=%s level=%s", i, lines[i], w.msg, w.level)
+		}
+	}
+	if lines[0]["video"] != "a.m3u8" || lines[1]["code"] != float64(3) {
+		t.Errorf("атрибуты не записаны: %v, %v", lines[0], lines[1])
+	}
+	if lines[0]["time"] != ts.Format(time.RFC3339) {
+		t.Errorf("время %v, ожидалось %s", lines[0]["time"], ts.Format(time.RFC3339))
+	}
+}
+
+func TestNewFileSinkEmptyPath(t *testing.T) {
+	if _, err := NewFileSink(""); err == nil {
+		t.Fatal("ожидалась ошибка для пустого пути")
+	}
+}
@@ -0,0 +1,63 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPSink отправляет пакеты сообщений POST-запросом в формате NDJSON
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink создаёт приёмник, отправляющий пакеты на указанный URL
+func NewHTTPSink(url string) (*HTTPSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("не указан URL приёмника логов")
+	}
+	return &HTTPSink{
+		url:    url,
+		client: &http.Client{Timeout: defaultSinkWriteTimeout + time.Second},
+	}, nil
+}
+
+func (s *HTTPSink) Write(ctx context.Context, batch []LogMessage) error {
+	data, err := encodeJSONLines(batch)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Дочитываем тело, чтобы соединение можно было переиспользовать
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("приёмник логов ответил %s", resp.Status)
+	default:
+		// Остальные ошибки клиента повтором не исправить
+		return Permanent(fmt.Errorf("приёмник логов ответил %s", resp.Status))
+	}
+}
+
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,131 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPSink(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "успех", status: http.StatusOK},
+		{name: "принято", status: http.StatusAccepted},
+		{name: "перегрузка повторяется", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "ошибка сервера повторяется", status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "ошибка клиента не повторяется", status: http.StatusBadRequest, wantErr: true, wantPermanent: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body []byte
+			var header http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Clone()
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			sink, err := NewHTTPSink(srv.URL + "/ingest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+			err = sink.Write(context.Background(), []LogMessage{
+				{Time: time.Now(), Level: slog.LevelInfo, Msg: "первое"},
+				{Time: time.Now(), Level: slog.LevelWarn, Msg: "второе", Args: []interface{}{"tenant", "acme"}},
+			})
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Write() = %v, ожидалась ошибка: %v", err, tc.wantErr)
+			}
+			var permanent permanentError
+			if errors.As(err, &permanent) != tc.wantPermanent {
+				t.Fatalf("Write() = %v, неисправимая ошибка ожидалась: %v", err, tc.wantPermanent)
+			}
+			if ct := header.Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Content-Type %q", ct)
+			}
+			lines := bytes.Split(bytes.TrimRight(body, "\n"), []byte("\n"))
+			if len(lines) != 2 {
+				t.Fatalf("в теле %d строк, ожидалось 2: %s", len(lines), body)
+			}
+			var second map[string]any
+			if err := json.Unmarshal(lines[1], &second); err != nil || second["msg"] != "второе" || second["tenant"] != "acme" {
+				t.Errorf("вторая строка %s: %v", lines[1], err)
+			}
+		})
+	}
+}
+
+// Временные ошибки HTTP повторяются приёмником с паузой, а 4xx — нет
+func TestHTTPSinkRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantOK    bool
+	}{
+		{name: "503 затем 200", statuses: []int{503, 503, 200}, wantCalls: 3, wantOK: true},
+		{name: "400 без повторов", statuses: []int{400, 200}, wantCalls: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				w.WriteHeader(tc.statuses[min(calls, len(tc.statuses)-1)])
+				calls++
+			}))
+			defer srv.Close()
+
+			sink, err := NewHTTPSink(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newSinkRunner("http", sink, SinkOptions{MaxRetries: 3, RetryBackoff: time.Millisecond, FlushInterval: time.Hour})
+			defer func() {
+				close(r.stop)
+				<-r.done
+			}()
+			r.write(testMessages(1))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if calls != tc.wantCalls {
+				t.Fatalf("запросов %d, ожидалось %d", calls, tc.wantCalls)
+			}
+			if ok := r.written.Load() == 1; ok != tc.wantOK {
+				t.Fatalf("записано %d, потеряно %d", r.written.Load(), r.failed.Load())
+			}
+		})
+	}
+}
+
+func TestHTTPSinkUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	sink, err := NewHTTPSink(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Write(context.Background(), testMessages(1))
+	var permanent permanentError
+	if err == nil || errors.As(err, &permanent) {
+		t.Fatalf("Write() = %v, ожидалась временная ошибка", err)
+	}
+}
@@ -0,0 +1,149 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	syslogFacilityLocal0 = 16                                 // Facility local0 по RFC 5424
+	syslogTimeFormat     = "2006-01-02T15:04:05.000000Z07:00" // TIMESTAMP по RFC 5424 с микросекундами
+	syslogDialTimeout    = 5 * time.Second                    // Тайм-аут подключения к серверу syslog
+	syslogBOM            = "\ufeff"                           // BOM перед MSG означает текст в UTF-8
+)
+
+// SyslogSink отправляет сообщения в формате RFC 5424 по UDP (одно сообщение на датаграмму)
+// или TCP (с подсчётом октетов по RFC 6587). При ошибке соединение переустанавливается.
+type SyslogSink struct {
+	network  string
+	addr     string
+	app      string
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink создаёт приёмник syslog. network — udp или tcp.
+func NewSyslogSink(network, addr, app string) (*SyslogSink, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("неподдерживаемый протокол syslog %q", network)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("не указан адрес сервера syslog")
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{
+		network:  network,
+		addr:     addr,
+		app:      app,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+func (s *SyslogSink) Write(ctx context.Context, batch []LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: syslogDialTimeout}
+		conn, err := dialer.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.conn.SetWriteDeadline(deadline)
+	}
+
+	var buf bytes.Buffer
+	for _, logMsg := range batch {
+		msg := s.format(logMsg)
+		buf.Reset()
+		if s.network == "tcp" {
+			// Подсчёт октетов: длина сообщения, пробел, сообщение
+			buf.WriteString(strconv.Itoa(len(msg)))
+			buf.WriteByte(' ')
+		}
+		buf.Write(msg)
+
+		if _, err := s.conn.Write(buf.Bytes()); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// format формирует сообщение RFC 5424:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG.
+// Атрибуты сообщения передаются в MSG в формате key=value, так как их ключи
+// могут содержать символы, недопустимые в именах STRUCTURED-DATA.
+func (s *SyslogSink) format(logMsg LogMessage) []byte {
+	record := newRecord(logMsg)
+	pri := syslogFacilityLocal0*8 + syslogSeverity(logMsg.Level)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - - %s%s",
+		pri, record.Time.Format(syslogTimeFormat), s.hostname, s.app, s.procID, syslogBOM, logMsg.Msg)
+
+	handler := slog.NewTextHandler(&attrWriter{buf: &buf}, &slog.HandlerOptions{
+		Level: slog.LevelDebug - 4,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Время, уровень и сообщение уже есть в заголовке
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	if record.NumAttrs() > 0 {
+		buf.WriteByte(' ')
+		_ = handler.Handle(context.Background(), record)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// attrWriter дописывает вывод TextHandler в общий буфер
+type attrWriter struct{ buf *bytes.Buffer }
+
+func (w *attrWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+// syslogSeverity отображает уровень slog в severity syslog
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // Error
+	case level >= slog.LevelWarn:
+		return 4 // Warning
+	case level >= slog.LevelInfo:
+		return 6 // Informational
+	default:
+		return 7 // Debug
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
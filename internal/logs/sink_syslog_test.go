@@ -0,0 +1,191 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Заголовок RFC 5424: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA, затем BOM и MSG
+var syslogHeader = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\d+) - - \x{FEFF}(.*)$`)
+
+func syslogTestMessages() []LogMessage {
+	ts := time.Date(2024, 5, 1, 12, 30, 45, 123456000, time.UTC)
+	return []LogMessage{
+		{Time: ts, Level: slog.LevelError, Msg: "ошибка маршрута", Args: []interface{}{"video", "a b.m3u8", "code", 3}},
+		{Time: ts, Level: slog.LevelInfo, Msg: "hello"},
+		{Time: ts, Level: slog.LevelDebug, Msg: "отладка"},
+	}
+}
+
+// Проверка сообщения RFC 5424 против исходного
+func checkSyslogMessage(t *testing.T, msg []byte, want LogMessage) {
+	t.Helper()
+	m := syslogHeader.FindSubmatch(msg)
+	if m == nil {
+		t.Fatalf("сообщение не в формате RFC 5424: %q", msg)
+	}
+	pri, _ := strconv.Atoi(string(m[1]))
+	if wantPri := syslogFacilityLocal0*8 + syslogSeverity(want.Level); pri != wantPri {
+		t.Errorf("PRI %d, ожидалось %d", pri, wantPri)
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, string(m[2])); err != nil || !ts.Equal(want.Time) {
+		t.Errorf("TIMESTAMP %s (%v), ожидалось %v", m[2], err, want.Time)
+	}
+	if string(m[4]) != "videobalance" || string(m[5]) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("APP-NAME %s, PROCID %s", m[4], m[5])
+	}
+	if !bytes.HasPrefix(m[6], []byte(want.Msg)) {
+		t.Errorf("MSG %q, ожидалось начало %q", m[6], want.Msg)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSyslogSink("udp", conn.LocalAddr().String(), "videobalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	batch := syslogTestMessages()
+	if err := sink.Write(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+
+	// По одному сообщению на датаграмму, без подсчёта октетов
+	buf := make([]byte, 64*1024)
+	for i, want := range batch {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("датаграмма %d: %v", i, err)
+		}
+		checkSyslogMessage(t, buf[:n], want)
+		if i == 0 && !bytes.HasSuffix(buf[:n], []byte(` video="a b.m3u8" code=3`)) {
+			t.Errorf("атрибуты не в формате key=value: %q", buf[:n])
+		}
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			conn.Close()
+			received <- data
+		}
+	}()
+
+	sink, err := NewSyslogSink("tcp", ln.Addr().String(), "videobalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := syslogTestMessages()
+	if err := sink.Write(context.Background(), batch[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), batch[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	select {
+	case data = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("сервер syslog не получил данных")
+	}
+
+	// Подсчёт октетов по RFC 6587: MSG-LEN SP SYSLOG-MSG, длина в байтах, а не в символах
+	for i, want := range batch {
+		lenStr, rest, ok := bytes.Cut(data, []byte(" "))
+		if !ok {
+			t.Fatalf("кадр %d без длины: %q", i, data)
+		}
+		n, err := strconv.Atoi(string(lenStr))
+		if err != nil || n > len(rest) {
+			t.Fatalf("кадр %d: неверная длина %q (осталось %d байт)", i, lenStr, len(rest))
+		}
+		checkSyslogMessage(t, rest[:n], want)
+		data = rest[n:]
+	}
+	if len(data) != 0 {
+		t.Fatalf("после кадров остались данные: %q", data)
+	}
+}
+
+// После ошибки записи соединение закрывается, а следующий пакет устанавливает новое
+func TestSyslogSinkTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	sink, err := NewSyslogSink("tcp", addr, "videobalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Write(context.Background(), syslogTestMessages()[:1]); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	ln.Close()
+
+	// Запись в закрытое сервером соединение завершается ошибкой не сразу
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.Write(context.Background(), syslogTestMessages()[:1]) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("запись в закрытое соединение не завершилась ошибкой")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("адрес %s занят: %v", addr, err)
+	}
+	defer ln.Close()
+	if err := sink.Write(context.Background(), syslogTestMessages()[:1]); err != nil {
+		t.Fatalf("запись после переподключения: %v", err)
+	}
+}
+
+func TestNewSyslogSinkValidation(t *testing.T) {
+	if _, err := NewSyslogSink("unix", "/dev/log", "app"); err == nil {
+		t.Error("ожидалась ошибка для неподдерживаемого протокола")
+	}
+	if _, err := NewSyslogSink("udp", "", "app"); err == nil {
+		t.Error("ожидалась ошибка для пустого адреса")
+	}
+}
@@ -0,0 +1,188 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink запоминает пакеты и время вызовов; fail возвращает ошибку для очередного вызова
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]LogMessage
+	calls   []time.Time
+	fail    func(call int) error
+	closed  bool
+}
+
+func (s *recordingSink) Write(_ context.Context, batch []LogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, time.Now())
+	if s.fail != nil {
+		if err := s.fail(len(s.calls)); err != nil {
+			return err
+		}
+	}
+	s.batches = append(s.batches, append([]LogMessage(nil), batch...))
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func testMessages(n int) []LogMessage {
+	batch := make([]LogMessage, n)
+	for i := range batch {
+		batch[i] = LogMessage{Time: time.Now(), Level: slog.LevelInfo, Msg: "сообщение " + strconv.Itoa(i)}
+	}
+	return batch
+}
+
+func TestSinkRunnerBatching(t *testing.T) {
+	sink := &recordingSink{}
+	r := newSinkRunner("test", sink, SinkOptions{BatchSize: 3, FlushInterval: time.Hour})
+	for _, logMsg := range testMessages(7) {
+		r.queue <- logMsg
+	}
+	done := make(chan struct{})
+	r.flushReq <- done
+	<-done
+	close(r.stop)
+	<-r.done
+
+	var sizes []int
+	next := 0
+	for _, batch := range sink.batches {
+		sizes = append(sizes, len(batch))
+		for _, logMsg := range batch {
+			if want := "сообщение " + strconv.Itoa(next); logMsg.Msg != want {
+				t.Fatalf("сообщение %q, ожидалось %q", logMsg.Msg, want)
+			}
+			next++
+		}
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Fatalf("размеры пакетов %v, ожидалось [3 3 1]", sizes)
+	}
+	if !sink.closed {
+		t.Fatal("приёмник не закрыт при остановке")
+	}
+	if got := r.written.Load(); got != 7 {
+		t.Fatalf("записано %d сообщений, ожидалось 7", got)
+	}
+}
+
+func TestSinkRunnerFlushInterval(t *testing.T) {
+	sink := &recordingSink{}
+	r := newSinkRunner("test", sink, SinkOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer func() {
+		close(r.stop)
+		<-r.done
+	}()
+	r.queue <- testMessages(1)[0]
+
+	deadline := time.Now().Add(time.Second)
+	for r.written.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("неполный пакет не записан по интервалу")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSinkRunnerRetry(t *testing.T) {
+	errTemporary := errors.New("временная ошибка")
+	backoff := 10 * time.Millisecond
+
+	for _, tc := range []struct {
+		name                  string
+		maxRetries            int
+		fail                  func(call int) error
+		wantCalls             int
+		wantWritten, wantFail uint64
+	}{
+		{
+			name:       "успех после повторов",
+			maxRetries: 3,
+			fail: func(call int) error {
+				if call <= 2 {
+					return errTemporary
+				}
+				return nil
+			},
+			wantCalls:   3,
+			wantWritten: 2,
+		},
+		{
+			name:       "повторы исчерпаны",
+			maxRetries: 2,
+			fail:       func(int) error { return errTemporary },
+			wantCalls:  3,
+			wantFail:   2,
+		},
+		{
+			name:       "повторы отключены",
+			maxRetries: -1,
+			fail:       func(int) error { return errTemporary },
+			wantCalls:  1,
+			wantFail:   2,
+		},
+		{
+			name:       "неисправимая ошибка не повторяется",
+			maxRetries: 3,
+			fail:       func(int) error { return Permanent(errTemporary) },
+			wantCalls:  1,
+			wantFail:   2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &recordingSink{fail: tc.fail}
+			r := newSinkRunner("test", sink, SinkOptions{MaxRetries: tc.maxRetries, RetryBackoff: backoff, FlushInterval: time.Hour})
+			defer func() {
+				close(r.stop)
+				<-r.done
+			}()
+
+			// write вызывается напрямую: горутина приёмника в это время ждёт сообщений
+			r.write(testMessages(2))
+
+			if len(sink.calls) != tc.wantCalls {
+				t.Fatalf("попыток записи %d, ожидалось %d", len(sink.calls), tc.wantCalls)
+			}
+			// Пауза удваивается с каждой попыткой
+			for i := 1; i < len(sink.calls); i++ {
+				want := backoff << (i - 1)
+				if gap := sink.calls[i].Sub(sink.calls[i-1]); gap < want {
+					t.Fatalf("пауза перед попыткой %d — %v, ожидалось не меньше %v", i+1, gap, want)
+				}
+			}
+			if got := r.written.Load(); got != tc.wantWritten {
+				t.Fatalf("записано %d, ожидалось %d", got, tc.wantWritten)
+			}
+			if got := r.failed.Load(); got != tc.wantFail {
+				t.Fatalf("потеряно %d, ожидалось %d", got, tc.wantFail)
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	if Permanent(nil) != nil {
+		t.Fatal("Permanent(nil) должен возвращать nil")
+	}
+	base := errors.New("ошибка")
+	err := Permanent(base)
+	var permanent permanentError
+	if !errors.As(err, &permanent) || !errors.Is(err, base) {
+		t.Fatalf("Permanent(%v) = %#v", base, err)
+	}
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"videobalance/internal/cache"
+	"videobalance/internal/limiter"
+	"videobalance/internal/logs"
+	"videobalance/internal/offload"
+	"videobalance/internal/scheduler"
+	"videobalance/internal/worker"
+)
+
+// ServerStats — источник состояния балансировщика для метрик
+type ServerStats interface {
+	LimiterStats() limiter.Stats
+	OffloadStats() offload.Stats
+	Jobs() *scheduler.Scheduler
+}
+
+// RegisterServer добавляет метрики ограничителя, разгрузки origin и периодических задач
+// балансировщика, а также кэша, исполнителя и логгера. Значения читаются при каждом запросе /metrics.
+func RegisterServer(s ServerStats) {
+	Registry.MustRegister(&statsCollector{server: s})
+}
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_"+name, help, labels, nil)
+}
+
+var (
+	cacheHits      = desc("cache_hits_total", "Попадания в кэш маршрутов: fresh — актуальная запись, stale — устаревшая, negative — отклонённый URL.", "type")
+	cacheMisses    = desc("cache_misses_total", "Промахи кэша маршрутов.")
+	cacheEvictions = desc("cache_evictions_total", "Записи, вытесненные из кэша маршрутов при переполнении.")
+	cacheEntries   = desc("cache_entries", "Записи в кэше маршрутов.")
+
+	limiterLimit    = desc("limiter_limit", "Текущий адаптивный лимит параллельных запросов.")
+	limiterInFlight = desc("limiter_in_flight", "Выполняющиеся запросы по приоритету.", "priority")
+	limiterRejected = desc("limiter_rejected_total", "Запросы, отклонённые ограничителем, по приоритету. Сбрасывается при замене ограничителя.", "priority")
+	limiterRTT      = desc("limiter_rtt_seconds", "Задержка запросов: short — средняя за последнее окно, base — базовая.", "window")
+
+	workerWorkers   = desc("worker_workers", "Рабочие горутины исполнителя фоновых задач.")
+	workerQueued    = desc("worker_queued", "Задачи в очереди исполнителя.")
+	workerRunning   = desc("worker_running", "Выполняющиеся задачи исполнителя.")
+	workerTasks     = desc("worker_tasks_total", "Задачи исполнителя по результату: completed, failed (включая timeout и panic), timeout, panic, skipped — не запущены из-за отмены.", "result")
+	workerQueueWait = desc("worker_queue_wait_seconds", "Среднее ожидание задачи в очереди за последний интервал масштабирования.")
+	workerLatency   = desc("worker_task_seconds", "Среднее время выполнения задачи за последний интервал масштабирования.")
+	workerWaitHist  = desc("worker_queue_wait_duration_seconds", "Ожидание задач в очереди исполнителя. Сбрасывается при замене исполнителя.")
+
+	originShare       = desc("origin_share", "Доля запросов на origin: target — целевая, measured — измеренная.", "type")
+	originTargetRPS   = desc("origin_target_requests_per_second", "Целевые запросы в секунду на origin-сервер (0 — цель задана долей).")
+	originProbability = desc("origin_probability", "Вероятность отправки запроса на origin.")
+	originRPS         = desc("origin_requests_per_second", "Запросы в секунду на origin-сервер.", "server")
+	originServerProb  = desc("origin_server_probability", "Вероятность отправки на origin-сервер адресованного ему запроса.", "server")
+	originHot         = desc("origin_hot_requests_total", "Запросы популярных видео, оставленные на CDN.")
+
+	logDropped  = desc("log_dropped_total", "Сообщения асинхронного логгера, потерянные при переполнении канала.")
+	logOverflow = desc("log_overflow_total", "События переполнения канала логов по действию политики.", "action")
+	logSink     = desc("log_sink_messages_total", "Сообщения приёмников логов по результату: written, dropped, failed.", "sink", "result")
+
+	jobRuns     = desc("job_runs_total", "Запуски периодических задач.", "job")
+	jobFailures = desc("job_failures_total", "Неудачные запуски периодических задач.", "job")
+	jobDuration = desc("job_last_duration_seconds", "Длительность последнего запуска периодической задачи.", "job")
+)
+
+type statsCollector struct {
+	server ServerStats
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		cacheHits, cacheMisses, cacheEvictions, cacheEntries,
+		limiterLimit, limiterInFlight, limiterRejected, limiterRTT,
+		workerWorkers, workerQueued, workerRunning, workerTasks, workerQueueWait, workerLatency, workerWaitHist,
+		originShare, originTargetRPS, originProbability, originRPS, originServerProb, originHot,
+		logDropped, logOverflow, logSink,
+		jobRuns, jobFailures, jobDuration,
+	} {
+		ch <- d
+	}
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	counter := func(d *prometheus.Desc, v uint64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), labels...)
+	}
+	gauge := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+	}
+
+	cs := cache.GetStats()
+	counter(cacheHits, cs.Hits, "fresh")
+	counter(cacheHits, cs.StaleHits, "stale")
+	counter(cacheHits, cs.NegativeHits, "negative")
+	counter(cacheMisses, cs.Misses)
+	counter(cacheEvictions, cs.Evictions)
+	gauge(cacheEntries, float64(cs.Len))
+
+	ls := c.server.LimiterStats()
+	gauge(limiterLimit, float64(ls.Limit))
+	for _, p := range ls.Priorities {
+		gauge(limiterInFlight, float64(p.InFlight), p.Priority)
+		counter(limiterRejected, p.Rejected, p.Priority)
+	}
+	gauge(limiterRTT, ls.ShortRTT.Seconds(), "short")
+	gauge(limiterRTT, ls.BaseRTT.Seconds(), "base")
+
+	ws := worker.ExecutorStats()
+	gauge(workerWorkers, float64(ws.Workers))
+	gauge(workerQueued, float64(ws.Queued))
+	gauge(workerRunning, float64(ws.Running))
+	counter(workerTasks, ws.Completed, "completed")
+	counter(workerTasks, ws.Failed, "failed")
+	counter(workerTasks, ws.TimedOut, "timeout")
+	counter(workerTasks, ws.Panics, "panic")
+	counter(workerTasks, ws.Skipped, "skipped")
+	gauge(workerQueueWait, ws.QueueWait.Seconds())
+	gauge(workerLatency, ws.Latency.Seconds())
+	wh := ws.QueueWaitHistogram
+	buckets := make(map[float64]uint64, len(wh.Bounds))
+	for i, bound := range wh.Bounds {
+		buckets[bound.Seconds()] = wh.Counts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(workerWaitHist, wh.Count, wh.Sum.Seconds(), buckets)
+
+	of := c.server.OffloadStats()
+	gauge(originShare, of.TargetShare, "target")
+	gauge(originShare, of.MeasuredShare, "measured")
+	gauge(originTargetRPS, of.TargetRPS)
+	gauge(originProbability, of.Probability)
+	counter(originHot, of.HotRequests)
+	for _, o := range of.Origins {
+		gauge(originRPS, o.RPS, o.Server)
+		gauge(originServerProb, o.Probability, o.Server)
+	}
+
+	counter(logDropped, logs.Dropped())
+	ov := logs.Overflow()
+	counter(logOverflow, ov.DroppedNewest, "dropped_newest")
+	counter(logOverflow, ov.DroppedOldest, "dropped_oldest")
+	counter(logOverflow, ov.Blocked, "blocked")
+	counter(logOverflow, ov.BlockTimeouts, "block_timeout")
+	counter(logOverflow, ov.Spilled, "spilled")
+	counter(logOverflow, ov.SpillDropped, "spill_dropped")
+	counter(logOverflow, ov.Replayed, "replayed")
+	for _, s := range logs.Sinks() {
+		counter(logSink, s.Written, s.Name, "written")
+		counter(logSink, s.Dropped, s.Name, "dropped")
+		counter(logSink, s.Failed, s.Name, "failed")
+	}
+
+	for _, j := range c.server.Jobs().Status() {
+		counter(jobRuns, j.Runs, j.Name)
+		counter(jobFailures, j.Failures, j.Name)
+		if j.Runs > 0 {
+			gauge(jobDuration, j.LastDuration.Seconds(), j.Name)
+		}
+	}
+}
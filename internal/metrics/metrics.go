@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "videobalance"
+
+// Registry — реестр метрик сервиса; отдаётся через Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Redirects считает ответы Redirect по направлению (cdn, origin, none) и причине
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Запросы Redirect по направлению и причине решения.",
+	}, []string{"backend", "reason"})
+
+	// URLParseErrors считает URL, которые не удалось разобрать ParseVideoURL
+	URLParseErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "url_parse_errors_total",
+		Help:      "URL видео, которые не удалось разобрать.",
+	})
+
+	// BackendUp — результат последней проверки доступности CDN и origin-серверов: 1 — доступен
+	BackendUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backend_up",
+		Help:      "Доступность сервера по последней проверке: 1 — доступен, 0 — нет.",
+	}, []string{"backend", "server"})
+
+	rpcStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "started_total",
+		Help:      "Начатые вызовы gRPC.",
+	}, []string{"service", "method"})
+
+	rpcHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "handled_total",
+		Help:      "Завершённые вызовы gRPC по коду ответа.",
+	}, []string{"service", "method", "code"})
+
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "handling_seconds",
+		Help:      "Время обработки вызовов gRPC.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"service", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Redirects, URLParseErrors, BackendUp, rpcStarted, rpcHandled, rpcDuration,
+	)
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// UnaryServerInterceptor учитывает каждый унарный вызов: количество, код ответа и время обработки
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		done := observe(info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor учитывает потоковые вызовы; время — до завершения потока
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := observe(info.FullMethod)
+		err := handler(srv, ss)
+		done(err)
+		return err
+	}
+}
+
+// Учёт начала вызова; возвращённая функция учитывает его завершение
+func observe(fullMethod string) func(error) {
+	service, method := splitMethod(fullMethod)
+	rpcStarted.WithLabelValues(service, method).Inc()
+	start := time.Now()
+	return func(err error) {
+		rpcDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+		rpcHandled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	}
+}
+
+// Разбор полного имени метода /package.Service/Method
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", fullMethod
+	}
+	return service, method
+}
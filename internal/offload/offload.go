@@ -0,0 +1,358 @@
+package offload
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTargetShare = 0.1             // Доля запросов на origin по умолчанию
+	defaultInterval    = 1 * time.Second // Период пересчёта вероятности
+	defaultKp          = 0.5             // Пропорциональный коэффициент регулятора
+	defaultKi          = 0.5             // Интегральный коэффициент регулятора (1/с)
+	shareSmoothing     = 0.3             // Коэффициент EWMA для измеренной доли
+	maxTrackedOrigins  = 1024            // Максимальное количество отслеживаемых origin-серверов
+	minSampling        = 0.01            // Минимальная доля запросов, по которой оценивается спрос на origin в режиме TargetOriginRPS
+)
+
+// Options задаёт параметры регулятора. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	TargetShare     float64       // Целевая доля запросов на origin (0..1)
+	TargetOriginRPS float64       // Целевые запросы в секунду на один origin-сервер; если задано, заменяет TargetShare
+	MaxOriginRPS    float64       // Жёсткий лимит запросов в секунду на один origin-сервер поверх регулятора (0 — без лимита)
+	HotThreshold    uint64        // Видео с оценкой популярности не ниже порога всегда идут через CDN (0 — не учитывать)
+	Interval        time.Duration // Период пересчёта вероятности
+	Kp, Ki          float64       // Коэффициенты PI-регулятора
+}
+
+// OriginStats — измеренная нагрузка на origin-сервер
+type OriginStats struct {
+	Server      string
+	RPS         float64
+	Probability float64 // Вероятность отправки на этот сервер запроса, адресованного ему
+}
+
+// Stats — текущее состояние регулятора
+type Stats struct {
+	TargetShare   float64 // 0 в режиме TargetOriginRPS
+	TargetRPS     float64 // 0 в режиме TargetShare
+	MeasuredShare float64 // Сглаженная измеренная доля запросов на origin
+	Probability   float64 // Вероятность, с которой запрос проверяется на отправку на origin
+	HotRequests   uint64  // Запросы популярных видео, оставленные на CDN, с создания регулятора
+	Origins       []OriginStats
+}
+
+// Controller поддерживает нагрузку на origin на целевом уровне.
+// Раз в интервал PI-регулятор сравнивает измеренную нагрузку с целевой и корректирует
+// вероятность, с которой запрос отправляется на origin. Цель задаётся долей всех запросов
+// (TargetShare) или запросами в секунду на каждый origin-сервер (TargetOriginRPS):
+// во втором случае у каждого сервера свой регулятор. Популярные видео в выборку
+// не попадают: их выгоднее отдавать из CDN. Жёсткий лимит MaxOriginRPS ограничивает
+// нагрузку сверху, а регулятор компенсирует отказы и попадания в кэш.
+type Controller struct {
+	opts   Options
+	now    func() time.Time // Часы и источник случайных чисел подменяются в тестах
+	random func() float64
+
+	probability atomic.Uint64 // Вероятность в виде битов float64
+	total       atomic.Uint64 // Запросы за текущий интервал
+	offloaded   atomic.Uint64 // Запросы на origin за текущий интервал
+	rejected    atomic.Uint64 // Отказы из-за лимита origin-сервера за текущий интервал
+	hot         atomic.Uint64 // Запросы популярных видео, оставленные на CDN, всего
+
+	lastUpdate atomic.Int64 // Время последнего пересчёта в наносекундах Unix
+	updating   atomic.Bool  // Пересчёт уже выполняется другой горутиной
+
+	// Состояние регулятора изменяется только в update под флагом updating
+	share    float64
+	hasShare bool
+	integral float64
+	measured atomic.Uint64 // Сглаженная доля в виде битов float64
+
+	originsMu sync.RWMutex
+	origins   map[string]*origin
+}
+
+// Состояние origin-сервера: token bucket, счётчики за интервал и регулятор режима TargetOriginRPS
+type origin struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	demand float64 // Оценка запросов к серверу за текущий интервал, включая не прошедшие выборку
+
+	sent        atomic.Uint64 // Запросы за текущий интервал
+	rejected    atomic.Uint64 // Отказы из-за лимита за текущий интервал
+	rps         atomic.Uint64 // Измеренные запросы в секунду в виде битов float64
+	probability atomic.Uint64 // Вероятность отправки на сервер в виде битов float64
+
+	// Состояние регулятора изменяется только в update под флагом updating
+	share    float64 // Сглаженная доля спроса на сервер, отправленная на него
+	hasShare bool
+	integral float64
+}
+
+// New создаёт регулятор
+func New(opts Options) *Controller {
+	return newController(opts, time.Now, rand.Float64)
+}
+
+func newController(opts Options, now func() time.Time, random func() float64) *Controller {
+	switch {
+	case opts.TargetOriginRPS > 0:
+		opts.TargetShare = 0
+	case opts.TargetShare <= 0:
+		opts.TargetShare = defaultTargetShare
+	case opts.TargetShare > 1:
+		opts.TargetShare = 1
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.Kp <= 0 {
+		opts.Kp = defaultKp
+	}
+	if opts.Ki <= 0 {
+		opts.Ki = defaultKi
+	}
+
+	c := &Controller{
+		opts:    opts,
+		now:     now,
+		random:  random,
+		origins: make(map[string]*origin),
+	}
+	// В режиме TargetOriginRPS спрос ещё не измерен: до первого пересчёта
+	// на origin идёт только выборка для его оценки
+	c.probability.Store(math.Float64bits(math.Max(opts.TargetShare, minSampling)))
+	c.lastUpdate.Store(now().UnixNano())
+	return c
+}
+
+// Sample учитывает запрос и сообщает, следует ли попробовать отправить его на origin.
+// popularity — оценка количества запросов к видео: популярные видео на origin не отправляются,
+// но входят в измеряемую долю, поэтому регулятор добирает её за счёт остальных.
+func (c *Controller) Sample(popularity uint64) bool {
+	c.maybeUpdate()
+	c.total.Add(1)
+	if c.opts.HotThreshold > 0 && popularity >= c.opts.HotThreshold {
+		c.hot.Add(1)
+		return false
+	}
+
+	p := math.Float64frombits(c.probability.Load())
+	return p > 0 && c.random() < p
+}
+
+// Admit проверяет лимит нагрузки на origin-сервер и учитывает отправленный на него запрос.
+// В режиме TargetOriginRPS запрос, прошедший Sample, дополнительно проходит выборку
+// с вероятностью регулятора этого сервера.
+func (c *Controller) Admit(server string) bool {
+	o := c.origin(server)
+	if c.opts.TargetOriginRPS > 0 {
+		// Без состояния сервера нагрузку на него не удержать, запрос остаётся на CDN
+		if o == nil || !o.sample(math.Float64frombits(c.probability.Load()), c.random) {
+			return false
+		}
+	}
+	if o != nil && c.opts.MaxOriginRPS > 0 && !o.take(c.opts.MaxOriginRPS, c.now()) {
+		c.rejected.Add(1)
+		o.rejected.Add(1)
+		return false
+	}
+
+	c.offloaded.Add(1)
+	if o != nil {
+		o.sent.Add(1)
+	}
+	return true
+}
+
+// Получение состояния origin-сервера. Если отслеживаемых серверов слишком много, возвращает nil.
+func (c *Controller) origin(server string) *origin {
+	c.originsMu.RLock()
+	o, ok := c.origins[server]
+	c.originsMu.RUnlock()
+	if ok {
+		return o
+	}
+
+	c.originsMu.Lock()
+	defer c.originsMu.Unlock()
+	if o, ok := c.origins[server]; ok {
+		return o
+	}
+	if len(c.origins) >= maxTrackedOrigins {
+		return nil
+	}
+	o = &origin{tokens: c.opts.MaxOriginRPS, last: c.now()}
+	c.origins[server] = o
+	return o
+}
+
+// Учёт спроса на сервер и выборка запроса, уже прошедшего Sample с вероятностью sampled
+func (o *origin) sample(sampled float64, random func() float64) bool {
+	if sampled <= 0 {
+		return false
+	}
+	o.mu.Lock()
+	o.demand += 1 / sampled
+	o.mu.Unlock()
+
+	p := math.Float64frombits(o.probability.Load())
+	return p > 0 && random()*sampled < p
+}
+
+// Забор токена из bucket с ёмкостью в одну секунду нагрузки
+func (o *origin) take(rps float64, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.tokens = math.Min(rps, o.tokens+now.Sub(o.last).Seconds()*rps)
+	o.last = now
+	if o.tokens < 1 {
+		return false
+	}
+	o.tokens--
+	return true
+}
+
+// Пересчёт вероятности: не чаще раза в интервал и только в одной горутине
+func (c *Controller) maybeUpdate() {
+	now := c.now().UnixNano()
+	last := c.lastUpdate.Load()
+	if time.Duration(now-last) < c.opts.Interval || !c.updating.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.updating.Store(false)
+
+	if last = c.lastUpdate.Load(); time.Duration(now-last) < c.opts.Interval {
+		return
+	}
+	c.lastUpdate.Store(now)
+	c.update(time.Duration(now - last).Seconds())
+}
+
+func (c *Controller) update(dt float64) {
+	total := c.total.Swap(0)
+	offloaded := c.offloaded.Swap(0)
+	rejected := c.rejected.Swap(0)
+
+	if c.opts.TargetOriginRPS > 0 {
+		c.updateOrigins(dt)
+	} else {
+		c.originsMu.RLock()
+		for _, o := range c.origins {
+			o.rps.Store(math.Float64bits(float64(o.sent.Swap(0)) / dt))
+			o.rejected.Store(0)
+		}
+		c.originsMu.RUnlock()
+	}
+
+	// Без трафика измерять нечего, вероятность остаётся прежней
+	if total == 0 {
+		return
+	}
+
+	measured := float64(offloaded) / float64(total)
+	if c.hasShare {
+		c.share += shareSmoothing * (measured - c.share)
+	} else {
+		c.share, c.hasShare = measured, true
+	}
+	c.measured.Store(math.Float64bits(c.share))
+	if c.opts.TargetOriginRPS > 0 {
+		return
+	}
+
+	target := c.opts.TargetShare
+	errShare := target - c.share
+	p := math.Float64frombits(c.probability.Load())
+
+	// Интеграл не накапливается, пока вероятность упирается в границу
+	// или origin-серверы уже отклоняют запросы по лимиту (anti-windup)
+	saturated := (p >= 1 || rejected > 0) && errShare > 0
+	if !saturated && !(p <= 0 && errShare < 0) {
+		c.integral += errShare * dt
+		limit := 1 / c.opts.Ki
+		c.integral = math.Max(-limit, math.Min(limit, c.integral))
+	}
+
+	p = target + c.opts.Kp*errShare + c.opts.Ki*c.integral
+	p = math.Max(0, math.Min(1, p))
+	c.probability.Store(math.Float64bits(p))
+}
+
+// Пересчёт вероятностей в режиме TargetOriginRPS. Цель сервера переводится в долю
+// спроса на него, и дальше регулятор работает как в режиме TargetShare, но отдельно
+// для каждого сервера. Общая вероятность выборки в Sample — наибольшая из вероятностей серверов.
+func (c *Controller) updateOrigins(dt float64) {
+	target := c.opts.TargetOriginRPS
+	sampling := minSampling
+
+	c.originsMu.RLock()
+	defer c.originsMu.RUnlock()
+	for _, o := range c.origins {
+		sent := o.sent.Swap(0)
+		rejected := o.rejected.Swap(0)
+		o.mu.Lock()
+		demand := o.demand
+		o.demand = 0
+		o.mu.Unlock()
+
+		rps := float64(sent) / dt
+		o.rps.Store(math.Float64bits(rps))
+		p := math.Float64frombits(o.probability.Load())
+
+		// Без запросов к серверу вероятность остаётся прежней
+		if demand == 0 {
+			sampling = math.Max(sampling, p)
+			continue
+		}
+		measured := math.Min(1, float64(sent)/demand)
+		if o.hasShare {
+			o.share += shareSmoothing * (measured - o.share)
+		} else {
+			o.share, o.hasShare = measured, true
+		}
+
+		targetShare := math.Min(1, target*dt/demand)
+		errShare := targetShare - o.share
+		saturated := (p >= 1 || rejected > 0) && errShare > 0
+		if !saturated && !(p <= 0 && errShare < 0) {
+			o.integral += errShare * dt
+			limit := 1 / c.opts.Ki
+			o.integral = math.Max(-limit, math.Min(limit, o.integral))
+		}
+
+		p = targetShare + c.opts.Kp*errShare + c.opts.Ki*o.integral
+		p = math.Max(0, math.Min(1, p))
+		o.probability.Store(math.Float64bits(p))
+		sampling = math.Max(sampling, p)
+	}
+	c.probability.Store(math.Float64bits(sampling))
+}
+
+// Stats возвращает текущее состояние регулятора
+func (c *Controller) Stats() Stats {
+	st := Stats{
+		TargetShare:   c.opts.TargetShare,
+		TargetRPS:     c.opts.TargetOriginRPS,
+		MeasuredShare: math.Float64frombits(c.measured.Load()),
+		Probability:   math.Float64frombits(c.probability.Load()),
+		HotRequests:   c.hot.Load(),
+	}
+
+	c.originsMu.RLock()
+	for server, o := range c.origins {
+		p := st.Probability
+		if c.opts.TargetOriginRPS > 0 {
+			p = math.Float64frombits(o.probability.Load())
+		}
+		st.Origins = append(st.Origins, OriginStats{Server: server, RPS: math.Float64frombits(o.rps.Load()), Probability: p})
+	}
+	c.originsMu.RUnlock()
+	return st
+}
@@ -0,0 +1,183 @@
+package offload
+
+import (
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+const tick = time.Millisecond // Шаг часов симуляции
+
+// Симуляция регулятора на подменённых часах с детерминированной выборкой
+type simulation struct {
+	c   *Controller
+	now time.Time
+	acc map[string]float64 // Накопленные дробные запросы к серверам
+}
+
+func newSimulation(opts Options) *simulation {
+	s := &simulation{now: time.Unix(1_700_000_000, 0), acc: make(map[string]float64)}
+	rng := rand.New(rand.NewPCG(1, 2))
+	s.c = newController(opts, func() time.Time { return s.now }, rng.Float64)
+	return s
+}
+
+// Одна секунда запросов, равномерно распределённых по времени; demand — запросы в секунду
+// к каждому серверу. Возвращает количество запросов, отправленных на каждый сервер.
+func (s *simulation) second(demand map[string]float64) map[string]int {
+	sent := make(map[string]int, len(demand))
+	servers := slices.Sorted(maps.Keys(demand)) // Постоянный порядок для воспроизводимой выборки
+	for i := 0; i < int(time.Second/tick); i++ {
+		s.now = s.now.Add(tick)
+		for _, server := range servers {
+			s.acc[server] += demand[server] * tick.Seconds()
+			for ; s.acc[server] >= 1; s.acc[server]-- {
+				if s.c.Sample(0) && s.c.Admit(server) {
+					sent[server]++
+				}
+			}
+		}
+	}
+	return sent
+}
+
+// Средние запросы в секунду на каждый сервер за последние measure секунд из seconds
+func (s *simulation) run(demand map[string]float64, seconds, measure int) map[string]float64 {
+	avg := make(map[string]float64, len(demand))
+	for i := 0; i < seconds; i++ {
+		sent := s.second(demand)
+		if i >= seconds-measure {
+			for server, n := range sent {
+				avg[server] += float64(n) / float64(measure)
+			}
+		}
+	}
+	return avg
+}
+
+func within(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*want
+}
+
+// PI-регулятор приводит долю запросов на origin к целевой
+func TestTargetShare(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target float64
+		demand float64
+	}{
+		{name: "доля по умолчанию", demand: 1000},
+		{name: "треть запросов", target: 0.3, demand: 1000},
+		{name: "большая доля", target: 0.8, demand: 500},
+		{name: "слабый трафик", target: 0.2, demand: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := newSimulation(Options{TargetShare: tc.target})
+			target := tc.target
+			if target == 0 {
+				target = defaultTargetShare
+			}
+
+			got := sim.run(map[string]float64{"s1": tc.demand}, 60, 20)["s1"] / tc.demand
+			if !within(got, target, 0.1) {
+				t.Errorf("доля запросов на origin %.3f, ожидается %.3f", got, target)
+			}
+			st := sim.c.Stats()
+			if !within(st.MeasuredShare, target, 0.15) {
+				t.Errorf("измеренная доля %.3f, ожидается %.3f", st.MeasuredShare, target)
+			}
+			if st.TargetShare != target || st.TargetRPS != 0 {
+				t.Errorf("цели в статистике %v и %v", st.TargetShare, st.TargetRPS)
+			}
+		})
+	}
+}
+
+// В режиме TargetOriginRPS нагрузка на каждый сервер сходится к цели, а сервер
+// с меньшим спросом получает все свои запросы
+func TestTargetOriginRPS(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target float64
+		demand map[string]float64
+		want   map[string]float64
+	}{
+		{name: "один сервер", target: 50, demand: map[string]float64{"s1": 1000}, want: map[string]float64{"s1": 50}},
+		{
+			name:   "разный спрос",
+			target: 50,
+			demand: map[string]float64{"s1": 1000, "s2": 300, "s3": 20},
+			want:   map[string]float64{"s1": 50, "s2": 50, "s3": 20},
+		},
+		{name: "спрос ниже цели", target: 500, demand: map[string]float64{"s1": 200}, want: map[string]float64{"s1": 200}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := newSimulation(Options{TargetOriginRPS: tc.target})
+			got := sim.run(tc.demand, 60, 20)
+			for server, want := range tc.want {
+				if !within(got[server], want, 0.15) {
+					t.Errorf("%s: %.1f запросов в секунду, ожидается %.1f", server, got[server], want)
+				}
+			}
+			origins := sim.c.Stats().Origins
+			if len(origins) != len(tc.demand) {
+				t.Fatalf("в статистике %d серверов, ожидается %d", len(origins), len(tc.demand))
+			}
+			for _, o := range origins {
+				if o.Probability <= 0 || o.Probability > 1 {
+					t.Errorf("%s: вероятность %v вне (0, 1]", o.Server, o.Probability)
+				}
+			}
+		})
+	}
+}
+
+// Лимит MaxOriginRPS не превышается ни на одном интервале, а отказы по нему
+// не раскручивают интеграл регулятора
+func TestMaxOriginRPS(t *testing.T) {
+	const limit = 100
+	sim := newSimulation(Options{TargetShare: 0.5, MaxOriginRPS: limit})
+	demand := map[string]float64{"s1": 1000, "s2": 100}
+
+	// Bucket вмещает секунду нагрузки: за k секунд не больше limit·(k+1) запросов
+	total := 0
+	for k := 1; k <= 30; k++ {
+		sent := sim.second(demand)
+		total += sent["s1"]
+		if sent["s1"] > 2*limit || total > limit*(k+1) {
+			t.Fatalf("секунда %d: %d запросов, всего %d при лимите %d", k, sent["s1"], total, limit)
+		}
+		if float64(sent["s2"]) > demand["s2"] {
+			t.Fatalf("секунда %d: на s2 отправлено %d запросов из %v", k, sent["s2"], demand["s2"])
+		}
+	}
+	if total < limit*25 {
+		t.Errorf("за 30 секунд на s1 отправлено %d запросов, лимит %d в секунду почти не используется", total, limit)
+	}
+
+	// Спрос упал ниже лимита: доля быстро возвращается к целевой
+	demand = map[string]float64{"s1": 150, "s2": 50}
+	got := sim.run(demand, 20, 10)
+	if share := (got["s1"] + got["s2"]) / 200; !within(share, 0.5, 0.15) {
+		t.Errorf("после снятия ограничения доля %.3f, ожидается 0.5", share)
+	}
+}
+
+// Популярные видео остаются на CDN, но входят в измеряемую долю
+func TestHotThreshold(t *testing.T) {
+	sim := newSimulation(Options{TargetShare: 1, HotThreshold: 10})
+	for i := 0; i < 100; i++ {
+		if sim.c.Sample(10) {
+			t.Fatal("популярное видео отправлено на origin")
+		}
+	}
+	if !sim.c.Sample(9) {
+		t.Error("непопулярное видео не отправлено на origin при доле 1")
+	}
+	if hot := sim.c.Stats().HotRequests; hot != 100 {
+		t.Errorf("HotRequests = %d, ожидается 100", hot)
+	}
+}
@@ -0,0 +1,261 @@
+package popularity
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDepth    = 4               // Количество строк count-min sketch
+	defaultWidth    = 16384           // Количество счётчиков в строке
+	defaultTopK     = 100             // Количество отслеживаемых популярных видео
+	defaultHalfLife = 1 * time.Minute // Период полураспада счётчиков
+
+	// DecayInterval — период затухания счётчиков трекера по умолчанию для Decay
+	DecayInterval = 5 * time.Second
+)
+
+// Options задаёт параметры трекера. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Depth    int           // Количество строк sketch (больше — точнее оценка)
+	Width    int           // Количество счётчиков в строке (больше — меньше коллизий)
+	TopK     int           // Размер списка самых популярных видео
+	HalfLife time.Duration // За это время вклад старых запросов уменьшается вдвое
+}
+
+// Entry — видео и оценка количества запросов к нему с учётом затухания
+type Entry struct {
+	Video string
+	Count uint64
+}
+
+// Tracker оценивает популярность видео в ограниченной памяти: count-min sketch
+// с экспоненциальным затуханием и список top-K самых запрашиваемых видео.
+type Tracker struct {
+	seed     maphash.Seed
+	depth    int
+	width    uint64
+	counters []atomic.Uint64 // depth*width счётчиков
+	halfLife time.Duration
+
+	lastDecay atomic.Int64 // Время последнего затухания в наносекундах Unix
+	decaying  atomic.Bool  // Затухание уже выполняется
+
+	topK     int
+	topMu    sync.RWMutex
+	top      map[string]*atomic.Uint64
+	minCount atomic.Uint64 // Минимальная оценка в списке top-K
+}
+
+var defaultTracker = New(Options{}) // Трекер по умолчанию, используемый функциями пакета
+
+// New создаёт трекер популярности
+func New(opts Options) *Tracker {
+	if opts.Depth <= 0 {
+		opts.Depth = defaultDepth
+	}
+	if opts.Width <= 0 {
+		opts.Width = defaultWidth
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = defaultTopK
+	}
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = defaultHalfLife
+	}
+
+	t := &Tracker{
+		seed:     maphash.MakeSeed(),
+		depth:    opts.Depth,
+		width:    uint64(opts.Width),
+		counters: make([]atomic.Uint64, opts.Depth*opts.Width),
+		halfLife: opts.HalfLife,
+		topK:     opts.TopK,
+		top:      make(map[string]*atomic.Uint64, opts.TopK+1),
+	}
+	t.lastDecay.Store(time.Now().UnixNano())
+	return t
+}
+
+// Индексы счётчиков для видео по схеме двойного хеширования
+func (t *Tracker) hashes(video string) (uint64, uint64) {
+	h := maphash.String(t.seed, video)
+	return h, (h >> 32) | 1
+}
+
+// Observe учитывает запрос к видео и возвращает оценку количества запросов к нему
+func (t *Tracker) Observe(video string) uint64 {
+	h1, h2 := t.hashes(video)
+	estimate := uint64(math.MaxUint64)
+	for i := 0; i < t.depth; i++ {
+		idx := uint64(i)*t.width + (h1+uint64(i)*h2)%t.width
+		if v := t.counters[idx].Add(1); v < estimate {
+			estimate = v
+		}
+	}
+
+	t.updateTop(video, estimate)
+	return estimate
+}
+
+// Estimate возвращает оценку количества запросов к видео без его учёта
+func (t *Tracker) Estimate(video string) uint64 {
+	h1, h2 := t.hashes(video)
+	estimate := uint64(math.MaxUint64)
+	for i := 0; i < t.depth; i++ {
+		idx := uint64(i)*t.width + (h1+uint64(i)*h2)%t.width
+		if v := t.counters[idx].Load(); v < estimate {
+			estimate = v
+		}
+	}
+	return estimate
+}
+
+// Обновление списка top-K. Блокировка на запись берётся только при изменении состава списка.
+func (t *Tracker) updateTop(video string, estimate uint64) {
+	t.topMu.RLock()
+	count, ok := t.top[video]
+	full := len(t.top) >= t.topK
+	t.topMu.RUnlock()
+
+	if ok {
+		count.Store(estimate)
+		return
+	}
+	if full && estimate <= t.minCount.Load() {
+		return
+	}
+
+	t.topMu.Lock()
+	defer t.topMu.Unlock()
+
+	if count, ok := t.top[video]; ok {
+		count.Store(estimate)
+		return
+	}
+	count = new(atomic.Uint64)
+	count.Store(estimate)
+	t.top[video] = count
+
+	if len(t.top) > t.topK {
+		minVideo, _ := t.minLocked()
+		delete(t.top, minVideo)
+	}
+	_, minCount := t.minLocked()
+	t.minCount.Store(minCount)
+}
+
+// Поиск видео с минимальной оценкой в списке top-K. Вызывается под topMu.
+func (t *Tracker) minLocked() (string, uint64) {
+	var (
+		minVideo string
+		minCount uint64 = math.MaxUint64
+	)
+	for video, count := range t.top {
+		if c := count.Load(); c < minCount {
+			minVideo, minCount = video, c
+		}
+	}
+	if len(t.top) == 0 {
+		minCount = 0
+	}
+	return minVideo, minCount
+}
+
+// Top возвращает до n самых популярных видео по убыванию оценки
+func (t *Tracker) Top(n int) []Entry {
+	t.topMu.RLock()
+	entries := make([]Entry, 0, len(t.top))
+	for video, count := range t.top {
+		if c := count.Load(); c > 0 {
+			entries = append(entries, Entry{Video: video, Count: c})
+		}
+	}
+	t.topMu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Video < entries[j].Video
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Decay уменьшает счётчики пропорционально времени, прошедшему с прошлого затухания.
+// Обходит все depth*width счётчиков (64K по умолчанию), поэтому вызывается
+// в фоне по расписанию, а не на пути запроса. Параллельный вызов пропускается.
+func (t *Tracker) Decay() {
+	t.decay(time.Now())
+}
+
+func (t *Tracker) decay(at time.Time) {
+	if !t.decaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer t.decaying.Store(false)
+
+	now, last := at.UnixNano(), t.lastDecay.Load()
+	if now <= last {
+		return
+	}
+	factor := math.Exp2(-float64(now-last) / float64(t.halfLife))
+	t.lastDecay.Store(now)
+
+	for i := range t.counters {
+		decayCounter(&t.counters[i], factor)
+	}
+
+	t.topMu.Lock()
+	for video, count := range t.top {
+		if decayCounter(count, factor) == 0 {
+			delete(t.top, video)
+		}
+	}
+	_, minCount := t.minLocked()
+	t.minCount.Store(minCount)
+	t.topMu.Unlock()
+}
+
+// Умножение счётчика на коэффициент затухания без потери параллельных инкрементов
+func decayCounter(counter *atomic.Uint64, factor float64) uint64 {
+	for {
+		old := counter.Load()
+		if old == 0 {
+			return 0
+		}
+		decayed := uint64(float64(old) * factor)
+		if counter.CompareAndSwap(old, decayed) {
+			return decayed
+		}
+	}
+}
+
+// Decay применяет затухание к трекеру по умолчанию. Вызывается планировщиком раз в DecayInterval.
+func Decay(context.Context) error {
+	defaultTracker.Decay()
+	return nil
+}
+
+// Учёт запроса к видео в трекере по умолчанию
+func Observe(video string) uint64 {
+	return defaultTracker.Observe(video)
+}
+
+// Оценка популярности видео в трекере по умолчанию
+func Estimate(video string) uint64 {
+	return defaultTracker.Estimate(video)
+}
+
+// Самые популярные видео в трекере по умолчанию
+func Top(n int) []Entry {
+	return defaultTracker.Top(n)
+}
@@ -0,0 +1,230 @@
+package popularity
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func observeN(t *Tracker, video string, n int) {
+	for i := 0; i < n; i++ {
+		t.Observe(video)
+	}
+}
+
+// Оценка count-min не меньше истинного количества и превышает его не больше чем на
+// e/Width·N, кроме доли видео порядка e^-Depth
+func TestEstimate(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		opts         Options
+		videos       int
+		exact        bool // Коллизий нет, оценка должна совпасть с количеством
+		maxOverShare float64
+	}{
+		{name: "без коллизий", opts: Options{}, videos: 50, exact: true},
+		{name: "узкий sketch", opts: Options{Depth: 4, Width: 256}, videos: 2000, maxOverShare: 0.1},
+		{name: "одна строка", opts: Options{Depth: 1, Width: 512}, videos: 2000, maxOverShare: 0.5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := New(tc.opts)
+			counts := make(map[string]uint64, tc.videos)
+			var total uint64
+			for i := 0; i < tc.videos; i++ {
+				video := "http://s1.origin-cluster/video/" + strconv.Itoa(i) + ".m3u8"
+				n := i%7 + 1
+				observeN(tr, video, n)
+				counts[video] = uint64(n)
+				total += uint64(n)
+			}
+
+			width := tc.opts.Width
+			if width == 0 {
+				width = defaultWidth
+			}
+			bound := uint64(math.Ceil(math.E / float64(width) * float64(total)))
+			over := 0
+			for video, want := range counts {
+				got := tr.Estimate(video)
+				if got < want {
+					t.Fatalf("Estimate(%q) = %d меньше истинного %d", video, got, want)
+				}
+				if tc.exact && got != want {
+					t.Fatalf("Estimate(%q) = %d, ожидается %d", video, got, want)
+				}
+				if got-want > bound {
+					over++
+				}
+			}
+			if share := float64(over) / float64(tc.videos); share > tc.maxOverShare {
+				t.Errorf("оценка превышает границу %d у %.1f%% видео, допустимо %.1f%%", bound, share*100, tc.maxOverShare*100)
+			}
+			if got := tr.Estimate("http://s1.origin-cluster/video/unknown.m3u8"); tc.exact && got != 0 {
+				t.Errorf("оценка незапрошенного видео %d", got)
+			}
+		})
+	}
+}
+
+// Observe возвращает оценку с учётом текущего запроса
+func TestObserveReturnsEstimate(t *testing.T) {
+	tr := New(Options{})
+	for i := uint64(1); i <= 5; i++ {
+		if got := tr.Observe("a"); got != i {
+			t.Fatalf("Observe() = %d, ожидается %d", got, i)
+		}
+	}
+	if got := tr.Estimate("a"); got != 5 {
+		t.Fatalf("Estimate() = %d, ожидается 5", got)
+	}
+}
+
+// За период полураспада счётчики и список top-K уменьшаются вдвое
+func TestDecay(t *testing.T) {
+	const halfLife = time.Minute
+	for _, tc := range []struct {
+		name    string
+		elapsed []time.Duration // Интервалы между последовательными затуханиями
+		want    uint64
+	}{
+		{name: "без времени", elapsed: []time.Duration{0}, want: 1000},
+		{name: "полураспад", elapsed: []time.Duration{halfLife}, want: 500},
+		{name: "два полураспада", elapsed: []time.Duration{2 * halfLife}, want: 250},
+		{name: "по частям", elapsed: []time.Duration{halfLife / 2, halfLife / 2, halfLife}, want: 250},
+		{name: "обнуление", elapsed: []time.Duration{20 * halfLife}, want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := New(Options{HalfLife: halfLife})
+			observeN(tr, "a", 1000)
+
+			now := time.Unix(0, tr.lastDecay.Load())
+			for _, d := range tc.elapsed {
+				now = now.Add(d)
+				tr.decay(now)
+			}
+			// Округление вниз при каждом затухании допускает расхождение на единицу за шаг
+			if got := tr.Estimate("a"); got > tc.want || got+uint64(len(tc.elapsed)) < tc.want {
+				t.Errorf("Estimate() = %d, ожидается %d", got, tc.want)
+			}
+			top := tr.Top(0)
+			if tc.want == 0 {
+				if len(top) != 0 {
+					t.Errorf("обнулённое видео осталось в top-K: %v", top)
+				}
+				return
+			}
+			if len(top) != 1 || top[0].Count != tr.Estimate("a") {
+				t.Errorf("Top() = %v, ожидается оценка %d", top, tr.Estimate("a"))
+			}
+		})
+	}
+
+	// Время раньше прошлого затухания не меняет счётчики
+	tr := New(Options{HalfLife: halfLife})
+	observeN(tr, "a", 10)
+	tr.decay(time.Unix(0, tr.lastDecay.Load()).Add(-time.Hour))
+	if got := tr.Estimate("a"); got != 10 {
+		t.Errorf("после затухания в прошлом Estimate() = %d, ожидается 10", got)
+	}
+}
+
+// Список top-K упорядочен по убыванию оценки, а новое видео вытесняет наименее популярное
+func TestTop(t *testing.T) {
+	tr := New(Options{TopK: 3})
+	for _, o := range []struct {
+		video string
+		n     int
+	}{{"a", 5}, {"b", 4}, {"c", 3}, {"d", 1}} {
+		observeN(tr, o.video, o.n)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		observe string // Видео, запрашиваемое перед проверкой
+		times   int
+		n       int
+		want    []Entry
+	}{
+		{name: "менее популярное не входит", want: []Entry{{"a", 5}, {"b", 4}, {"c", 3}}},
+		{name: "ограничение n", n: 2, want: []Entry{{"a", 5}, {"b", 4}}},
+		{name: "равная минимуму оценка не вытесняет", observe: "d", times: 2, want: []Entry{{"a", 5}, {"b", 4}, {"c", 3}}},
+		{name: "вытеснение наименее популярного", observe: "e", times: 6, want: []Entry{{"e", 6}, {"a", 5}, {"b", 4}}},
+		{name: "рост оценки меняет порядок", observe: "b", times: 3, want: []Entry{{"b", 7}, {"e", 6}, {"a", 5}}},
+		{name: "равные оценки по имени", observe: "a", times: 1, want: []Entry{{"b", 7}, {"a", 6}, {"e", 6}}},
+	} {
+		observeN(tr, tc.observe, tc.times)
+		if got := tr.Top(tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: Top(%d) = %v, ожидается %v", tc.name, tc.n, got, tc.want)
+		}
+	}
+}
+
+// Одновременные запросы, чтение и затухание. Проверяется под go test -race.
+func TestConcurrentObserve(t *testing.T) {
+	tr := New(Options{TopK: 8})
+	const (
+		workers    = 8
+		iterations = 2000
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				tr.Observe("hot")
+				tr.Observe("video-" + strconv.Itoa((w*iterations+i)%64))
+				if i%100 == 0 {
+					tr.Top(4)
+					tr.Estimate("hot")
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Без затухания параллельные инкременты не теряются
+	if got := tr.Estimate("hot"); got != workers*iterations {
+		t.Fatalf("Estimate(hot) = %d, ожидается %d", got, workers*iterations)
+	}
+	if top := tr.Top(1); len(top) != 1 || top[0].Video != "hot" || top[0].Count != workers*iterations {
+		t.Fatalf("Top(1) = %v", top)
+	}
+
+	stop := make(chan struct{})
+	var decays sync.WaitGroup
+	decays.Add(1)
+	go func() {
+		defer decays.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				tr.Decay()
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				tr.Observe("hot")
+				tr.Top(4)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	decays.Wait()
+
+	// Затухание уменьшает оценку, но не делает её больше числа запросов
+	if got := tr.Estimate("hot"); got > 2*workers*iterations {
+		t.Fatalf("Estimate(hot) = %d после затухания, больше числа запросов", got)
+	}
+}
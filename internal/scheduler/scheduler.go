@@ -0,0 +1,286 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"videobalance/internal/logs"
+	"videobalance/internal/worker"
+)
+
+var logger = logs.For("scheduler") // Логгер пакета с отдельно настраиваемым уровнем
+
+var (
+	// ErrDuplicate возвращается Register для уже зарегистрированного имени
+	ErrDuplicate = errors.New("задача с таким именем уже зарегистрирована")
+
+	// ErrStopped возвращается Register после остановки планировщика
+	ErrStopped = errors.New("планировщик остановлен")
+)
+
+// Job — периодическая задача. Запуски одной задачи не пересекаются:
+// следующий отсчитывается от завершения предыдущего. Задача выполняется
+// в исполнителе worker, а планировщик только ждёт её завершения.
+type Job struct {
+	Name     string
+	Interval time.Duration                   // Пауза между запусками
+	Jitter   time.Duration                   // Случайная добавка к паузе (0..Jitter), чтобы задачи не совпадали
+	Timeout  time.Duration                   // Максимальное время запуска, включая ожидание в очереди исполнителя (0 — без ограничения)
+	Inline   bool                            // Выполнять в горутине планировщика: для задач, управляющих самим исполнителем
+	Run      func(ctx context.Context) error // Контекст отменяется по таймауту или при принудительной остановке
+}
+
+// Status — состояние задачи и результат последнего запуска
+type Status struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"-"`
+	Running      bool          `json:"running"`
+	Runs         uint64        `json:"runs"`
+	Failures     uint64        `json:"failures"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"-"`                    // 0 до первого запуска
+	LastError    string        `json:"last_error,omitempty"` // Пусто, если последний запуск успешен
+	NextRun      time.Time     `json:"next_run"`
+}
+
+// MarshalJSON записывает длительности строками вида 1m30s
+func (s Status) MarshalJSON() ([]byte, error) {
+	type status Status
+	var last string
+	if s.LastDuration > 0 {
+		last = s.LastDuration.String()
+	}
+	return json.Marshal(struct {
+		status
+		Interval     string `json:"interval"`
+		LastDuration string `json:"last_duration,omitempty"`
+	}{status(s), s.Interval.String(), last})
+}
+
+// Scheduler запускает зарегистрированные периодические задачи между Start и Stop
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*entry
+	started bool
+	stopped bool
+
+	stop chan struct{} // Закрывается в Stop: новые запуски не начинаются
+	wg   sync.WaitGroup
+
+	// Отменяется, если выполняющиеся задачи нужно прервать при остановке
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type entry struct {
+	job Job
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New создаёт планировщик; задачи запускаются после Start
+func New() *Scheduler {
+	s := &Scheduler{
+		jobs: make(map[string]*entry),
+		stop: make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Register добавляет задачу. Задача, добавленная после Start, запускается сразу по расписанию.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil || job.Interval <= 0 {
+		return fmt.Errorf("задача %q: нужны имя, функция и положительный интервал", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrStopped
+	}
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, job.Name)
+	}
+	e := &entry{job: job, status: Status{Name: job.Name, Interval: job.Interval}}
+	s.jobs[job.Name] = e
+	if s.started {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+	return nil
+}
+
+// Start запускает все зарегистрированные задачи; повторные вызовы ничего не делают
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	for _, e := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+	logger.Info("Планировщик задач запущен", "jobs", len(s.jobs))
+}
+
+// Stop прекращает запуск задач и ждёт завершения выполняющихся.
+// Если ctx истекает раньше, их контексты отменяются.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Status возвращает состояние задач, упорядоченное по имени
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		e.mu.Lock()
+		statuses = append(statuses, e.status)
+		e.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// HTTPHandler отдаёт состояние задач в JSON
+func (s *Scheduler) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Status())
+	})
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(s.schedule(e))
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-timer.C:
+		}
+		s.run(e)
+		timer.Reset(s.schedule(e))
+	}
+}
+
+// Пауза до следующего запуска с учётом разброса
+func (s *Scheduler) schedule(e *entry) time.Duration {
+	delay := e.job.Interval
+	if e.job.Jitter > 0 {
+		delay += rand.N(e.job.Jitter)
+	}
+	e.mu.Lock()
+	e.status.NextRun = time.Now().Add(delay)
+	e.mu.Unlock()
+	return delay
+}
+
+func (s *Scheduler) run(e *entry) {
+	ctx := s.ctx
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	e.mu.Lock()
+	e.status.Running = true
+	e.status.LastRun = start
+	e.status.NextRun = time.Time{}
+	e.mu.Unlock()
+
+	err := execute(ctx, e.job)
+	if err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("превышен таймаут %v", e.job.Timeout)
+	}
+	duration := time.Since(start)
+
+	e.mu.Lock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastDuration = duration
+	e.status.LastError = ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		logger.Warn("Ошибка выполнения периодической задачи", "job", e.job.Name, "duration", duration, "error", err)
+	} else {
+		logger.Debug("Периодическая задача выполнена", "job", e.job.Name, "duration", duration)
+	}
+}
+
+// Выполнение задачи в исполнителе worker или, для Inline, в текущей горутине
+func execute(ctx context.Context, job Job) error {
+	if job.Inline {
+		return call(ctx, job)
+	}
+	future, err := worker.Submit(ctx, func(ctx context.Context) error { return call(ctx, job) })
+	if err != nil {
+		return fmt.Errorf("задача не поставлена в исполнитель: %w", err)
+	}
+	<-future.Done()
+	return future.Err()
+}
+
+// Выполнение задачи с перехватом паники
+func call(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("паника: %v", r)
+			logger.Error("Паника в периодической задаче", "job", job.Name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"videobalance/internal/worker"
+)
+
+// Запуски задач выполняются в исполнителе worker, а задачи Inline — в горутине планировщика
+func TestJobsRunOnExecutor(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		inline     bool
+		onExecutor bool
+	}{
+		{name: "исполнитель", onExecutor: true},
+		{name: "inline", inline: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := worker.ExecutorStats().Completed
+			ran := make(chan struct{}, 1)
+			s := New()
+			if err := s.Register(Job{Name: "job", Interval: time.Millisecond, Inline: tc.inline, Run: func(context.Context) error {
+				select {
+				case ran <- struct{}{}:
+				default:
+				}
+				return nil
+			}}); err != nil {
+				t.Fatal(err)
+			}
+			s.Start()
+			select {
+			case <-ran:
+			case <-time.After(5 * time.Second):
+				t.Fatal("задача не запущена")
+			}
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			st := s.Status()
+			if len(st) != 1 || st[0].Runs == 0 || st[0].LastError != "" {
+				t.Fatalf("состояние задачи: %+v", st)
+			}
+			completed := worker.ExecutorStats().Completed - before
+			if onExecutor := completed > 0; onExecutor != tc.onExecutor {
+				t.Fatalf("в исполнителе выполнено %d задач, ожидалось выполнение в исполнителе: %v", completed, tc.onExecutor)
+			}
+			if tc.onExecutor && completed != st[0].Runs {
+				t.Fatalf("в исполнителе выполнено %d задач, запусков %d", completed, st[0].Runs)
+			}
+		})
+	}
+}
+
+// Таймаут задачи ограничивает и ожидание в очереди исполнителя, и выполнение
+func TestJobTimeout(t *testing.T) {
+	s := New()
+	if err := s.Register(Job{Name: "slow", Interval: time.Millisecond, Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if st := s.Status(); st[0].Failures > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("таймаут задачи не учтён")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// В JSON длительности записываются строками, а до первого запуска last_duration нет
+func TestStatusJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status Status
+		want   map[string]any
+	}{
+		{
+			name:   "до запуска",
+			status: Status{Name: "job", Interval: 30 * time.Second},
+			want:   map[string]any{"name": "job", "interval": "30s"},
+		},
+		{
+			name:   "после запуска",
+			status: Status{Name: "job", Interval: time.Minute, Runs: 1, LastDuration: 1500 * time.Millisecond},
+			want:   map[string]any{"name": "job", "interval": "1m0s", "runs": 1.0, "last_duration": "1.5s"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tc.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, ожидается %v", key, got[key], want)
+				}
+			}
+			if _, ok := got["last_duration"]; ok != (tc.status.LastDuration > 0) {
+				t.Errorf("last_duration в %s", data)
+			}
+		})
+	}
+}
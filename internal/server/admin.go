@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"videobalance/internal/logs"
+	"videobalance/internal/popularity"
+	"videobalance/internal/util"
+	"videobalance/internal/worker"
+	pb "videobalance/proto"
+)
+
+const (
+	prewarmConcurrency      = 16                     // Количество URL одного прогрева, ожидающих исполнителя или выполняемых
+	prewarmProgressInterval = 500 * time.Millisecond // Интервал отправки промежуточного прогресса
+)
+
+// AdminServer реализует служебные RPC балансировщика
+type AdminServer struct {
+	pb.UnimplementedAdminServer
+	balancer *BalancerServer
+	logger   *slog.Logger
+}
+
+// Конструктор служебного сервера
+func NewAdminServer(balancer *BalancerServer) *AdminServer {
+	return &AdminServer{
+		balancer: balancer,
+		logger:   logs.For("server"),
+	}
+}
+
+// Прогрев одного URL, поставленный в очередь исполнителя. Если поставить не удалось, err
+// содержит причину, и URL считается непрогретым.
+type prewarmTask struct {
+	video  string
+	future *worker.Future
+	err    error
+}
+
+// Prewarm вычисляет маршруты для списка URL и загружает их в кэш заранее
+func (a *AdminServer) Prewarm(req *pb.PrewarmRequest, stream pb.Admin_PrewarmServer) error {
+	videos := append([]string(nil), req.Videos...)
+	if len(req.List) > 0 {
+		list, err := util.ReadVideoList(bytes.NewReader(req.List))
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "не удалось прочитать список URL: %v", err)
+		}
+		videos = append(videos, list...)
+	}
+	if len(videos) == 0 {
+		return status.Error(codes.InvalidArgument, "список URL для прогрева пуст")
+	}
+
+	ctx := stream.Context()
+	a.logger.InfoContext(ctx, "Начат прогрев кэша", "количество", len(videos))
+
+	// URL прогреваются на исполнителе фоновых задач, а отправкой в поток занимается только
+	// текущая горутина. Результаты учитываются в порядке постановки в очередь.
+	tasks := make(chan prewarmTask, prewarmConcurrency)
+	go func() {
+		defer close(tasks)
+		for _, video := range videos {
+			future, err := worker.Submit(ctx, func(context.Context) error { return a.balancer.warm(video) })
+			select {
+			case tasks <- prewarmTask{video: video, future: future, err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(prewarmProgressInterval)
+	defer ticker.Stop()
+
+	progress := &pb.PrewarmProgress{Total: int64(len(videos))}
+	record := func(video string, err error) {
+		progress.Processed++
+		if err != nil {
+			progress.Failed++
+			progress.Failures = append(progress.Failures, &pb.PrewarmFailure{Video: video, Error: err.Error()})
+		} else {
+			progress.Warmed++
+		}
+	}
+
+	// Пока ждём завершения текущей задачи, следующие не читаются
+	var (
+		next    = tasks
+		current prewarmTask
+		done    <-chan struct{}
+	)
+	for {
+		select {
+		case task, ok := <-next:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return status.FromContextError(err).Err()
+				}
+				progress.Done = true
+				a.logger.InfoContext(ctx, "Прогрев кэша завершён",
+					"количество", progress.Total, "успешно", progress.Warmed, "ошибок", progress.Failed)
+				return stream.Send(progress)
+			}
+			if task.err != nil {
+				record(task.video, task.err)
+				continue
+			}
+			current, next, done = task, nil, task.future.Done()
+		case <-done:
+			record(current.video, current.future.Err())
+			next, done = tasks, nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+			if err := stream.Send(progress); err != nil {
+				return err
+			}
+			// Ошибки передаются только один раз, в ближайшем сообщении после их появления
+			progress.Failures = nil
+		}
+	}
+}
+
+// TopVideos возвращает самые популярные видео по оценке трекера популярности
+func (a *AdminServer) TopVideos(_ context.Context, req *pb.TopVideosRequest) (*pb.TopVideosResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit не может быть отрицательным")
+	}
+
+	top := popularity.Top(int(req.Limit))
+	resp := &pb.TopVideosResponse{Videos: make([]*pb.VideoPopularity, 0, len(top))}
+	for _, entry := range top {
+		resp.Videos = append(resp.Videos, &pb.VideoPopularity{Video: entry.Video, Count: entry.Count})
+	}
+	return resp, nil
+}
+
+// GetLogLevels возвращает текущие уровни логирования
+func (a *AdminServer) GetLogLevels(context.Context, *pb.GetLogLevelsRequest) (*pb.LogLevels, error) {
+	return logLevels(), nil
+}
+
+// SetLogLevel меняет уровень логирования во время работы, как PUT /debug/loglevel.
+// Метод службы Admin, поэтому при заданных токенах требует токен администратора.
+func (a *AdminServer) SetLogLevel(ctx context.Context, req *pb.SetLogLevelRequest) (*pb.LogLevels, error) {
+	if req.Level == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан уровень")
+	}
+	var revertAfter time.Duration
+	if req.Revert != nil {
+		if err := req.Revert.CheckValid(); err != nil || req.Revert.AsDuration() < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "некорректная длительность revert %v", req.Revert)
+		}
+		revertAfter = req.Revert.AsDuration()
+	}
+	if err := logs.ChangeLevel(req.Package, req.Level, revertAfter); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return logLevels(), nil
+}
+
+func logLevels() *pb.LogLevels {
+	state := logs.Levels()
+	resp := &pb.LogLevels{Global: state.Global, Packages: state.Packages}
+	for pkg, at := range state.Reverts {
+		if resp.Reverts == nil {
+			resp.Reverts = make(map[string]*timestamppb.Timestamp, len(state.Reverts))
+		}
+		resp.Reverts[pkg] = timestamppb.New(at)
+	}
+	return resp
+}
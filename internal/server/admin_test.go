@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"videobalance/internal/cache"
+	pb "videobalance/proto"
+)
+
+// Поток прогрева без соединения: сообщения сохраняются
+type prewarmStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*pb.PrewarmProgress
+}
+
+func (s *prewarmStream) Context() context.Context { return s.ctx }
+
+func (s *prewarmStream) Send(p *pb.PrewarmProgress) error {
+	s.sent = append(s.sent, &pb.PrewarmProgress{
+		Total: p.Total, Processed: p.Processed, Warmed: p.Warmed, Failed: p.Failed,
+		Failures: p.Failures, Done: p.Done,
+	})
+	return nil
+}
+
+// Все URL прогреваются на исполнителе, ошибки попадают в прогресс
+func TestPrewarm(t *testing.T) {
+	cache.Purge()
+	admin := NewAdminServer(NewBalancerServer("balancer.example.com", "cdn.example.com", Options{}))
+
+	var videos []string
+	for i := 0; i < 3*prewarmConcurrency; i++ {
+		videos = append(videos, "http://s1.origin-cluster/video/prewarm-"+strconv.Itoa(i)+"/index.m3u8")
+	}
+	const invalid = "http://example.com/video/1.m3u8"
+	stream := &prewarmStream{ctx: context.Background()}
+	if err := admin.Prewarm(&pb.PrewarmRequest{Videos: append(videos, invalid)}, stream); err != nil {
+		t.Fatal(err)
+	}
+
+	last := stream.sent[len(stream.sent)-1]
+	if !last.Done || last.Total != int64(len(videos)+1) || last.Processed != last.Total ||
+		last.Warmed != int64(len(videos)) || last.Failed != 1 {
+		t.Fatalf("итоговый прогресс %+v", last)
+	}
+	var failures []string
+	for _, p := range stream.sent {
+		for _, f := range p.Failures {
+			failures = append(failures, f.Video)
+		}
+	}
+	if len(failures) != 1 || failures[0] != invalid {
+		t.Errorf("ошибки прогрева для %v, ожидается %s", failures, invalid)
+	}
+	for _, video := range videos {
+		if _, ok := cache.GetFromCache(video); !ok {
+			t.Fatalf("%s не попал в кэш", video)
+		}
+	}
+}
+
+// Отмена клиентом прерывает прогрев
+func TestPrewarmCanceled(t *testing.T) {
+	admin := NewAdminServer(NewBalancerServer("balancer.example.com", "cdn.example.com", Options{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := admin.Prewarm(&pb.PrewarmRequest{Videos: []string{"http://s1.origin-cluster/video/1.m3u8"}}, &prewarmStream{ctx: ctx})
+	if code := status.Code(err); code != codes.Canceled {
+		t.Fatalf("код %v, ожидается %v (%v)", code, codes.Canceled, err)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"videobalance/internal/metrics"
+)
+
+const (
+	healthCheckTimeout  = 5 * time.Second // Таймаут одной проверки
+	healthCheckParallel = 16              // Одновременные проверки
+)
+
+// Результат последней проверки доступности CDN или origin-сервера
+type backendStatus struct {
+	Backend string // cdn или origin
+	Server  string
+	Up      bool
+	Checked time.Time
+	Error   string // Причина недоступности
+}
+
+// Состояние проверок доступности направлений
+type backendHealth struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	servers map[string]backendStatus // По Backend и Server
+}
+
+func newBackendHealth() *backendHealth {
+	return &backendHealth{
+		client: &http.Client{
+			Timeout: healthCheckTimeout,
+			// Перенаправление — ответ сервера, по нему не переходим
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		servers: make(map[string]backendStatus),
+	}
+}
+
+// Проверка текущего CDN и origin-серверов, известных регулятору разгрузки.
+// Сервер считается доступным, если ответил на HEAD / без ошибки 5xx. Результат
+// записывается в метрику backend_up; маршрутизация его не учитывает.
+func (s *BalancerServer) checkBackends(ctx context.Context) error {
+	rt := s.routing.Load()
+	var targets []backendStatus
+	if rt.cdnHost != "" {
+		targets = append(targets, backendStatus{Backend: backendCDN, Server: rt.cdnHost})
+	}
+	for _, o := range rt.offload.Stats().Origins {
+		targets = append(targets, backendStatus{Backend: backendOrigin, Server: o.Server})
+	}
+
+	checked := make(map[string]backendStatus, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthCheckParallel)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := s.health.probe(ctx, target.Server)
+			if ctx.Err() != nil {
+				// Не успели проверить до таймаута задачи: прежний результат остаётся
+				return
+			}
+			target.Up, target.Checked = err == nil, time.Now()
+			if err != nil {
+				target.Error = err.Error()
+			}
+			mu.Lock()
+			checked[target.Backend+" "+target.Server] = target
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+	servers := make(map[string]backendStatus, len(targets))
+	for _, target := range targets {
+		key := target.Backend + " " + target.Server
+		h, ok := checked[key]
+		prev, seen := s.health.servers[key]
+		if !ok {
+			if seen {
+				servers[key] = prev
+			}
+			continue
+		}
+		servers[key] = h
+		metrics.BackendUp.WithLabelValues(h.Backend, h.Server).Set(boolGauge(h.Up))
+		switch {
+		case !h.Up && (!seen || prev.Up):
+			s.logger.Warn("Сервер недоступен", "backend", h.Backend, "server", h.Server, "error", h.Error)
+		case h.Up && seen && !prev.Up:
+			s.logger.Info("Сервер снова доступен", "backend", h.Backend, "server", h.Server)
+		}
+	}
+	// Серверы, которые больше не проверяются, пропадают из метрики
+	for key, prev := range s.health.servers {
+		if _, ok := servers[key]; !ok {
+			metrics.BackendUp.DeleteLabelValues(prev.Backend, prev.Server)
+		}
+	}
+	s.health.servers = servers
+	return ctx.Err()
+}
+
+func boolGauge(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (h *backendHealth) probe(ctx context.Context, server string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "http://"+server+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// Метод и URL запроса в ошибке не нужны: сервер указан отдельно
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("ответ %s", resp.Status)
+	}
+	return nil
+}
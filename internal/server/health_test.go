@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"videobalance/internal/metrics"
+	"videobalance/internal/offload"
+)
+
+// Значения backend_up по backend и server из реестра метрик
+func backendUp(t *testing.T) map[string]float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != "videobalance_backend_up" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			values[labels["backend"]+" "+labels["server"]] = m.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func newBackend(t *testing.T, status int) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/" {
+			t.Errorf("проверка %s %s, ожидается HEAD /", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// CDN и origin-серверы, на которые отправлялись запросы, проверяются и попадают в backend_up
+func TestCheckBackends(t *testing.T) {
+	cdn := newBackend(t, http.StatusNotFound)
+	origin := newBackend(t, http.StatusMovedPermanently)
+	failing := newBackend(t, http.StatusServiceUnavailable)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	unreachable := strings.TrimPrefix(closed.URL, "http://")
+	closed.Close()
+	// Метрика общая для пакета: серверы прежних запусков теста не учитываются
+	metrics.BackendUp.Reset()
+
+	s := NewBalancerServer("balancer.example.com", cdn, Options{Offload: offload.Options{TargetShare: 1}})
+	for _, server := range []string{origin, failing, unreachable} {
+		s.routing.Load().offload.Admit(server)
+	}
+	if err := s.checkBackends(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		backendCDN + " " + cdn:            1,
+		backendOrigin + " " + origin:      1,
+		backendOrigin + " " + failing:     0,
+		backendOrigin + " " + unreachable: 0,
+	}
+	if got := backendUp(t); !maps.Equal(got, want) {
+		t.Errorf("backend_up = %v, ожидается %v", got, want)
+	}
+	if h := s.health.servers[backendOrigin+" "+failing]; !strings.Contains(h.Error, "503") {
+		t.Errorf("ошибка проверки %q, ожидается ответ 503", h.Error)
+	}
+
+	// После смены CDN прежний пропадает из метрики
+	next := newBackend(t, http.StatusOK)
+	s.Reload("balancer.example.com", next, Options{Offload: offload.Options{TargetShare: 1}})
+	if err := s.checkBackends(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, backendCDN+" "+cdn)
+	want[backendCDN+" "+next] = 1
+	if got := backendUp(t); !maps.Equal(got, want) {
+		t.Errorf("backend_up после смены CDN = %v, ожидается %v", got, want)
+	}
+}
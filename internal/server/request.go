@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"videobalance/internal/interceptor"
+	"videobalance/internal/limiter"
+)
+
+const (
+	tenantHeader        = "x-tenant"               // Заголовок с идентификатором арендатора
+	priorityHeader      = "x-priority"             // Заголовок с приоритетом запроса (выставляется шлюзом)
+	retryPushbackHeader = "grpc-retry-pushback-ms" // Трейлер с рекомендуемой задержкой повтора
+)
+
+// requestPriority определяет приоритет запроса. Арендатор берётся из токена вызова.
+// Метаданным x-tenant и x-priority клиент управлять не может: они учитываются только от
+// доверенного шлюза (его токен в GatewayTokens) или при trustHeaders. Иначе возвращает def.
+func requestPriority(ctx context.Context, tenants map[string]limiter.Priority, def limiter.Priority, trustHeaders bool) limiter.Priority {
+	caller, _ := interceptor.CallerFrom(ctx)
+	if caller.Tenant != "" {
+		if p, ok := tenants[caller.Tenant]; ok {
+			return p
+		}
+	}
+	if !trustHeaders && !caller.Gateway {
+		return def
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return def
+	}
+	if ids := md.Get(tenantHeader); len(ids) > 0 {
+		if p, ok := tenants[ids[0]]; ok {
+			return p
+		}
+	}
+	if values := md.Get(priorityHeader); len(values) > 0 {
+		if p, err := limiter.ParsePriority(values[0]); err == nil {
+			return p
+		}
+	}
+	return def
+}
+
+// clientAddr возвращает адрес клиента из контекста gRPC
+func clientAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"videobalance/internal/interceptor"
+	"videobalance/internal/limiter"
+)
+
+// Приоритет определяется по токену; x-tenant и x-priority принимаются только от шлюза
+func TestRequestPriority(t *testing.T) {
+	auth := interceptor.Auth(interceptor.AuthOptions{
+		TenantTokens:  map[string]string{"acme": "acme-token", "live": "live-token"},
+		GatewayTokens: []string{"gateway-token"},
+	}).Unary
+	tenants := map[string]limiter.Priority{"acme": limiter.PriorityHigh, "live": limiter.PriorityCritical}
+
+	for _, tc := range []struct {
+		name         string
+		md           []string
+		trustHeaders bool
+		want         limiter.Priority
+	}{
+		{name: "арендатор по токену", md: []string{"authorization", "Bearer acme-token"}, want: limiter.PriorityHigh},
+		{name: "x-tenant от арендатора не учитывается",
+			md: []string{"authorization", "Bearer acme-token", "x-tenant", "live"}, want: limiter.PriorityHigh},
+		{name: "x-priority от арендатора не учитывается",
+			md: []string{"authorization", "Bearer acme-token", "x-priority", "critical"}, want: limiter.PriorityHigh},
+		{name: "x-tenant от шлюза", md: []string{"authorization", "Bearer gateway-token", "x-tenant", "live"}, want: limiter.PriorityCritical},
+		{name: "x-priority от шлюза", md: []string{"authorization", "Bearer gateway-token", "x-priority", "normal"}, want: limiter.PriorityNormal},
+		{name: "шлюз без метаданных", md: []string{"authorization", "Bearer gateway-token"}, want: limiter.PriorityLow},
+		{name: "доверие к метаданным", md: []string{"authorization", "Bearer gateway-token", "x-tenant", "acme"}, trustHeaders: true, want: limiter.PriorityHigh},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tc.md...))
+			var got limiter.Priority
+			_, err := auth(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/videobalance.Balancer/Redirect"}, func(ctx context.Context, _ any) (any, error) {
+				got = requestPriority(ctx, tenants, limiter.PriorityLow, tc.trustHeaders)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("приоритет %v, ожидался %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// Без Auth метаданные учитываются только при доверии к ним
+func TestRequestPriorityWithoutAuth(t *testing.T) {
+	tenants := map[string]limiter.Priority{"acme": limiter.PriorityHigh}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant", "acme", "x-priority", "critical"))
+	if got := requestPriority(ctx, tenants, limiter.PriorityLow, false); got != limiter.PriorityLow {
+		t.Fatalf("без доверия: %v", got)
+	}
+	if got := requestPriority(ctx, tenants, limiter.PriorityLow, true); got != limiter.PriorityHigh {
+		t.Fatalf("с доверием: %v", got)
+	}
+}
@@ -2,123 +2,419 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	_ "github.com/hashicorp/golang-lru"
-	"golang.org/x/sync/semaphore"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"log/slog"
+	"reflect"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+	"videobalance/internal/accesslog"
+	"videobalance/internal/apierr"
 	"videobalance/internal/cache"
+	"videobalance/internal/interceptor"
+	"videobalance/internal/limiter"
+	"videobalance/internal/logs"
+	"videobalance/internal/metrics"
+	"videobalance/internal/offload"
+	"videobalance/internal/popularity"
+	"videobalance/internal/scheduler"
+	"videobalance/internal/tracing"
 	"videobalance/internal/util"
 	"videobalance/internal/worker"
 	pb "videobalance/proto"
 )
 
 const (
-	maxConcurrentRequests = 5000 // Максимальное количество параллельных запросов
-	defaultWorkerPoolSize = 500  // Начальный размер пула горутин
+	backendCDN    = "cdn"    // Запрос перенаправлен на CDN
+	backendOrigin = "origin" // Запрос перенаправлен на origin-сервер
+
+	defaultRequestTimeout = 10 * time.Second // Максимальное время обработки запроса по умолчанию
 )
 
-var (
-	// Инициализация семафора для ограничения количества параллельных запросов
-	sem = semaphore.NewWeighted(maxConcurrentRequests)
+var tracer = tracing.Tracer() // Спаны этапов обработки запроса
 
-	// Пул горутин для обработки запросов
-	workerPool = make(chan struct{}, defaultWorkerPoolSize)
+// Ошибка для операций, которым нужен CDN, когда он не указан
+var errNoCDN = errors.New("CDN не указан")
 
-	// Счетчики запросов по каждому видео
-	videoRequestCounts sync.Map
-)
+// Ошибка для маршрута, вычисленного по маршрутизации, которую успели заменить
+var errRoutingChanged = errors.New("маршрутизация изменилась во время вычисления маршрута")
 
 // Балансировщик запросов
 type BalancerServer struct {
 	pb.UnimplementedBalancerServer
+	logger    *slog.Logger
+	accessLog *accesslog.Logger    // Журнал доступа (nil — не пишется)
+	jobs      *scheduler.Scheduler // Периодические задачи, работающие вместе с сервером
+	health    *backendHealth       // Результаты проверок доступности CDN и origin-серверов
+
+	routing  atomic.Pointer[routing] // Текущие маршрутизация и лимиты, заменяются в Reload
+	reloadMu sync.Mutex              // Упорядочивает одновременные Reload
+}
+
+// Маршрутизация и лимиты, заменяемые целиком при перезагрузке конфигурации.
+// Запрос читает их один раз в начале, поэтому выполняющиеся запросы замену не видят.
+type routing struct {
 	balancerDomain string
 	cdnHost        string
-	logger         *slog.Logger
-	mu             sync.Mutex // для защиты локального счетчика от гонок
+	offload        *offload.Controller         // Регулятор доли запросов на origin
+	limiter        *limiter.Limiter            // Адаптивный лимит параллельных запросов
+	tenants        map[string]limiter.Priority // Приоритеты арендаторов
+	defPriority    limiter.Priority            // Приоритет запросов без арендатора и x-priority
+	trustHeaders   bool                        // Учитывать x-tenant и x-priority от любого клиента
+	timeout        time.Duration               // Максимальное время обработки запроса
+	opts           Options                     // Параметры, из которых построены offload и limiter
 }
 
-// Конструктор балансировщика
-func NewBalancerServer(balancerDomain, cdnHost string) *BalancerServer {
-	worker.AdjustWorkerPoolSize()
+// Options — дополнительные параметры балансировщика
+type Options struct {
+	Offload   offload.Options
+	Limiter   limiter.Options
+	AccessLog *accesslog.Logger
+
+	Tenants         map[string]limiter.Priority // Приоритет запросов арендатора
+	DefaultPriority limiter.Priority            // Приоритет запросов без арендатора и x-priority
+	TrustHeaders    bool                        // Учитывать x-tenant и x-priority не только от доверенного шлюза
+	RequestTimeout  time.Duration               // Максимальное время обработки запроса (0 — 10 секунд)
+
+	// Период проверки доступности CDN и origin-серверов (0 — не проверяются).
+	// Как и журнал доступа, задаётся только при создании.
+	HealthCheckInterval time.Duration
+}
 
-	return &BalancerServer{
+// Построение маршрутизации. Регулятор и ограничитель прежней маршрутизации
+// сохраняются, если их параметры не изменились, чтобы не терять накопленное состояние.
+func newRouting(balancerDomain, cdnHost string, opts Options, prev *routing) *routing {
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = defaultRequestTimeout
+	}
+	rt := &routing{
 		balancerDomain: balancerDomain,
 		cdnHost:        cdnHost,
-		logger:         slog.Default(),
+		tenants:        opts.Tenants,
+		defPriority:    opts.DefaultPriority,
+		trustHeaders:   opts.TrustHeaders,
+		timeout:        opts.RequestTimeout,
+		opts:           opts,
+	}
+
+	if prev != nil && reflect.DeepEqual(prev.opts.Offload, opts.Offload) {
+		rt.offload = prev.offload
+	} else {
+		rt.offload = offload.New(opts.Offload)
+	}
+
+	if prev != nil && reflect.DeepEqual(prev.opts.Limiter, opts.Limiter) {
+		rt.limiter = prev.limiter
+	} else {
+		limiterOpts := opts.Limiter
+		if prev != nil {
+			// Новый ограничитель начинает с достигнутого лимита, а не с начального
+			limiterOpts.InitialLimit = prev.limiter.Limit()
+		}
+		rt.limiter = limiter.New(limiterOpts)
+	}
+	return rt
+}
+
+// Конструктор балансировщика
+func NewBalancerServer(balancerDomain, cdnHost string, opts Options) *BalancerServer {
+	jobs := scheduler.New()
+	for _, job := range []scheduler.Job{
+		{Name: "cache-cleaner", Interval: cache.CleanInterval, Jitter: 30 * time.Second, Timeout: time.Minute, Run: cache.CleanExpired},
+		{Name: "popularity-decay", Interval: popularity.DecayInterval, Timeout: popularity.DecayInterval, Run: popularity.Decay},
+		// Масштабирование не должно ждать в очереди исполнителя, размер которого оно меняет
+		{Name: "worker-autoscale", Interval: worker.ScaleInterval, Timeout: worker.ScaleInterval, Inline: true, Run: worker.MonitorPoolSize},
+	} {
+		if err := jobs.Register(job); err != nil {
+			panic(err)
+		}
 	}
+
+	s := &BalancerServer{
+		logger:    logs.For("server"),
+		accessLog: opts.AccessLog,
+		jobs:      jobs,
+		health:    newBackendHealth(),
+	}
+	s.routing.Store(newRouting(balancerDomain, cdnHost, opts, nil))
+	if opts.HealthCheckInterval > 0 {
+		if err := jobs.Register(scheduler.Job{
+			Name:     "backend-health",
+			Interval: opts.HealthCheckInterval,
+			Jitter:   opts.HealthCheckInterval / 10,
+			Timeout:  opts.HealthCheckInterval,
+			Run:      s.checkBackends,
+		}); err != nil {
+			panic(err)
+		}
+	}
+	return s
+}
+
+// Reload атомарно заменяет маршрутизацию и лимиты. Выполняющиеся запросы
+// завершаются с прежними. При смене CDN кэш маршрутов очищается.
+// Журнал доступа и период проверки доступности в opts не учитываются: они задаются только при создании.
+func (s *BalancerServer) Reload(balancerDomain, cdnHost string, opts Options) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	prev := s.routing.Load()
+	rt := newRouting(balancerDomain, cdnHost, opts, prev)
+	s.routing.Store(rt)
+
+	if prev.cdnHost != rt.cdnHost {
+		removed := cache.Purge()
+		s.logger.Info("CDN изменён, кэш маршрутов очищен", "было", prev.cdnHost, "стало", rt.cdnHost, "удалено", removed)
+	}
+	s.logger.Info("Маршрутизация обновлена",
+		"cdn", rt.cdnHost, "domain", rt.balancerDomain, "timeout", rt.timeout,
+		"offload_changed", rt.offload != prev.offload, "limiter_changed", rt.limiter != prev.limiter)
+}
+
+// Start запускает периодические задачи балансировщика
+func (s *BalancerServer) Start() {
+	s.jobs.Start()
+}
+
+// Shutdown останавливает периодические задачи, ожидая выполняющиеся не дольше, чем живёт ctx
+func (s *BalancerServer) Shutdown(ctx context.Context) error {
+	return s.jobs.Stop(ctx)
+}
+
+// Jobs возвращает планировщик периодических задач балансировщика
+func (s *BalancerServer) Jobs() *scheduler.Scheduler {
+	return s.jobs
+}
+
+// OffloadStats возвращает состояние регулятора доли запросов на origin
+func (s *BalancerServer) OffloadStats() offload.Stats {
+	return s.routing.Load().offload.Stats()
+}
+
+// LimiterStats возвращает текущий лимит параллельных запросов и его показатели
+func (s *BalancerServer) LimiterStats() limiter.Stats {
+	return s.routing.Load().limiter.Stats()
 }
 
-func (s *BalancerServer) Redirect(ctx context.Context, req *pb.RedirectRequest) (*pb.RedirectResponse, error) {
+func (s *BalancerServer) Redirect(ctx context.Context, req *pb.RedirectRequest) (resp *pb.RedirectResponse, err error) {
+	// Запись журнала доступа заполняется по ходу обработки и пишется при выходе
+	start := time.Now()
+	rt := s.routing.Load()
+	rec := accesslog.Record{
+		RequestID: interceptor.RequestID(ctx),
+		Client:    clientAddr(ctx),
+		Video:     req.Video,
+		Cache:     accesslog.CacheMiss,
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("url.full", logs.Redact(req.Video)))
+	defer func() {
+		rec.Latency = time.Since(start)
+		if resp != nil {
+			rec.Target = resp.TargetUrl
+		}
+		if err != nil {
+			rec.Error = err.Error()
+			// Клиент получает код gRPC и подробности ошибки вместо UNKNOWN
+			err = apierr.Status(err).Err()
+		}
+		s.accessLog.Log(rec)
+
+		backend := rec.Backend
+		if backend == "" {
+			backend = "none"
+		}
+		metrics.Redirects.WithLabelValues(backend, rec.Reason).Inc()
+	}()
+
 	// Устанавливаем тайм-аут для обработки запроса
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, rt.timeout)
 	defer cancel()
 
-	// Попытка захватить семафор для ограничения параллельных запросов
-	if err := sem.Acquire(ctx, 1); err != nil {
-		s.logger.Warn("Не удалось захватить семафор", "error", err, "video", req.Video)
-		return nil, fmt.Errorf("не удалось захватить семафор: %v", err)
+	// Запросы сверх адаптивного лимита отклоняются сразу, а не ждут в очереди,
+	// начиная с запросов низкого приоритета. Клиенту сообщается, когда повторить запрос.
+	priority := requestPriority(ctx, rt.tenants, rt.defPriority, rt.trustHeaders)
+	rec.Priority = priority.String()
+	_, admission := tracer.Start(ctx, "admission", trace.WithAttributes(attribute.String("videobalance.priority", rec.Priority)))
+	token, ok := rt.limiter.Acquire(priority)
+	admission.SetAttributes(attribute.Bool("videobalance.admitted", ok))
+	admission.End()
+	if !ok {
+		limit, retryAfter := rt.limiter.Limit(), rt.limiter.RetryAfter(priority)
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(retryPushbackHeader, strconv.FormatInt(retryAfter.Milliseconds(), 10)))
+		s.logger.DebugContext(ctx, "Превышен лимит параллельных запросов", "limit", limit, "priority", priority, "video", req.Video)
+		rec.Reason = "overloaded"
+		return nil, &apierr.Error{
+			Err: fmt.Errorf("%w: превышен лимит параллельных запросов (%d) для приоритета %s, повторите через %v",
+				apierr.ErrOverloaded, limit, priority, retryAfter.Round(time.Millisecond)),
+			RetryAfter: retryAfter,
+			Metadata:   map[string]string{"priority": priority.String(), "limit": strconv.Itoa(limit)},
+		}
 	}
-	defer sem.Release(1) // Освобождаем семафор
+	defer func() { token.Release(limiterOutcome(ctx)) }()
 
-	// Попытка захватить место в пуле горутин
-	select {
-	case workerPool <- struct{}{}: // Блокируем горутину, если пул заполнился
-		defer func() { <-workerPool }() // Освобождаем место в пуле
-	case <-ctx.Done():
-		s.logger.Warn("Пул горутин заполнен, запрос ожидает")
-		return nil, fmt.Errorf("запрос был отменен или превышен тайм-аут")
-	}
+	// Учитываем запрос в трекере популярности; оценка влияет на разгрузку origin
+	hits := popularity.Observe(req.Video)
 
 	// Проверка наличия URL в кэше
-	if cachedURL, found := cache.GetFromCache(req.Video); found {
-		s.logger.Info("URL найден в кэше", "url", cachedURL)
-		return &pb.RedirectResponse{TargetUrl: cachedURL}, nil
-
+	_, lookup := tracer.Start(ctx, "cache.lookup")
+	res := cache.LookupInCache(req.Video)
+	lookup.SetAttributes(attribute.Bool("cache.found", res.Found), attribute.Bool("cache.stale", res.Stale), attribute.Bool("cache.negative", res.Negative))
+	lookup.End()
+	if res.Negative {
+		// URL недавно не удалось разобрать, повторно не разбираем и не логируем ошибку
+		rec.Cache = accesslog.CacheNegative
+		rec.Reason = "invalid_url"
+		return nil, &apierr.Error{Err: res.Err, Field: "video"}
 	}
 
-	// Используем функцию из util для разбора видео URL
-	server, path, err := util.ParseVideoURL(req.Video)
+	// Выбор направления: origin, запись кэша или CDN
+	ctx, route := tracer.Start(ctx, "route")
+	defer func() {
+		route.SetAttributes(attribute.String("videobalance.backend", rec.Backend), attribute.String("videobalance.reason", rec.Reason))
+		route.End()
+	}()
+
+	// Используем функцию из util для разбора видео URL: сервер нужен и для разгрузки origin, и для маршрута на CDN
+	server, path, err := parseVideoURL(ctx, req.Video)
 	if err != nil {
-		s.logger.Error("Не удалось разобрать URL", "url", req.Video, "error", err)
-		return nil, err
+		s.logger.ErrorContext(ctx, "Не удалось разобрать URL", "url", req.Video, "error", err)
+		metrics.URLParseErrors.Inc()
+		cache.AddNegativeToCache(req.Video, err)
+		rec.Reason = "invalid_url"
+		return nil, &apierr.Error{Err: err, Field: "video"}
 	}
+	rec.Server, rec.Path = server, path
 
-	// Получаем текущий счетчик запросов
-	count := s.incrementRequestCount(req.Video)
+	// Устаревшую запись пересчитываем в фоне, даже если этот запрос уйдёт на origin
+	if res.Revalidate {
+		s.revalidateAsync(req.Video)
+	}
 
-	// Логика перенаправления для каждого 10-го запроса
-	if count%10 == 0 {
-		// Печатаем логи для диагностики
-		s.logger.Info("Перенаправление на оригинальный URL", "url", req.Video, "номер_запроса", count)
+	// Часть запросов отправляется на origin, чтобы держать его долю нагрузки на целевом уровне.
+	// Популярные видео всегда идут через CDN.
+	if rt.offload.Sample(hits) && rt.offload.Admit(server) {
+		s.logger.InfoContext(ctx, "Перенаправление на оригинальный URL", "url", req.Video, "сервер", server)
+		rec.Backend, rec.Reason = backendOrigin, "origin_offload"
 		return &pb.RedirectResponse{TargetUrl: req.Video}, nil
 	}
 
+	if res.Found {
+		rec.Cache, rec.Backend, rec.Reason = accesslog.CacheHit, backendCDN, "cache"
+		if res.Stale {
+			rec.Cache = accesslog.CacheStale
+		}
+		s.logger.InfoContext(ctx, "URL найден в кэше", "url", res.URL, "устаревший", res.Stale)
+		return &pb.RedirectResponse{TargetUrl: res.URL}, nil
+	}
+
 	// Если cdnHost пуст, используем оригинальный URL
-	if s.cdnHost == "" {
+	if rt.cdnHost == "" {
 
-		s.logger.Info("Перенаправление на оригинальный URL, CDN не указан", "url", req.Video)
+		s.logger.InfoContext(ctx, "Перенаправление на оригинальный URL, CDN не указан", "url", req.Video)
 
+		rec.Backend, rec.Reason = backendOrigin, "no_cdn"
 		return &pb.RedirectResponse{TargetUrl: req.Video}, nil
 	}
 
 	// Формируем URL для перенаправления на CDN
-	cdnURL := fmt.Sprintf("http://%s/%s", s.cdnHost, server, path)
+	cdnURL := rt.cdnURL(server, path)
 
-	s.logger.Info("Перенаправление на CDN", "url", cdnURL)
+	s.logger.InfoContext(ctx, "Перенаправление на CDN", "url", cdnURL)
 
-	// Кэшируем URL
+	// Кэшируем URL, если за время запроса CDN не сменился
+	s.cacheRoute(rt, req.Video, cdnURL)
+	rec.Backend, rec.Reason = backendCDN, "cdn"
 	return &pb.RedirectResponse{TargetUrl: cdnURL}, nil
 }
 
-// Получение и обновление локального счетчика запросов
-func (s *BalancerServer) incrementRequestCount(video string) uint64 {
-	countInterface, _ := videoRequestCounts.LoadOrStore(video, uint64(0))
-	count := countInterface.(uint64)
-	newCount := atomic.AddUint64(&count, 1)
-	videoRequestCounts.Store(video, newCount)
-	// Возвращаем новое значение счетчика
-	return newCount
+// Разбор URL видео в отдельном спане трассировки
+func parseVideoURL(ctx context.Context, video string) (server, path string, err error) {
+	_, span := tracer.Start(ctx, "parse")
+	defer span.End()
+	server, path, err = util.ParseVideoURL(video)
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return server, path, err
+}
+
+// Формирование URL для перенаправления на CDN
+func (rt *routing) cdnURL(server, path string) string {
+	return fmt.Sprintf("http://%s/%s/%s", rt.cdnHost, server, path)
+}
+
+// Сохранение маршрута в кэше, если маршрутизация, по которой он вычислен, ещё действует
+func (s *BalancerServer) cacheRoute(rt *routing, video, url string) bool {
+	if s.routing.Load() != rt {
+		return false
+	}
+	cache.AddToCache(video, url)
+	return true
+}
+
+// Обновление устаревшей записи кэша на исполнителе задач. Если очередь заполнена,
+// обновление повторит следующий запрос этой записи.
+func (s *BalancerServer) revalidateAsync(video string) {
+	_, err := worker.TrySubmit(context.Background(), func(context.Context) error {
+		s.revalidate(video)
+		return nil
+	})
+	if err != nil {
+		cache.RevalidateFailed(video)
+		s.logger.Debug("Фоновое обновление записи кэша отложено", "url", video, "error", err)
+	}
+}
+
+// Фоновое обновление устаревшей записи кэша. При неудаче, в том числе без CDN,
+// отметка об обновлении снимается, чтобы его повторил следующий запрос.
+func (s *BalancerServer) revalidate(video string) {
+	if err := s.warm(video); err != nil {
+		cache.RevalidateFailed(video)
+		s.logger.Debug("Не удалось обновить запись кэша", "url", video, "error", err)
+		return
+	}
+	s.logger.Debug("Запись кэша обновлена в фоне", "url", video)
+}
+
+// Вычисление маршрута на CDN и сохранение его в кэше.
+// Разгрузка origin здесь не учитывается: это не запрос клиента.
+func (s *BalancerServer) warm(video string) error {
+	server, path, err := util.ParseVideoURL(video)
+	if err != nil {
+		metrics.URLParseErrors.Inc()
+		cache.AddNegativeToCache(video, err)
+		return err
+	}
+
+	rt := s.routing.Load()
+	if rt.cdnHost == "" {
+		// Без CDN запросы идут на оригинальный URL, кэшировать нечего
+		return errNoCDN
+	}
+
+	if !s.cacheRoute(rt, video, rt.cdnURL(server, path)) {
+		return errRoutingChanged
+	}
+	return nil
+}
+
+// Результат запроса для ограничителя: истёкший срок означает перегрузку,
+// а отмена клиентом не говорит о нагрузке
+func limiterOutcome(ctx context.Context) limiter.Outcome {
+	switch ctx.Err() {
+	case nil:
+		return limiter.Success
+	case context.DeadlineExceeded:
+		return limiter.Dropped
+	default:
+		return limiter.Ignore
+	}
 }
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "videobalance"
+
+	// TraceIDHeader — заголовок ответа gRPC с идентификатором трассировки
+	TraceIDHeader = "x-trace-id"
+)
+
+// Протоколы экспорта OTLP
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// Options задаёт экспорт трассировок
+type Options struct {
+	Endpoint    string  // URL коллектора OTLP, например http://collector:4317; пусто — трассировки не экспортируются
+	Protocol    string  // grpc (по умолчанию) или http
+	SampleRatio float64 // Доля новых трассировок, которые записываются; решение входящего контекста соблюдается
+	ServiceName string  // Имя сервиса в ресурсе (по умолчанию videobalance)
+}
+
+// Start настраивает распространение контекста W3C (traceparent, tracestate, baggage) и,
+// если задан Endpoint, экспорт спанов по OTLP. Возвращённая функция отправляет
+// накопленные спаны и останавливает экспорт.
+func Start(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = instrumentationName
+	}
+
+	var client otlptrace.Client
+	switch opts.Protocol {
+	case ProtocolGRPC, "":
+		client = otlptracegrpc.NewClient(otlptracegrpc.WithEndpointURL(opts.Endpoint))
+	case ProtocolHTTP:
+		client = otlptracehttp.NewClient(otlptracehttp.WithEndpointURL(opts.Endpoint))
+	default:
+		return nil, fmt.Errorf("неизвестный протокол OTLP %q", opts.Protocol)
+	}
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("создание экспорта OTLP: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трассировщик сервиса. Его можно получить до Start:
+// спаны начнут записываться после настройки экспорта.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TraceID возвращает идентификатор трассировки из ctx; пусто, если трассировки нет
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
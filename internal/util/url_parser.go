@@ -1,11 +1,15 @@
 package util
 
 import (
-	"errors"
-	"log/slog"
+	"fmt"
 	"regexp"
+
+	"videobalance/internal/apierr"
+	"videobalance/internal/logs"
 )
 
+var logger = logs.For("util") // Логгер пакета с отдельно настраиваемым уровнем
+
 // Регулярные выражения для извлечения частей URL
 var (
 	// Регулярное выражение для извлечения сервера из URL (например, s1, s2 и т.д.)
@@ -13,22 +17,40 @@ var (
 
 	// Регулярное выражение для извлечения пути из URL (например, video/123/xcg2djHckad.m3u8)
 	pathRegex = regexp.MustCompile(`https?://s\d+\.origin-cluster/(.*)`) // Поддержка http и https
+
+	// Регулярное выражение для хоста URL, не относящегося к origin-cluster
+	hostRegex = regexp.MustCompile(`^https?://(?:[^/?#\s@]*@)?([^/?#\s]+)`) // Без учётных данных
 )
 
+// URLError — ошибка разбора URL видео. errors.Is сопоставляет её с apierr.ErrBadURL,
+// если URL не разобран, или с apierr.ErrUnknownOrigin, если хост не из origin-cluster.
+type URLError struct {
+	URL    string
+	Reason string // Что не удалось разобрать
+	Err    error  // apierr.ErrBadURL или apierr.ErrUnknownOrigin
+}
+
+func (e *URLError) Error() string { return e.Reason }
+
+func (e *URLError) Unwrap() error { return e.Err }
+
 // ParseVideoURL разбирает входной URL и возвращает сервер и путь
 // url - входной URL в формате https://s1.origin-cluster/video/123/xcg2djHckad.m3u8
 // Возвращает:
 // - server (например, s1)
 // - path (например, video/123/xcg2djHckad.m3u8)
-// - err (ошибка, если URL не может быть разобран)
+// - err (*URLError, если URL не может быть разобран)
 func ParseVideoURL(url string) (server string, path string, err error) {
 	// Извлекаем сервер из URL с использованием регулярного выражения
 	serverMatch := serverRegex.FindStringSubmatch(url)
 	if len(serverMatch) < 2 {
 		// Логируем ошибку, если сервер не может быть извлечен из URL
-		slog.Error("Ошибка при разборе URL: не удалось извлечь сервер", "url", url)
-		// Возвращаем ошибку, если сервер не найден
-		return "", "", errors.New("не удалось извлечь сервер из URL")
+		logger.Error("Ошибка при разборе URL: не удалось извлечь сервер", "url", url)
+		// URL с другим хостом указывает на неизвестный origin, остальные — некорректны
+		if hostMatch := hostRegex.FindStringSubmatch(url); hostMatch != nil {
+			return "", "", &URLError{URL: url, Reason: fmt.Sprintf("хост %s не является сервером origin-cluster", hostMatch[1]), Err: apierr.ErrUnknownOrigin}
+		}
+		return "", "", &URLError{URL: url, Reason: "не удалось извлечь сервер из URL", Err: apierr.ErrBadURL}
 	}
 	// Присваиваем извлеченное значение серверу
 	server = serverMatch[1]
@@ -37,15 +59,15 @@ func ParseVideoURL(url string) (server string, path string, err error) {
 	pathMatch := pathRegex.FindStringSubmatch(url)
 	if len(pathMatch) < 2 {
 		// Логируем ошибку, если путь не может быть извлечен из URL
-		slog.Error("Ошибка при разборе URL: не удалось извлечь путь", "url", url)
+		logger.Error("Ошибка при разборе URL: не удалось извлечь путь", "url", url)
 		// Возвращаем ошибку, если путь не найден
-		return "", "", errors.New("не удалось извлечь путь из URL")
+		return "", "", &URLError{URL: url, Reason: "не удалось извлечь путь из URL", Err: apierr.ErrBadURL}
 	}
 	// Присваиваем извлеченное значение пути
 	path = pathMatch[1]
 
 	// Логируем успешное извлечение данных из URL
-	slog.Info("URL успешно разобран", "сервер", server, "путь", path)
+	logger.Info("URL успешно разобран", "сервер", server, "путь", path)
 
 	// Возвращаем извлеченные данные и ошибку (если она есть)
 	return server, path, nil
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ReadVideoList читает список URL видео по одному в строке.
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+func ReadVideoList(r io.Reader) ([]string, error) {
+	var videos []string
+
+	scanner := bufio.NewScanner(r)
+	// Подписанные URL бывают длинными, поэтому увеличиваем максимальный размер строки
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		videos = append(videos, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
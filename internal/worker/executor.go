@@ -0,0 +1,551 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"videobalance/internal/logs"
+)
+
+const (
+	defaultWorkers         = 16                     // Начальное количество рабочих горутин по умолчанию
+	defaultMinWorkers      = 4                      // Нижняя граница количества горутин по умолчанию
+	defaultMaxWorkers      = 256                    // Верхняя граница количества горутин по умолчанию
+	defaultQueueSize       = 1024                   // Ёмкость очереди задач по умолчанию
+	defaultTargetQueueWait = 100 * time.Millisecond // Допустимое время ожидания задачи в очереди по умолчанию
+	defaultDrainTimeout    = 30 * time.Second       // Время на выполнение оставшихся задач при Shutdown
+	scaleDownIdleTicks     = 5                      // Сколько проверок подряд горутины должны простаивать перед уменьшением пула
+)
+
+// Верхние границы интервалов гистограммы ожидания задачи в очереди
+var queueWaitBuckets = [...]time.Duration{
+	100 * time.Microsecond, time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+var (
+	// ErrClosed возвращается Submit после начала остановки исполнителя
+	ErrClosed = errors.New("исполнитель остановлен")
+
+	// ErrQueueFull возвращается TrySubmit, если в очереди нет свободного места
+	ErrQueueFull = errors.New("очередь исполнителя заполнена")
+
+	// ErrPanic оборачивает панику, возникшую в задаче
+	ErrPanic = errors.New("паника в задаче")
+)
+
+// Task — задача исполнителя. Контекст отменяется по таймауту задачи,
+// отмене контекста Submit или принудительной остановке исполнителя.
+type Task func(ctx context.Context) error
+
+// Options задаёт параметры исполнителя. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Workers         int           // Начальное количество рабочих горутин
+	MinWorkers      int           // Нижняя граница количества горутин при автомасштабировании
+	MaxWorkers      int           // Верхняя граница количества горутин при автомасштабировании
+	QueueSize       int           // Ёмкость очереди; Submit ждёт, пока в ней не появится место
+	TaskTimeout     time.Duration // Максимальное время выполнения задачи (0 — без ограничения)
+	TargetQueueWait time.Duration // Время ожидания в очереди, при превышении которого пул растёт
+}
+
+// Stats — счётчики исполнителя
+type Stats struct {
+	Workers    int           // Текущее количество рабочих горутин
+	MinWorkers int           // Нижняя граница количества горутин
+	MaxWorkers int           // Верхняя граница количества горутин
+	Latency    time.Duration // Среднее время выполнения задачи за последний интервал масштабирования
+	QueueWait  time.Duration // Среднее время ожидания в очереди за последний интервал масштабирования
+
+	Queued    int    // Задачи в очереди
+	Running   int64  // Выполняющиеся задачи
+	Completed uint64 // Завершённые без ошибки
+	Failed    uint64 // Завершённые с ошибкой, включая таймауты и панику
+	TimedOut  uint64 // Превысившие TaskTimeout
+	Panics    uint64 // Завершённые паникой
+	Skipped   uint64 // Не запущенные, так как контекст Submit отменён до начала выполнения
+
+	QueueWaitHistogram Histogram // Ожидание в очереди всех взятых из неё задач с создания исполнителя
+}
+
+// Histogram — распределение длительностей
+type Histogram struct {
+	Bounds []time.Duration // Верхние границы интервалов по возрастанию
+	Counts []uint64        // Counts[i] — значения не больше Bounds[i], нарастающим итогом
+	Count  uint64          // Все значения, включая больше последней границы
+	Sum    time.Duration
+}
+
+// Executor выполняет задачи из ограниченной очереди пулом горутин.
+// Размер пула меняется в пределах MinWorkers..MaxWorkers при вызове autoscale:
+// пул растёт, когда задачи скапливаются в очереди, и уменьшается, когда горутины простаивают.
+type Executor struct {
+	opts   Options
+	queue  chan *job
+	retire chan struct{} // Сигналы горутинам завершиться при уменьшении пула
+	size   atomic.Int64  // Целевое количество рабочих горутин
+
+	// Отменяется, когда оставшиеся задачи нужно прервать при остановке
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.RWMutex   // Защищает closed от гонки с регистрацией в submitters
+	closed     bool           // Устанавливается в Shutdown; новые задачи не принимаются
+	closing    chan struct{}  // Закрывается в Shutdown и прерывает ожидание места в очереди
+	submitters sync.WaitGroup // Вызовы Submit, которые могут отправить задачу в queue
+	workers    sync.WaitGroup
+
+	running   atomic.Int64
+	completed atomic.Uint64
+	failed    atomic.Uint64
+	timedOut  atomic.Uint64
+	panics    atomic.Uint64
+	skipped   atomic.Uint64
+
+	// Измерения текущего интервала масштабирования
+	peakRunning atomic.Int64
+	windowTasks atomic.Int64
+	windowBusy  atomic.Int64 // Суммарное время выполнения задач в наносекундах
+	windowWait  atomic.Int64 // Суммарное время ожидания в очереди в наносекундах
+
+	// Гистограмма ожидания в очереди; последний элемент — больше всех границ
+	waitCounts [len(queueWaitBuckets) + 1]atomic.Uint64
+	waitSum    atomic.Int64
+
+	scaleMu   sync.Mutex // Защищает idleTicks от одновременных вызовов autoscale
+	idleTicks int
+	latency   atomic.Int64
+	queueWait atomic.Int64
+}
+
+type job struct {
+	ctx    context.Context
+	task   Task
+	future *Future
+	queued time.Time
+}
+
+// Future — результат задачи, доступный после её завершения
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// Done закрывается после завершения задачи
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait ждёт завершения задачи и возвращает её ошибку
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err возвращает ошибку завершённой задачи; до завершения — nil
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// NewExecutor создаёт исполнитель и запускает рабочие горутины
+func NewExecutor(opts Options) *Executor {
+	if opts.MinWorkers <= 0 {
+		opts.MinWorkers = min(defaultMinWorkers, max(opts.Workers, 1))
+	}
+	if opts.MaxWorkers <= 0 {
+		opts.MaxWorkers = max(defaultMaxWorkers, opts.Workers)
+	}
+	if opts.MaxWorkers < opts.MinWorkers {
+		opts.MaxWorkers = opts.MinWorkers
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	opts.Workers = min(max(opts.Workers, opts.MinWorkers), opts.MaxWorkers)
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.TargetQueueWait <= 0 {
+		opts.TargetQueueWait = defaultTargetQueueWait
+	}
+
+	e := &Executor{
+		opts:    opts,
+		queue:   make(chan *job, opts.QueueSize),
+		retire:  make(chan struct{}, opts.MaxWorkers),
+		closing: make(chan struct{}),
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+
+	e.size.Store(int64(opts.Workers))
+	e.spawn(opts.Workers)
+	return e
+}
+
+// Запуск n рабочих горутин; после начала остановки новые горутины не запускаются
+func (e *Executor) spawn(n int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	e.workers.Add(n)
+	for i := 0; i < n; i++ {
+		go e.work()
+	}
+}
+
+// Submit ставит задачу в очередь, ожидая свободного места не дольше, чем живёт ctx.
+// Контекст задачи наследует значения и отмену ctx.
+func (e *Executor) Submit(ctx context.Context, task Task) (*Future, error) {
+	j := &job{ctx: ctx, task: task, future: &Future{done: make(chan struct{})}, queued: time.Now()}
+
+	// Блокировка не удерживается во время ожидания места в очереди, иначе Shutdown ждал бы
+	// его без учёта своего ctx. queue закрывается только после выхода всех зарегистрированных Submit.
+	e.mu.RLock()
+	if e.closed {
+		e.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	e.submitters.Add(1)
+	e.mu.RUnlock()
+	defer e.submitters.Done()
+
+	select {
+	case e.queue <- j:
+		return j.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-e.closing:
+		return nil, ErrClosed
+	}
+}
+
+// TrySubmit ставит задачу в очередь без ожидания и возвращает ErrQueueFull,
+// если очередь заполнена. Подходит для необязательной работы на пути запроса.
+func (e *Executor) TrySubmit(ctx context.Context, task Task) (*Future, error) {
+	j := &job{ctx: ctx, task: task, future: &Future{done: make(chan struct{})}, queued: time.Now()}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return nil, ErrClosed
+	}
+	select {
+	case e.queue <- j:
+		return j.future, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+func (e *Executor) work() {
+	defer e.workers.Done()
+	for {
+		select {
+		case j, ok := <-e.queue:
+			if !ok {
+				return
+			}
+			e.run(j)
+		case <-e.retire:
+			return
+		}
+	}
+}
+
+func (e *Executor) run(j *job) {
+	e.observeWait(time.Since(j.queued))
+
+	// Задача, контекст которой отменён, пока она ждала в очереди, не запускается
+	if err := j.ctx.Err(); err != nil {
+		e.skipped.Add(1)
+		e.failed.Add(1)
+		j.future.complete(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+	stop := context.AfterFunc(e.ctx, cancel)
+	defer stop()
+	if e.opts.TaskTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, e.opts.TaskTimeout)
+		defer cancelTimeout()
+	}
+
+	start := time.Now()
+	running := e.running.Add(1)
+	for {
+		peak := e.peakRunning.Load()
+		if running <= peak || e.peakRunning.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+	err := e.call(ctx, j.task)
+	e.running.Add(-1)
+
+	e.windowTasks.Add(1)
+	e.windowBusy.Add(int64(time.Since(start)))
+	e.windowWait.Add(int64(start.Sub(j.queued)))
+
+	switch {
+	case err == nil:
+		e.completed.Add(1)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && j.ctx.Err() == nil:
+		e.timedOut.Add(1)
+		e.failed.Add(1)
+		logs.AsyncLogFor(logPackage, slog.LevelWarn, "Задача превысила таймаут", "timeout", e.opts.TaskTimeout, "error", err)
+	default:
+		e.failed.Add(1)
+	}
+	j.future.complete(err)
+}
+
+// Учёт ожидания задачи в очереди в гистограмме
+func (e *Executor) observeWait(wait time.Duration) {
+	i := 0
+	for i < len(queueWaitBuckets) && wait > queueWaitBuckets[i] {
+		i++
+	}
+	e.waitCounts[i].Add(1)
+	e.waitSum.Add(int64(wait))
+}
+
+// Выполнение задачи с перехватом паники
+func (e *Executor) call(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.panics.Add(1)
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
+			// Стек пишется синхронно: асинхронный логгер обрезает длинные строки
+			logger.Error("Паника в задаче исполнителя", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	return task(ctx)
+}
+
+// Shutdown прекращает приём задач и ждёт выполнения уже поставленных в очередь.
+// Если ctx истекает раньше, контексты оставшихся задач отменяются.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.closing)
+		// Ожидающие места Submit выходят сразу после закрытия closing
+		go func() {
+			e.submitters.Wait()
+			close(e.queue)
+		}()
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		// Оставшиеся задачи получают отменённый контекст и завершаются быстро
+		e.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Пересчёт размера пула по измерениям прошедшего интервала. Пул растёт, когда все
+// горутины заняты и в очереди есть задачи либо задачи ждут в очереди дольше
+// TargetQueueWait: новый размер рассчитывается так, чтобы очередь разобралась за
+// TargetQueueWait при текущем времени выполнения задач, но не более чем вдвое за раз.
+// Пул уменьшается, если горутины простаивают scaleDownIdleTicks проверок подряд.
+func (e *Executor) autoscale() {
+	e.scaleMu.Lock()
+	defer e.scaleMu.Unlock()
+	e.mu.RLock()
+	closed := e.closed
+	e.mu.RUnlock()
+	if closed {
+		return
+	}
+
+	tasks := e.windowTasks.Swap(0)
+	busy := e.windowBusy.Swap(0)
+	wait := e.windowWait.Swap(0)
+	peak := int(e.peakRunning.Swap(e.running.Load()))
+	if tasks > 0 {
+		e.latency.Store(busy / tasks)
+		e.queueWait.Store(wait / tasks)
+	}
+	latency := time.Duration(e.latency.Load())
+	queueWait := time.Duration(e.queueWait.Load())
+
+	size := int(e.size.Load())
+	queued := len(e.queue)
+	target := size
+
+	switch {
+	case queued > 0 && peak >= size, tasks > 0 && queueWait > e.opts.TargetQueueWait:
+		e.idleTicks = 0
+		target = size * 2
+		if latency > 0 {
+			needed := int(int64(queued) * int64(latency) / int64(e.opts.TargetQueueWait))
+			target = min(target, int(e.running.Load())+needed)
+		}
+		target = max(target, size+1)
+	case queued == 0 && peak < size:
+		e.idleTicks++
+		if e.idleTicks >= scaleDownIdleTicks {
+			e.idleTicks = 0
+			target = size - max(1, (size-peak)/2)
+		}
+	default:
+		e.idleTicks = 0
+	}
+	target = min(max(target, e.opts.MinWorkers), e.opts.MaxWorkers)
+	if target == size {
+		return
+	}
+
+	e.size.Store(int64(target))
+	if target > size {
+		// Горутины, ещё не получившие сигнал завершения, остаются в пуле
+		n := target - size
+	reclaim:
+		for ; n > 0; n-- {
+			select {
+			case <-e.retire:
+			default:
+				break reclaim
+			}
+		}
+		e.spawn(n)
+	} else {
+		for i := 0; i < size-target; i++ {
+			e.retire <- struct{}{}
+		}
+	}
+	logs.AsyncLogFor(logPackage, slog.LevelInfo, "Изменён размер пула горутин",
+		"from", size, "to", target, "queued", queued, "peak_running", peak, "latency", latency, "queue_wait", queueWait)
+}
+
+// Stats возвращает счётчики исполнителя
+func (e *Executor) Stats() Stats {
+	return Stats{
+		Workers:    int(e.size.Load()),
+		MinWorkers: e.opts.MinWorkers,
+		MaxWorkers: e.opts.MaxWorkers,
+		Latency:    time.Duration(e.latency.Load()),
+		QueueWait:  time.Duration(e.queueWait.Load()),
+		Queued:     len(e.queue),
+		Running:    e.running.Load(),
+		Completed:  e.completed.Load(),
+		Failed:     e.failed.Load(),
+		TimedOut:   e.timedOut.Load(),
+		Panics:     e.panics.Load(),
+		Skipped:    e.skipped.Load(),
+
+		QueueWaitHistogram: e.waitHistogram(),
+	}
+}
+
+func (e *Executor) waitHistogram() Histogram {
+	h := Histogram{
+		Bounds: slices.Clone(queueWaitBuckets[:]),
+		Counts: make([]uint64, len(queueWaitBuckets)),
+		Sum:    time.Duration(e.waitSum.Load()),
+	}
+	for i := range e.waitCounts {
+		h.Count += e.waitCounts[i].Load()
+		if i < len(h.Counts) {
+			h.Counts[i] = h.Count
+		}
+	}
+	return h
+}
+
+var (
+	defaultExecutor   *Executor // Исполнитель по умолчанию, используемый функциями пакета
+	defaultExecutorMu sync.Mutex
+)
+
+// Start создаёт исполнитель по умолчанию с заданными параметрами.
+// Если Start не вызван, первая Submit создаёт исполнитель с параметрами по умолчанию.
+func Start(opts Options) {
+	defaultExecutorMu.Lock()
+	defer defaultExecutorMu.Unlock()
+	if defaultExecutor != nil {
+		// Задачи, уже поставленные в прежний исполнитель, выполняются в фоне
+		go defaultExecutor.Shutdown(context.Background())
+	}
+	defaultExecutor = NewExecutor(opts)
+}
+
+func executor() *Executor {
+	defaultExecutorMu.Lock()
+	defer defaultExecutorMu.Unlock()
+	if defaultExecutor == nil {
+		defaultExecutor = NewExecutor(Options{})
+	}
+	return defaultExecutor
+}
+
+// Submit ставит задачу в очередь исполнителя по умолчанию
+func Submit(ctx context.Context, task Task) (*Future, error) {
+	return executor().Submit(ctx, task)
+}
+
+// TrySubmit ставит задачу в очередь исполнителя по умолчанию без ожидания
+func TrySubmit(ctx context.Context, task Task) (*Future, error) {
+	return executor().TrySubmit(ctx, task)
+}
+
+// ExecutorStats возвращает счётчики исполнителя по умолчанию
+func ExecutorStats() Stats {
+	return executor().Stats()
+}
+
+// Остановка исполнителя по умолчанию с ожиданием поставленных задач
+func drainDefaultExecutor() {
+	defaultExecutorMu.Lock()
+	e := defaultExecutor
+	defaultExecutorMu.Unlock()
+	if e == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDrainTimeout)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		logs.AsyncLogFor(logPackage, slog.LevelWarn, "Не все задачи завершены при остановке", "error", err)
+	}
+	stats := e.Stats()
+	logs.AsyncLogFor(logPackage, slog.LevelInfo, "Исполнитель задач остановлен",
+		"completed", stats.Completed, "failed", stats.Failed, "timed_out", stats.TimedOut, "panics", stats.Panics)
+}
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Shutdown не должен ждать Submit, заблокированный на заполненной очереди, дольше своего ctx
+func TestShutdownWithBlockedSubmit(t *testing.T) {
+	e := NewExecutor(Options{Workers: 1, MinWorkers: 1, MaxWorkers: 1, QueueSize: 1})
+
+	started := make(chan struct{})
+	block := func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if _, err := e.Submit(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	queued, err := e.Submit(context.Background(), func(context.Context) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Очередь заполнена: этот Submit ждёт места без ограничения по времени
+	submitErr := make(chan error, 1)
+	go func() {
+		_, err := e.Submit(context.Background(), func(context.Context) error { return nil })
+		submitErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := e.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v, ожидалось %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Shutdown завершился через %v", elapsed)
+	}
+
+	select {
+	case err := <-submitErr:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("заблокированный Submit вернул %v, ожидалось %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Submit остался заблокированным после Shutdown")
+	}
+
+	// Задача, уже стоявшая в очереди, завершается, а не теряется
+	if err := queued.Wait(context.Background()); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("задача из очереди: %v", err)
+	}
+	if _, err := e.Submit(context.Background(), block); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Submit после Shutdown = %v, ожидалось %v", err, ErrClosed)
+	}
+}
+
+// Задачи, поставленные до Shutdown, выполняются до его завершения
+func TestShutdownDrainsQueue(t *testing.T) {
+	e := NewExecutor(Options{Workers: 2, QueueSize: 64})
+	var done atomic.Int64
+	for i := 0; i < 50; i++ {
+		if _, err := e.Submit(context.Background(), func(context.Context) error {
+			time.Sleep(time.Millisecond)
+			done.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := done.Load(); n != 50 {
+		t.Fatalf("выполнено %d задач из 50", n)
+	}
+}
+
+// Ожидание в очереди попадает в интервал гистограммы по своей длительности
+func TestQueueWaitHistogram(t *testing.T) {
+	e := NewExecutor(Options{Workers: 1, MinWorkers: 1, MaxWorkers: 1})
+	defer e.Shutdown(context.Background())
+
+	for _, wait := range []time.Duration{0, 3 * time.Millisecond, 30 * time.Second} {
+		e.observeWait(wait)
+	}
+	h := e.Stats().QueueWaitHistogram
+	if h.Count != 3 || h.Sum != 30*time.Second+3*time.Millisecond {
+		t.Fatalf("Count = %d, Sum = %v; ожидается 3 и 30.003s", h.Count, h.Sum)
+	}
+	for i, bound := range h.Bounds {
+		want := uint64(0)
+		switch {
+		case bound >= 5*time.Millisecond:
+			want = 2
+		case bound >= 100*time.Microsecond:
+			want = 1
+		}
+		if h.Counts[i] != want {
+			t.Errorf("значений не больше %v: %d, ожидается %d", bound, h.Counts[i], want)
+		}
+	}
+
+	// Выполненная задача учитывается вместе с ожиданием
+	future, err := e.Submit(context.Background(), func(context.Context) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-future.Done()
+	if h := e.Stats().QueueWaitHistogram; h.Count != 4 {
+		t.Fatalf("Count = %d после задачи, ожидается 4", h.Count)
+	}
+}
+
+// TrySubmit не ждёт места в очереди и не принимает задачи после Shutdown
+func TestTrySubmit(t *testing.T) {
+	e := NewExecutor(Options{Workers: 1, MinWorkers: 1, MaxWorkers: 1, QueueSize: 1})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	if _, err := e.TrySubmit(context.Background(), func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	queued, err := e.TrySubmit(context.Background(), func(context.Context) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.TrySubmit(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("TrySubmit в заполненную очередь = %v, ожидалось %v", err, ErrQueueFull)
+	}
+
+	close(release)
+	if err := queued.Wait(context.Background()); err != nil {
+		t.Fatalf("задача из очереди: %v", err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.TrySubmit(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Fatalf("TrySubmit после Shutdown = %v, ожидалось %v", err, ErrClosed)
+	}
+}